@@ -0,0 +1,48 @@
+#!/bin/bash
+
+# Copyright (c) 2023 Red Hat, Inc.
+
+# Runs the test groups which don't need a cloud against a fake hub,
+# requires the envtest binaries (see KUBEBUILDER_ASSETS) and the test binaries built by: make build
+
+FAKE_HUB_DIR=${FAKE_HUB_DIR:-$(pwd)/fake-hub}
+RUN_ID=${RUN_ID:-fake-hub-$(date -u +%Y%m%d-%H%M%S)}
+OWNER=${OWNER:-ginkgo}
+
+rm -rf "$FAKE_HUB_DIR"
+mkdir -p "$FAKE_HUB_DIR"
+
+echo "Starting the fake hub in $FAKE_HUB_DIR"
+go run ./cmd/fake-hub -dir "$FAKE_HUB_DIR" -v=2 > "$FAKE_HUB_DIR/fake-hub.log" 2>&1 &
+FAKE_HUB_PID=$!
+trap 'kill $FAKE_HUB_PID 2>/dev/null; wait $FAKE_HUB_PID 2>/dev/null' EXIT
+
+for i in $(seq 1 120); do
+  if grep -q "Fake hub running" "$FAKE_HUB_DIR/fake-hub.log"; then
+    break
+  fi
+  if ! kill -0 $FAKE_HUB_PID 2>/dev/null || [ "$i" -eq 120 ]; then
+    echo "The fake hub failed to start"
+    cat "$FAKE_HUB_DIR/fake-hub.log"
+    exit 1
+  fi
+  sleep 1
+done
+
+# the clusters are imported and provisioned first, the fake hub installs the clusterDeployments of the
+# fake aws, azure and gcp credentials of its options, the scale, placement, manifestwork and addon-toggle
+# groups run against them, then the imported clusters are detached and the provisioned clusters destroyed
+failed=0
+for group in import provision scale placement manifestwork addon-toggle destroy; do
+  echo "Tests start $group at "$(date)
+  if ! clc-e2e run "$group" -test-dir=pkg/tests -options="$FAKE_HUB_DIR/options.yaml" -owner="$OWNER" -run-id="$RUN_ID"; then
+    echo "Tests failed $group"
+    failed=1
+  fi
+  echo "Tests end $group at "$(date)
+done
+
+if [ $failed -ne 0 ]; then
+  echo "The fake hub logs are in $FAKE_HUB_DIR/fake-hub.log"
+fi
+exit $failed
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	clusterjanitor "github.com/stolostron/cluster-lifecycle-e2e/pkg/janitor"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/runner"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
+	libgoconfig "github.com/stolostron/library-go/pkg/config"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+const usage = `Usage:
+  clc-e2e run <group> [flags] [-- <test binary args>]
+  clc-e2e cleanup -run-id=<run ID> [flags]
+  clc-e2e janitor [-ttl=<duration>] [-destroy] [flags]
+  clc-e2e list
+
+Commands:
+  run      validate the options and run the tests of a group
+  cleanup  delete the objects created on the hub by a run
+  janitor  report or destroy the clusters left on the hub by any run and older than a TTL
+  list     list the test groups
+`
+
+// clc-e2e runs the compiled test binaries of a test group with ginkgo.
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "run":
+		os.Exit(run(os.Args[2:]))
+	case "cleanup":
+		os.Exit(cleanup(os.Args[2:]))
+	case "janitor":
+		os.Exit(janitor(os.Args[2:]))
+	case "list":
+		list()
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+func list() {
+	for _, g := range runner.Groups() {
+		name := g.Name
+		if len(g.Aliases) != 0 {
+			name = fmt.Sprintf("%s (%s)", g.Name, strings.Join(g.Aliases, ", "))
+		}
+		fmt.Printf("%-30s %s\n", name, g.Description)
+	}
+}
+
+func run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "a test group is required\n%s", usage)
+		return 2
+	}
+	g, err := runner.Lookup(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s, run 'clc-e2e list' for the test groups\n", err)
+		return 2
+	}
+
+	var settings runner.Settings
+	var clouds string
+	var dryRun bool
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.StringVar(&settings.Ginkgo, "ginkgo", "ginkgo", "The ginkgo command")
+	fs.StringVar(&settings.TestDir, "test-dir", ".", "The directory of the compiled test binaries")
+	fs.StringVar(&settings.OptionsFile, "options", "", "Location of the options.yaml, "+
+		"if not set the OPTIONS environment variable or resources/options.yaml is used")
+	fs.StringVar(&settings.Focus, "focus", "", "Override the ginkgo focus of the group")
+	fs.IntVar(&settings.Nodes, "nodes", 0, "Override the number of parallel ginkgo nodes of the group")
+	fs.StringVar(&clouds, "clouds", "", "A comma separated list of cloud providers (ie: aws,gcp) overriding the ones of the group")
+	fs.StringVar(&settings.Owner, "owner", "", "The prefix of the created resources")
+	fs.StringVar(&settings.RunID, "run-id", "", "The label of the created resources, generated if not set")
+	fs.IntVar(&settings.Verbosity, "v", 3, "The log verbosity of the tests")
+	fs.BoolVar(&dryRun, "dry-run", false, "Validate the options and print the plan without running it")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	settings.Args = fs.Args()
+	if settings.RunID == "" {
+		settings.RunID = time.Now().UTC().Format("20060102-150405")
+	}
+	if clouds != "" {
+		settings.Clouds = strings.Split(clouds, ",")
+	}
+
+	plan, err := runner.NewPlan(g, settings)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if errs := runner.Validate(g, settings.OptionsFile, plan.Clouds); len(errs) != 0 {
+		fmt.Fprintf(os.Stderr, "invalid options for the test group %s:\n", g.Name)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "  - %s\n", err)
+		}
+		return 1
+	}
+
+	fmt.Printf("Test group %s: %s\n", g.Name, plan)
+	fmt.Printf("Run ID %s, the created objects can be deleted with: clc-e2e cleanup -run-id=%s\n", settings.RunID, settings.RunID)
+	if dryRun {
+		return 0
+	}
+	if err := plan.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func cleanup(args []string) int {
+	var runID, optionsFile string
+	var dryRun bool
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	klog.InitFlags(fs)
+	fs.StringVar(&runID, "run-id", "", "The ID of the run which created the objects")
+	fs.StringVar(&optionsFile, "options", "", "Location of the options.yaml, "+
+		"if not set the OPTIONS environment variable or resources/options.yaml is used")
+	fs.BoolVar(&dryRun, "dry-run", false, "List the objects without deleting them")
+	_ = fs.Set("v", "1")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if runID == "" {
+		fmt.Fprintf(os.Stderr, "-run-id is required\n%s", usage)
+		return 2
+	}
+
+	client, err := hubClient(optionsFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	objects, err := ownership.Cleanup(client, runID, dryRun)
+	for _, o := range objects {
+		if dryRun {
+			fmt.Printf("%s would be deleted\n", o)
+		} else {
+			fmt.Printf("%s deleted\n", o)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "the cleanup of the run %s is incomplete: %s\n", runID, err)
+		return 1
+	}
+	return 0
+}
+
+func janitor(args []string) int {
+	var optionsFile string
+	var ttl time.Duration
+	var destroy bool
+	var owners string
+	fs := flag.NewFlagSet("janitor", flag.ExitOnError)
+	klog.InitFlags(fs)
+	fs.StringVar(&optionsFile, "options", "", "Location of the options.yaml, "+
+		"if not set the OPTIONS environment variable or resources/options.yaml is used")
+	fs.DurationVar(&ttl, "ttl", 24*time.Hour, "The age from which a cluster created by the tests is destroyed")
+	fs.BoolVar(&destroy, "destroy", false, "Destroy the clusters, otherwise they are only reported")
+	fs.StringVar(&owners, "owners", "", "A comma separated list of owners (ie: ginkgo) whose generated cluster names "+
+		"(<cloud>-<owner>-<uid>) select the clusters without run ID label. If not set only the labeled clusters are selected")
+	_ = fs.Set("v", "1")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var ownerList []string
+	if owners != "" {
+		ownerList = strings.Split(owners, ",")
+	}
+
+	client, err := hubClient(optionsFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	now := time.Now()
+	report, err := clusterjanitor.Find(client, ttl, now, ownerList)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "the clusters can not be listed: %s\n", err)
+		return 1
+	}
+	if len(report.Clusters) == 0 && len(report.ImageSets) == 0 {
+		fmt.Printf("No cluster older than %s\n", ttl)
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tCLOUD\tRUN ID\tAGE\tOBJECTS")
+	for _, c := range report.Clusters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Name, orNone(c.Cloud), orNone(c.RunID),
+			c.Age(now).Round(time.Minute), strings.Join(c.Objects(), ","))
+	}
+	for _, name := range report.ImageSets {
+		fmt.Fprintf(w, "-\t-\t-\t-\tClusterImageSet/%s\n", name)
+	}
+	w.Flush()
+	if !destroy {
+		fmt.Println("Run with -destroy to destroy them")
+		return 0
+	}
+
+	if err := clusterjanitor.Destroy(client, report); err != nil {
+		fmt.Fprintf(os.Stderr, "the clusters are not all destroyed: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// hubClient returns a dynamic client of the hub of the options file
+func hubClient(optionsFile string) (dynamic.Interface, error) {
+	if err := options.Load(optionsFile); err != nil {
+		return nil, fmt.Errorf("options can not be loaded: %v", err)
+	}
+	options.Default()
+	hub := libgooptions.TestOptions.Options.Hub
+	config, err := libgoconfig.LoadConfig(hub.ApiServerURL, hub.KubeConfig, hub.KubeContext)
+	if err != nil {
+		return nil, fmt.Errorf("hub kubeconfig can not be loaded: %v", err)
+	}
+	return dynamic.NewForConfig(config)
+}
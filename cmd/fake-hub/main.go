@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/fakehub"
+	"k8s.io/klog"
+)
+
+// fake-hub starts a local API server acting as a hub and waits for a signal.
+// The suites can then run against it with -options=<dir>/options.yaml
+func main() {
+	var dir string
+	klog.InitFlags(nil)
+	flag.StringVar(&dir, "dir", "", "The directory where the kubeconfig and the options.yaml of the fake hub are written, "+
+		"a temporary directory is created if not set")
+	flag.Parse()
+
+	hub, err := fakehub.Start(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start the fake hub: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Fake hub running\n  kubeconfig: %s\n  options:    %s\n", hub.KubeConfig, hub.OptionsFile)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+
+	if err := hub.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to stop the fake hub: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,19 +1,24 @@
 package appliers
 
 import (
-	"path/filepath"
-
 	"github.com/onsi/gomega"
 
 	"github.com/stolostron/applier/pkg/applier"
 	"github.com/stolostron/applier/pkg/templateprocessor"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
 )
 
 const (
 	importClusterScenario     = "import"
 	selfImportClusterScenario = "self_import"
 	createClusterScenario     = "create"
+	clusterPoolScenario       = "clusterpool"
+	upgradeScenario           = "upgrade"
+	hypershiftScenario        = "hypershift"
+	placementScenario         = "placement"
+	manifestWorkScenario      = "manifestwork"
 )
 
 type HubAppliers struct {
@@ -22,21 +27,44 @@ type HubAppliers struct {
 	ImportYamlReader        templateprocessor.TemplateReader
 	ImportApplier           *applier.Applier
 	SelfImportApplier       *applier.Applier
+	ClusterPoolApplier      *applier.Applier
+	UpgradeApplier          *applier.Applier
+	HyperShiftApplier       *applier.Applier
+	PlacementApplier        *applier.Applier
+	ManifestWorkApplier     *applier.Applier
 }
 
 func GetHubAppliers(hubClient *clients.HubClients) (hubAppliers *HubAppliers) {
 	var err error
 	hubAppliers = &HubAppliers{}
-	createYamlReader := templateprocessor.NewYamlFileReader(filepath.Join("../resources/hub", createClusterScenario))
+	overrideDir := options.TestOptions.Options.TemplatesOverrideDir
+	// the objects created by the appliers are labeled with the run ID and recorded for the teardown
+	client := ownership.NewClient(hubClient.ClientClient)
+	createYamlReader := NewTemplateReader(createClusterScenario, overrideDir)
 	hubAppliers.CreateTemplateProcessor, err = templateprocessor.NewTemplateProcessor(createYamlReader, &templateprocessor.Options{})
 	gomega.Expect(err).To(gomega.BeNil())
-	hubAppliers.CreateApplier, err = applier.NewApplier(createYamlReader, &templateprocessor.Options{}, hubClient.ClientClient, nil, nil, nil)
+	hubAppliers.CreateApplier, err = applier.NewApplier(createYamlReader, &templateprocessor.Options{}, client, nil, nil, nil)
+	gomega.Expect(err).To(gomega.BeNil())
+	hubAppliers.ImportYamlReader = NewTemplateReader(importClusterScenario, overrideDir)
+	hubAppliers.ImportApplier, err = applier.NewApplier(hubAppliers.ImportYamlReader, &templateprocessor.Options{}, client, nil, nil, nil)
+	gomega.Expect(err).To(gomega.BeNil())
+	selfImportYamlReader := NewTemplateReader(selfImportClusterScenario, overrideDir)
+	hubAppliers.SelfImportApplier, err = applier.NewApplier(selfImportYamlReader, &templateprocessor.Options{}, client, nil, nil, nil)
+	gomega.Expect(err).To(gomega.BeNil())
+	clusterPoolYamlReader := NewTemplateReader(clusterPoolScenario, overrideDir)
+	hubAppliers.ClusterPoolApplier, err = applier.NewApplier(clusterPoolYamlReader, &templateprocessor.Options{}, client, nil, nil, nil)
+	gomega.Expect(err).To(gomega.BeNil())
+	upgradeYamlReader := NewTemplateReader(upgradeScenario, overrideDir)
+	hubAppliers.UpgradeApplier, err = applier.NewApplier(upgradeYamlReader, &templateprocessor.Options{}, client, nil, nil, nil)
+	gomega.Expect(err).To(gomega.BeNil())
+	hypershiftYamlReader := NewTemplateReader(hypershiftScenario, overrideDir)
+	hubAppliers.HyperShiftApplier, err = applier.NewApplier(hypershiftYamlReader, &templateprocessor.Options{}, client, nil, nil, nil)
 	gomega.Expect(err).To(gomega.BeNil())
-	hubAppliers.ImportYamlReader = templateprocessor.NewYamlFileReader(filepath.Join("../resources/hub", importClusterScenario))
-	hubAppliers.ImportApplier, err = applier.NewApplier(hubAppliers.ImportYamlReader, &templateprocessor.Options{}, hubClient.ClientClient, nil, nil, nil)
+	placementYamlReader := NewTemplateReader(placementScenario, overrideDir)
+	hubAppliers.PlacementApplier, err = applier.NewApplier(placementYamlReader, &templateprocessor.Options{}, client, nil, nil, nil)
 	gomega.Expect(err).To(gomega.BeNil())
-	selfImportYamlReader := templateprocessor.NewYamlFileReader(filepath.Join("../resources/hub", selfImportClusterScenario))
-	hubAppliers.SelfImportApplier, err = applier.NewApplier(selfImportYamlReader, &templateprocessor.Options{}, hubClient.ClientClient, nil, nil, nil)
+	manifestWorkYamlReader := NewTemplateReader(manifestWorkScenario, overrideDir)
+	hubAppliers.ManifestWorkApplier, err = applier.NewApplier(manifestWorkYamlReader, &templateprocessor.Options{}, client, nil, nil, nil)
 	gomega.Expect(err).To(gomega.BeNil())
 	return
 }
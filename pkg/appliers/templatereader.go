@@ -0,0 +1,99 @@
+package appliers
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/stolostron/applier/pkg/templateprocessor"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/resources"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+// TemplateReader reads the templates of a scenario embedded in the binary.
+// A template in <overrideDir>/<scenario> replaces the embedded template with the same name,
+// the other templates of the override directory are added to the scenario.
+type TemplateReader struct {
+	scenario    string
+	overrideDir string
+}
+
+var _ templateprocessor.TemplateReader = &TemplateReader{}
+
+// NewTemplateReader creates a TemplateReader
+// scenario: The scenario directory of the templates (ie: create)
+// overrideDir: The directory of the templates overriding the embedded ones, ignored if empty
+func NewTemplateReader(scenario, overrideDir string) *TemplateReader {
+	return &TemplateReader{
+		scenario:    scenario,
+		overrideDir: overrideDir,
+	}
+}
+
+// Asset returns the template name of the scenario
+func (r *TemplateReader) Asset(name string) ([]byte, error) {
+	if r.overrideDir != "" {
+		b, err := os.ReadFile(filepath.Join(r.overrideDir, r.scenario, filepath.FromSlash(name)))
+		if err == nil {
+			klog.V(2).Infof("Template %s/%s read from %s", r.scenario, name, r.overrideDir)
+			return b, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return resources.Hub.ReadFile(path.Join("hub", r.scenario, name))
+}
+
+// AssetNames returns the names of the embedded and overriding templates of the scenario
+func (r *TemplateReader) AssetNames() ([]string, error) {
+	names := map[string]bool{}
+	root := path.Join("hub", r.scenario)
+	err := fs.WalkDir(resources.Hub, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		names[p[len(root)+1:]] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if r.overrideDir != "" {
+		overrideRoot := filepath.Join(r.overrideDir, r.scenario)
+		err := filepath.WalkDir(overrideRoot, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == overrideRoot {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(overrideRoot, p)
+			if err != nil {
+				return err
+			}
+			names[filepath.ToSlash(rel)] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	keys := make([]string, 0, len(names))
+	for name := range names {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// ToJSON converts a yaml template to JSON
+func (*TemplateReader) ToJSON(b []byte) ([]byte, error) {
+	return yaml.YAMLToJSON(b)
+}
@@ -0,0 +1,217 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+// the hub controllers for which the logs are collected, per namespace
+var hubControllers = map[string][]string{
+	"hive":                    {"hive-controllers"},
+	"multicluster-engine":     {"managedcluster-import-controller-v2"},
+	"open-cluster-management": {"klusterlet-addon-controller-v2"},
+}
+
+// the namespaces of the managed cluster where the pod logs are collected
+var agentNamespaces = []string{
+	"open-cluster-management-agent",
+	"open-cluster-management-agent-addon",
+}
+
+// the resources collected in the cluster namespace on the hub
+var clusterNamespaceResources = []schema.GroupVersionResource{
+	{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"},
+	{Group: "hive.openshift.io", Version: "v1", Resource: "clusterprovisions"},
+	{Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks"},
+	{Group: "addon.open-cluster-management.io", Version: "v1alpha1", Resource: "managedclusteraddons"},
+	{Group: "agent.open-cluster-management.io", Version: "v1", Resource: "klusterletaddonconfigs"},
+}
+
+// the number of lines kept from the end of each container log
+var tailLines int64 = 5000
+
+// Clients are the clients to a cluster
+type Clients struct {
+	KubeClient    kubernetes.Interface
+	DynamicClient dynamic.Interface
+}
+
+// Collector dumps the diagnostics of a managed cluster in a directory
+type Collector struct {
+	hub Clients
+	dir string
+}
+
+// NewCollector creates a Collector
+// hub: The clients to the hub
+// dir: The directory where the diagnostics are written, in a sub-directory per cluster
+func NewCollector(hub Clients, dir string) *Collector {
+	return &Collector{
+		hub: hub,
+		dir: dir,
+	}
+}
+
+// Collect dumps in <dir>/<clusterName>:
+// - hub/: the managedCluster and the resources and events of the cluster namespace
+// - hub/logs/: the logs of the hub controllers
+// - managed/: the klusterlet and the logs of the agents if managed is not nil
+// The collection continues on errors, the errors are logged and the last one returned.
+func (c *Collector) Collect(clusterName string, managed *Clients) error {
+	dir := filepath.Join(c.dir, clusterName)
+	klog.V(1).Infof("Cluster %s: Collecting the diagnostics in %s", clusterName, dir)
+	var lastErr error
+	check := func(err error) {
+		if err != nil {
+			klog.Errorf("Cluster %s: diagnostics: %s", clusterName, err)
+			lastErr = err
+		}
+	}
+
+	hubDir := filepath.Join(dir, "hub")
+	check(c.collectObject(c.hub.DynamicClient,
+		schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"},
+		"", clusterName, hubDir))
+	for _, gvr := range clusterNamespaceResources {
+		check(c.collectList(c.hub.DynamicClient, gvr, clusterName, hubDir))
+	}
+	check(c.collectEvents(c.hub.KubeClient, clusterName, hubDir))
+	for ns, deployments := range hubControllers {
+		for _, deployment := range deployments {
+			check(c.collectDeploymentLogs(c.hub.KubeClient, ns, deployment, filepath.Join(hubDir, "logs")))
+		}
+	}
+
+	if managed != nil {
+		managedDir := filepath.Join(dir, "managed")
+		check(c.collectObject(managed.DynamicClient,
+			schema.GroupVersionResource{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "klusterlets"},
+			"", "klusterlet", managedDir))
+		for _, ns := range agentNamespaces {
+			check(c.collectEvents(managed.KubeClient, ns, managedDir))
+			check(c.collectNamespaceLogs(managed.KubeClient, ns, filepath.Join(managedDir, "logs")))
+		}
+	}
+	return lastErr
+}
+
+func (c *Collector) collectObject(client dynamic.Interface, gvr schema.GroupVersionResource, ns, name, dir string) error {
+	u, err := client.Resource(gvr).Namespace(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("%s %s: %v", gvr.Resource, name, err)
+	}
+	return writeYAML(filepath.Join(dir, gvr.Resource+".yaml"), u.Object)
+}
+
+func (c *Collector) collectList(client dynamic.Interface, gvr schema.GroupVersionResource, ns, dir string) error {
+	l, err := client.Resource(gvr).Namespace(ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("%s: %v", gvr.Resource, err)
+	}
+	if len(l.Items) == 0 {
+		return nil
+	}
+	return writeYAML(filepath.Join(dir, gvr.Resource+".yaml"), l.UnstructuredContent())
+}
+
+func (c *Collector) collectEvents(client kubernetes.Interface, ns, dir string) error {
+	events, err := client.CoreV1().Events(ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("events in %s: %v", ns, err)
+	}
+	if len(events.Items) == 0 {
+		return nil
+	}
+	return writeYAML(filepath.Join(dir, fmt.Sprintf("events-%s.yaml", ns)), events)
+}
+
+func (c *Collector) collectDeploymentLogs(client kubernetes.Interface, ns, name, dir string) error {
+	deployment, err := client.AppsV1().Deployments(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("deployment %s/%s: %v", ns, name, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	pods, err := client.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return fmt.Errorf("pods of deployment %s/%s: %v", ns, name, err)
+	}
+	return collectPodsLogs(client, pods.Items, dir)
+}
+
+func (c *Collector) collectNamespaceLogs(client kubernetes.Interface, ns, dir string) error {
+	pods, err := client.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("pods in %s: %v", ns, err)
+	}
+	return collectPodsLogs(client, pods.Items, dir)
+}
+
+func collectPodsLogs(client kubernetes.Interface, pods []corev1.Pod, dir string) error {
+	var lastErr error
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			path := filepath.Join(dir, pod.Namespace, fmt.Sprintf("%s-%s.log", pod.Name, container.Name))
+			if err := collectLogs(client, pod.Namespace, pod.Name, container.Name, path); err != nil {
+				klog.Errorf("logs of %s/%s %s: %s", pod.Namespace, pod.Name, container.Name, err)
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+func collectLogs(client kubernetes.Interface, ns, pod, container, path string) error {
+	stream, err := client.CoreV1().Pods(ns).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		TailLines: &tailLines,
+	}).Stream(context.TODO())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, stream)
+	return err
+}
+
+func writeYAML(path string, obj interface{}) error {
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
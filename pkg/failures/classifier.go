@@ -0,0 +1,204 @@
+package failures
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed rules.yaml
+var defaultRules []byte
+
+// Rule maps a failure reason and message to a failure category.
+type Rule struct {
+	//The name of the rule
+	Name string `json:"name"`
+	//The scope where the rule applies
+	Scope string `json:"scope"`
+	//A regular expression matching the reason, empty matches all reasons
+	Reason string `json:"reason,omitempty"`
+	//A regular expression matching the message, empty matches all messages
+	Message string `json:"message,omitempty"`
+	//The tag of the failure category
+	Tag string `json:"tag"`
+	//The link to the failure category analysis and remediation
+	Link string `json:"link,omitempty"`
+	//The severity of the failure
+	Severity string `json:"severity,omitempty"`
+	//The name of the rule of the rules file giving its tag, link and severity to a registered rule,
+	//so the failure categories are only defined in the rules file
+	From string `json:"-"`
+
+	reasonRegexp  *regexp.Regexp
+	messageRegexp *regexp.Regexp
+}
+
+type rulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Classifier classifies the failures using rules.
+type Classifier struct {
+	rules []Rule
+}
+
+// NewClassifier creates a classifier from a rules file content.
+func NewClassifier(b []byte) (*Classifier, error) {
+	rf := &rulesFile{}
+	if err := yaml.Unmarshal(b, rf); err != nil {
+		return nil, err
+	}
+	if err := compile(rf.Rules); err != nil {
+		return nil, err
+	}
+	return &Classifier{rules: rf.Rules}, nil
+}
+
+// compile validates the rules and compiles their regular expressions
+func compile(rules []Rule) error {
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" || r.Scope == "" {
+			return fmt.Errorf("rule %d: name and scope are required", i)
+		}
+		var err error
+		if r.reasonRegexp, err = regexp.Compile(r.Reason); err != nil {
+			return fmt.Errorf("rule %s: invalid reason: %v", r.Name, err)
+		}
+		if r.messageRegexp, err = regexp.Compile(r.Message); err != nil {
+			return fmt.Errorf("rule %s: invalid message: %v", r.Name, err)
+		}
+		switch r.Severity {
+		case "", SeverityLow, SeverityMedium, SeverityHigh:
+		default:
+			return fmt.Errorf("rule %s: invalid severity %s", r.Name, r.Severity)
+		}
+	}
+	return nil
+}
+
+// Classify returns the failure for the reason and message which occurred in the scope.
+// If no rule matches, the failure is untagged.
+func (c *Classifier) Classify(scope, reason, message string) *Failure {
+	f := &Failure{
+		Scope:   scope,
+		Reason:  reason,
+		Message: message,
+	}
+	for _, r := range c.rules {
+		if r.Scope != scope || !r.reasonRegexp.MatchString(reason) || !r.messageRegexp.MatchString(message) {
+			continue
+		}
+		f.Rule = r.Name
+		f.Tag = r.Tag
+		f.Link = r.Link
+		f.Severity = r.Severity
+		break
+	}
+	return f
+}
+
+// Rules returns the rules of the classifier.
+func (c *Classifier) Rules() []Rule {
+	return c.rules
+}
+
+var (
+	rulesFilePath   string
+	registeredRules []Rule
+
+	defaultClassifier     *Classifier
+	defaultClassifierErr  error
+	defaultClassifierOnce sync.Once
+)
+
+// InitFlags adds the flags of the failure classification
+func InitFlags(flagset *flag.FlagSet) {
+	if flagset == nil {
+		flagset = flag.CommandLine
+	}
+	flagset.StringVar(&rulesFilePath, "failure-rules", "",
+		"Location of a rules file to classify the failures.\n"+
+			"If not present the embedded pkg/failures/rules.yaml is used.")
+}
+
+// RegisterRules registers rules evaluated before the rules of the rules file,
+// for the failures known by a package (ie: the quota errors of a cloud provider).
+// A registered rule takes the tag, link and severity of the rule of the rules file named by its From.
+// It must be called before the first classification, usually from an init function.
+func RegisterRules(rules ...Rule) {
+	registeredRules = append(registeredRules, rules...)
+}
+
+// Load loads the default classifier from the -failure-rules file or from the embedded rules,
+// preceded by the registered rules. It returns an error if the rules file can not be read,
+// or if its rules or the registered rules are invalid.
+func Load() error {
+	defaultClassifierOnce.Do(func() {
+		defaultClassifier, defaultClassifierErr = load()
+	})
+	return defaultClassifierErr
+}
+
+func load() (*Classifier, error) {
+	b := defaultRules
+	if rulesFilePath != "" {
+		var err error
+		b, err = ioutil.ReadFile(rulesFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the failure rules %s: %v", rulesFilePath, err)
+		}
+	}
+	c, err := NewClassifier(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid failure rules: %v", err)
+	}
+	rules := append([]Rule{}, registeredRules...)
+	for i := range rules {
+		r := &rules[i]
+		if r.From == "" {
+			continue
+		}
+		from := c.rule(r.From)
+		if from == nil {
+			return nil, fmt.Errorf("invalid registered failure rules: rule %s: no rule %s in the failure rules", r.Name, r.From)
+		}
+		r.Tag = from.Tag
+		r.Link = from.Link
+		r.Severity = from.Severity
+	}
+	if err := compile(rules); err != nil {
+		return nil, fmt.Errorf("invalid registered failure rules: %v", err)
+	}
+	c.rules = append(rules, c.rules...)
+	return c, nil
+}
+
+// rule returns the rule with the name or nil
+func (c *Classifier) rule(name string) *Rule {
+	for i := range c.rules {
+		if c.rules[i].Name == name {
+			return &c.rules[i]
+		}
+	}
+	return nil
+}
+
+// Default returns the default classifier, it panics if it can not be loaded.
+// Load should be called first to report the error.
+func Default() *Classifier {
+	if err := Load(); err != nil {
+		panic(err)
+	}
+	return defaultClassifier
+}
+
+// Classify classifies the failure with the default classifier.
+func Classify(scope, reason, message string) *Failure {
+	return Default().Classify(scope, reason, message)
+}
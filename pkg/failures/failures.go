@@ -0,0 +1,102 @@
+package failures
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// The scopes of the failures, a scope is the step of the scenario where the failure occurred.
+const (
+	ScopeProvision    = "provision"
+	ScopeImport       = "import"
+	ScopeAddon        = "addon"
+	ScopeDetach       = "detach"
+	ScopeDestroy      = "destroy"
+	ScopeHibernation  = "hibernation"
+	ScopeClusterPool  = "clusterpool"
+	ScopeUpgrade      = "upgrade"
+	ScopeHyperShift   = "hypershift"
+	ScopeScale        = "scale"
+	ScopePlacement    = "placement"
+	ScopeManifestWork = "manifestwork"
+)
+
+// The severities of the failures
+const (
+	//The failure is a known issue, the e2e can be rerun
+	SeverityLow = "low"
+	//The failure is caused by the infrastructure (quota, cloud provider...)
+	SeverityMedium = "medium"
+	//The failure must be investigated
+	SeverityHigh = "high"
+)
+
+// Failure is a classified e2e failure.
+type Failure struct {
+	//The scope where the failure occurred
+	Scope string `json:"scope,omitempty"`
+	//The name of the rule which classified the failure
+	Rule string `json:"rule,omitempty"`
+	//The tag of the failure category (ie: [quota limit])
+	Tag string `json:"tag"`
+	//The link to the failure category analysis and remediation
+	Link string `json:"link,omitempty"`
+	//The severity of the failure
+	Severity string `json:"severity,omitempty"`
+	//The reason of the failure
+	Reason string `json:"reason"`
+	//The message of the failure
+	Message string `json:"message"`
+}
+
+// Error returns the failure in the format expected by the e2e result analysis.
+func (f *Failure) Error() string {
+	return fmt.Sprintf("Tag: %v, "+
+		"Possible Solution: %v, "+
+		"Reason: %v, "+
+		"Error message: %v,",
+		f.Tag, f.Link, f.Reason, f.Message)
+}
+
+// GomegaString returns the failure message,
+// so the failed assertions print the failure in the format expected by the e2e result analysis.
+func (f *Failure) GomegaString() string {
+	return f.Error()
+}
+
+var failureMsgRegexp = regexp.MustCompile(`(?s)Tag: (.*?), Possible Solution: (.*?), Reason: (.*?), Error message: (.*),`)
+
+// Parse extracts a failure from a message containing a failure error,
+// for example a ginkgo failure message.
+// Only the tag, link, reason and message are available.
+func Parse(msg string) (*Failure, bool) {
+	m := failureMsgRegexp.FindStringSubmatch(msg)
+	if m == nil {
+		return nil, false
+	}
+	return &Failure{
+		Tag:     m[1],
+		Link:    m[2],
+		Reason:  m[3],
+		Message: m[4],
+	}, true
+}
+
+// As returns the failure wrapped in err if any.
+func As(err error) (*Failure, bool) {
+	var f *Failure
+	if errors.As(err, &f) {
+		return f, true
+	}
+	return nil, false
+}
+
+// Aggregate counts the failures per tag, the untagged failures are counted under "".
+func Aggregate(failures []*Failure) map[string]int {
+	counts := make(map[string]int)
+	for _, f := range failures {
+		counts[f.Tag]++
+	}
+	return counts
+}
@@ -0,0 +1,114 @@
+package fakehub
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// the CRDs installed on the managed clusters
+var managedClusterCRDs = map[string]bool{
+	"klusterlets.operator.open-cluster-management.io":  true,
+	"clusterclaims.cluster.open-cluster-management.io": true,
+}
+
+// cluster is an API server of the fake hub: the hub itself or the managed cluster
+// of a cluster imported or provisioned on the fake hub, where its klusterlet and agents run.
+type cluster struct {
+	kubeClient      kubernetes.Interface
+	dynamicClient   dynamic.Interface
+	discoveryClient *discovery.DiscoveryClient
+	//The mapper of the kinds of the manifests applied by the work agent
+	mapper *restmapper.DeferredDiscoveryRESTMapper
+	//The admin kubeconfig of the cluster
+	kubeConfig []byte
+	//The API server of a managed cluster, nil for the hub which is stopped by the Hub
+	env *envtest.Environment
+}
+
+// newCluster returns the clients of the cluster accessed with the rest config.
+func newCluster(restConfig *rest.Config, kubeConfig []byte) (*cluster, error) {
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &cluster{
+		kubeClient:      kubeClient,
+		dynamicClient:   dynamicClient,
+		discoveryClient: discoveryClient,
+		mapper:          restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+		kubeConfig:      kubeConfig,
+	}, nil
+}
+
+// startManagedCluster starts the API server of a managed cluster with the klusterlet and clusterClaim CRDs installed,
+// so each cluster has its own klusterlet, agent namespaces and manifests applied by its work agent.
+func startManagedCluster(name string) (*cluster, error) {
+	crds, err := CRDs()
+	if err != nil {
+		return nil, err
+	}
+	installed := make([]*apiextensionsv1.CustomResourceDefinition, 0)
+	for _, crd := range crds {
+		if managedClusterCRDs[crd.Name] {
+			installed = append(installed, crd)
+		}
+	}
+	env := &envtest.Environment{
+		CRDs: installed,
+	}
+	if _, err := env.Start(); err != nil {
+		return nil, err
+	}
+	user, err := env.AddUser(envtest.User{Name: name + "-admin", Groups: []string{"system:masters"}}, nil)
+	if err != nil {
+		env.Stop()
+		return nil, err
+	}
+	kubeConfig, err := user.KubeConfig()
+	if err != nil {
+		env.Stop()
+		return nil, err
+	}
+	c, err := newCluster(user.Config(), kubeConfig)
+	if err != nil {
+		env.Stop()
+		return nil, err
+	}
+	c.env = env
+	return c, nil
+}
+
+// stop stops the API server of a managed cluster.
+func (c *cluster) stop() error {
+	if c.env == nil {
+		return nil
+	}
+	return c.env.Stop()
+}
+
+// mapping returns the resource of the kind, the discovery is refreshed for the kinds not found.
+func (c *cluster) mapping(apiVersion, kind string) (*meta.RESTMapping, error) {
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		c.mapper.Reset()
+		mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	return mapping, err
+}
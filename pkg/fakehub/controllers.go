@@ -0,0 +1,870 @@
+package fakehub
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+)
+
+const (
+	reconcileInterval = time.Second
+
+	klusterletName                           = "klusterlet"
+	openClusterManagementAgentNamespace      = "open-cluster-management-agent"
+	openClusterManagementAgentAddonNamespace = "open-cluster-management-agent-addon"
+	localClusterName                         = "local-cluster"
+
+	powerStateRunning     = "Running"
+	powerStateHibernating = "Hibernating"
+)
+
+var (
+	gvrManagedCluster         = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
+	gvrClusterDeployment      = schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
+	gvrClusterImageSet        = schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterimagesets"}
+	gvrManifestWork           = schema.GroupVersionResource{Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks"}
+	gvrManagedClusterAddOn    = schema.GroupVersionResource{Group: "addon.open-cluster-management.io", Version: "v1alpha1", Resource: "managedclusteraddons"}
+	gvrClusterManagementAddOn = schema.GroupVersionResource{Group: "addon.open-cluster-management.io", Version: "v1alpha1", Resource: "clustermanagementaddons"}
+	gvrKlusterletAddonConfig  = schema.GroupVersionResource{Group: "agent.open-cluster-management.io", Version: "v1", Resource: "klusterletaddonconfigs"}
+	gvrKlusterlet             = schema.GroupVersionResource{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "klusterlets"}
+)
+
+// the hub deployments checked by the suites as minimal requirements
+var hubDeployments = map[string][]string{
+	"multicluster-engine":         {"managedcluster-import-controller-v2"},
+	"open-cluster-management":     {"klusterlet-addon-controller-v2"},
+	"open-cluster-management-hub": {"cluster-manager-registration-controller"},
+	"hive":                        {"hive-controllers"},
+}
+
+// the managedClusterAddOn created for each enabled addon in the klusterletAddonConfig
+var klusterletAddonConfigAddOns = map[string][]string{
+	"applicationManager":   {"application-manager"},
+	"policyController":     {"governance-policy-framework", "config-policy-controller"},
+	"searchCollector":      {"search-collector"},
+	"certPolicyController": {"cert-policy-controller"},
+	"iamPolicyController":  {"iam-policy-controller"},
+}
+
+// controllers simulates the hub controllers (hive, registration, import,
+// work and addon) and the managed cluster agents.
+// Each cluster it provisions or imports has its own managed cluster API server,
+// the fake hub is the managed cluster of the local-cluster.
+type controllers struct {
+	//The fake hub
+	*cluster
+	//The managed clusters by cluster name
+	managedClusters map[string]*cluster
+	//The CA signing the approved CSRs
+	signer *signer
+	//The managedClusters seen by the controllers
+	seen map[string]bool
+}
+
+// seed creates the resources a real hub has after the installation.
+func (c *controllers) seed() error {
+	for ns, names := range hubDeployments {
+		if err := c.ensureNamespace(ns); err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err := c.ensureDeployment(ns, name); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.ensureNamespace("openshift-config"); err != nil {
+		return err
+	}
+	_, err := c.kubeClient.CoreV1().Secrets("openshift-config").Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pull-secret",
+			Namespace: "openshift-config",
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	if err := c.create(gvrClusterImageSet, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "hive.openshift.io/v1",
+		"kind":       "ClusterImageSet",
+		"metadata": map[string]interface{}{
+			"name": "img4.11.0-x86-64-appsub",
+		},
+		"spec": map[string]interface{}{
+			"releaseImage": "quay.io/openshift-release-dev/ocp-release:4.11.0-x86_64",
+		},
+	}}); err != nil {
+		return err
+	}
+
+	// the clusterManagementAddOns of the add-ons the fake hub deploys
+	addOns := []string{"work-manager"}
+	for _, names := range klusterletAddonConfigAddOns {
+		addOns = append(addOns, names...)
+	}
+	for _, addOnName := range addOns {
+		if err := c.create(gvrClusterManagementAddOn, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "addon.open-cluster-management.io/v1alpha1",
+			"kind":       "ClusterManagementAddOn",
+			"metadata": map[string]interface{}{
+				"name": addOnName,
+			},
+			"spec": map[string]interface{}{
+				"addOnMeta": map[string]interface{}{
+					"displayName": addOnName,
+				},
+			},
+		}}); err != nil {
+			return err
+		}
+	}
+
+	// the hub is self-imported as local-cluster
+	if err := c.ensureNamespace(localClusterName); err != nil {
+		return err
+	}
+	if err := c.create(gvrManagedCluster, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cluster.open-cluster-management.io/v1",
+		"kind":       "ManagedCluster",
+		"metadata": map[string]interface{}{
+			"name": localClusterName,
+			"labels": map[string]interface{}{
+				"local-cluster": "true",
+				"cloud":         "auto-detect",
+				"vendor":        "auto-detect",
+			},
+		},
+		"spec": map[string]interface{}{
+			"hubAcceptsClient": true,
+		},
+	}}); err != nil {
+		return err
+	}
+	if err := c.create(gvrKlusterletAddonConfig, newKlusterletAddonConfig(localClusterName)); err != nil {
+		return err
+	}
+	return c.deployKlusterlet(localClusterName)
+}
+
+// run reconciles every reconcileInterval until the context is done.
+func (c *controllers) run(ctx context.Context) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for name, reconcile := range map[string]func() error{
+				"namespace":         c.reconcileNamespaces,
+				"clusterdeployment": c.reconcileClusterDeployments,
+				"managedcluster":    c.reconcileManagedClusters,
+				"csr":               c.reconcileCertificateSigningRequests,
+				"manifestwork":      c.reconcileManifestWorks,
+				"placement":         c.reconcilePlacements,
+			} {
+				if err := reconcile(); err != nil {
+					klog.V(2).Infof("fake hub %s controller: %s", name, err)
+				}
+			}
+			for name, mc := range c.managedClusters {
+				if err := mc.reconcileNamespaces(); err != nil {
+					klog.V(2).Infof("fake hub managed cluster %s namespace controller: %s", name, err)
+				}
+			}
+		}
+	}
+}
+
+// reconcileNamespaces simulates the namespace controller which doesn't run in the fake hub.
+// It deletes the content of the terminating namespaces and then finalizes them.
+func (c *cluster) reconcileNamespaces() error {
+	namespaces, err := c.kubeClient.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range namespaces.Items {
+		ns := &namespaces.Items[i]
+		if ns.DeletionTimestamp == nil {
+			continue
+		}
+		empty, err := c.deleteNamespaceContent(ns.Name)
+		if err != nil || !empty {
+			return err
+		}
+		ns.Spec.Finalizers = nil
+		if _, err := c.kubeClient.CoreV1().Namespaces().Finalize(context.TODO(), ns, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		klog.V(2).Infof("fake hub: namespace %s finalized", ns.Name)
+	}
+	return nil
+}
+
+func (c *cluster) deleteNamespaceContent(ns string) (empty bool, err error) {
+	apiResourceLists, err := c.discoveryClient.ServerPreferredNamespacedResources()
+	if err != nil {
+		return false, err
+	}
+	empty = true
+	for _, apiResourceList := range apiResourceLists {
+		gv, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
+		if err != nil {
+			return false, err
+		}
+		for _, apiResource := range apiResourceList.APIResources {
+			if !hasVerb(apiResource.Verbs, "list") || !hasVerb(apiResource.Verbs, "delete") {
+				continue
+			}
+			client := c.dynamicClient.Resource(gv.WithResource(apiResource.Name)).Namespace(ns)
+			us, err := client.List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				continue
+			}
+			for _, u := range us.Items {
+				empty = false
+				if len(u.GetFinalizers()) != 0 {
+					u.SetFinalizers(nil)
+					if _, err := client.Update(context.TODO(), &u, metav1.UpdateOptions{}); err != nil && !errors.IsNotFound(err) {
+						return false, err
+					}
+				}
+				if err := client.Delete(context.TODO(), u.GetName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+					return false, err
+				}
+			}
+		}
+	}
+	return empty, nil
+}
+
+// reconcileClusterDeployments simulates hive, the clusterDeployments are installed immediately.
+func (c *controllers) reconcileClusterDeployments() error {
+	cds, err := c.dynamicClient.Resource(gvrClusterDeployment).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range cds.Items {
+		cd := &cds.Items[i]
+		if cd.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if installed, _, _ := unstructured.NestedString(cd.Object, "status", "installedTimestamp"); installed != "" {
+			if err := c.reconcilePowerState(cd); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.installClusterDeployment(cd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *controllers) installClusterDeployment(cd *unstructured.Unstructured) error {
+	name, ns := cd.GetName(), cd.GetNamespace()
+	managedCluster, err := c.startManagedCluster(name)
+	if err != nil {
+		return err
+	}
+	adminKubeconfigSecretName := name + "-admin-kubeconfig"
+	_, err = c.kubeClient.CoreV1().Secrets(ns).Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      adminKubeconfigSecretName,
+			Namespace: ns,
+		},
+		Data: map[string][]byte{
+			"kubeconfig": managedCluster.kubeConfig,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	if err := unstructured.SetNestedField(cd.Object, true, "spec", "installed"); err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedMap(cd.Object, map[string]interface{}{
+		"clusterID": string(cd.GetUID()),
+		"infraID":   name + "-fake",
+		"adminKubeconfigSecretRef": map[string]interface{}{
+			"name": adminKubeconfigSecretName,
+		},
+	}, "spec", "clusterMetadata"); err != nil {
+		return err
+	}
+	cd, err = c.dynamicClient.Resource(gvrClusterDeployment).Namespace(ns).Update(context.TODO(), cd, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedField(cd.Object, metav1.Now().UTC().Format(time.RFC3339), "status", "installedTimestamp"); err != nil {
+		return err
+	}
+	if _, err := c.dynamicClient.Resource(gvrClusterDeployment).Namespace(ns).UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	klog.V(2).Infof("fake hub: clusterDeployment %s installed", name)
+	// the managedCluster is auto-imported
+	return c.deployKlusterlet(name)
+}
+
+// reconcilePowerState reports the requested powerState in the status immediately.
+func (c *controllers) reconcilePowerState(cd *unstructured.Unstructured) error {
+	powerState, _, _ := unstructured.NestedString(cd.Object, "spec", "powerState")
+	if powerState == "" {
+		powerState = powerStateRunning
+	}
+	if current, _, _ := unstructured.NestedString(cd.Object, "status", "powerState"); current == powerState {
+		return nil
+	}
+	if err := unstructured.SetNestedField(cd.Object, powerState, "status", "powerState"); err != nil {
+		return err
+	}
+	_, err := c.dynamicClient.Resource(gvrClusterDeployment).Namespace(cd.GetNamespace()).UpdateStatus(context.TODO(), cd, metav1.UpdateOptions{})
+	if err == nil {
+		klog.V(2).Infof("fake hub: clusterDeployment %s %s", cd.GetName(), powerState)
+	}
+	return err
+}
+
+// isHibernating returns true if the clusterDeployment of the cluster is hibernating.
+func (c *controllers) isHibernating(clusterName string) (bool, error) {
+	cd, err := c.dynamicClient.Resource(gvrClusterDeployment).Namespace(clusterName).Get(context.TODO(), clusterName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	powerState, _, _ := unstructured.NestedString(cd.Object, "status", "powerState")
+	return powerState == powerStateHibernating, nil
+}
+
+// reconcileManagedClusters simulates the import, registration, work and addon controllers.
+func (c *controllers) reconcileManagedClusters() error {
+	mcs, err := c.dynamicClient.Resource(gvrManagedCluster).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	current := map[string]bool{}
+	for i := range mcs.Items {
+		mc := &mcs.Items[i]
+		current[mc.GetName()] = true
+		c.seen[mc.GetName()] = true
+		if err := c.reconcileManagedCluster(mc); err != nil {
+			return err
+		}
+	}
+	for name := range c.seen {
+		if current[name] {
+			continue
+		}
+		if err := c.detach(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *controllers) reconcileManagedCluster(mc *unstructured.Unstructured) error {
+	name := mc.GetName()
+	if _, err := c.kubeClient.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := c.ensureImportSecret(name); err != nil {
+		return err
+	}
+	hibernating, err := c.isHibernating(name)
+	if err != nil {
+		return err
+	}
+	if hibernating {
+		// the klusterlet doesn't update its lease anymore
+		if isAvailable(mc) {
+			return c.setManagedClusterConditions(mc, metav1.ConditionUnknown)
+		}
+		return nil
+	}
+	if !isAvailable(mc) {
+		if err := c.acceptManagedCluster(mc); err != nil {
+			return err
+		}
+		klusterlet, err := c.managedCluster(name).dynamicClient.Resource(gvrKlusterlet).Get(context.TODO(), klusterletName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if clusterName, _, _ := unstructured.NestedString(klusterlet.Object, "spec", "clusterName"); clusterName != name {
+			return nil
+		}
+		if err := c.setManagedClusterConditions(mc, metav1.ConditionTrue); err != nil {
+			return err
+		}
+	}
+	for _, mwName := range []string{name + "-klusterlet-crds", name + "-klusterlet"} {
+		if err := c.ensureAppliedManifestWork(name, mwName); err != nil {
+			return err
+		}
+	}
+	return c.ensureAddOns(name)
+}
+
+// acceptManagedCluster sets the managedCluster accepted if the hub accepts the client, as the registration
+// controller does. The agents simulated by the scale tests wait for it to join the cluster.
+func (c *controllers) acceptManagedCluster(mc *unstructured.Unstructured) error {
+	if accepts, _, _ := unstructured.NestedBool(mc.Object, "spec", "hubAcceptsClient"); !accepts || hasConditions(mc, "HubAcceptedManagedCluster") {
+		return nil
+	}
+	conditions, _, _ := unstructured.NestedSlice(mc.Object, "status", "conditions")
+	conditions = append(conditions, map[string]interface{}{
+		"type":               "HubAcceptedManagedCluster",
+		"status":             string(metav1.ConditionTrue),
+		"reason":             "HubClusterAdminAccepted",
+		"message":            "set by the fake hub",
+		"lastTransitionTime": metav1.Now().UTC().Format(time.RFC3339),
+	})
+	if err := unstructured.SetNestedSlice(mc.Object, conditions, "status", "conditions"); err != nil {
+		return err
+	}
+	updated, err := c.dynamicClient.Resource(gvrManagedCluster).UpdateStatus(context.TODO(), mc, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	updated.DeepCopyInto(mc)
+	klog.V(2).Infof("fake hub: managedCluster %s accepted", mc.GetName())
+	return nil
+}
+
+// setManagedClusterConditions sets the managedCluster accepted and joined
+// and its availability to the available status.
+func (c *controllers) setManagedClusterConditions(mc *unstructured.Unstructured, available metav1.ConditionStatus) error {
+	now := metav1.Now().UTC().Format(time.RFC3339)
+	conditions := make([]interface{}, 0)
+	for _, t := range []string{"HubAcceptedManagedCluster", "ManagedClusterJoined", "ManagedClusterConditionAvailable"} {
+		status := metav1.ConditionTrue
+		if t == "ManagedClusterConditionAvailable" {
+			status = available
+		}
+		conditions = append(conditions, map[string]interface{}{
+			"type":               t,
+			"status":             string(status),
+			"reason":             t,
+			"message":            "set by the fake hub",
+			"lastTransitionTime": now,
+		})
+	}
+	if err := unstructured.SetNestedSlice(mc.Object, conditions, "status", "conditions"); err != nil {
+		return err
+	}
+	_, err := c.dynamicClient.Resource(gvrManagedCluster).UpdateStatus(context.TODO(), mc, metav1.UpdateOptions{})
+	if err == nil {
+		klog.V(2).Infof("fake hub: managedCluster %s available %s", mc.GetName(), available)
+	}
+	return err
+}
+
+// ensureImportSecret creates the import secret the same way the import controller does,
+// the import.yaml deploys a klusterlet registering the cluster on the fake hub.
+func (c *controllers) ensureImportSecret(clusterName string) error {
+	crd, err := crdFS.ReadFile(filepath.Join("crds", "klusterlets.operator.open-cluster-management.io.yaml"))
+	if err != nil {
+		return err
+	}
+	importYAML := fmt.Sprintf(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: %s
+---
+apiVersion: operator.open-cluster-management.io/v1
+kind: Klusterlet
+metadata:
+  name: %s
+spec:
+  clusterName: %s
+`, openClusterManagementAgentNamespace, klusterletName, clusterName)
+	_, err = c.kubeClient.CoreV1().Secrets(clusterName).Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      clusterName + "-import",
+			Namespace: clusterName,
+		},
+		Data: map[string][]byte{
+			"crdsv1.yaml":      crd,
+			"crdsv1beta1.yaml": crd,
+			"import.yaml":      []byte(importYAML),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *controllers) ensureAppliedManifestWork(ns, name string) error {
+	mw := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "work.open-cluster-management.io/v1",
+		"kind":       "ManifestWork",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": ns,
+		},
+		"spec": map[string]interface{}{
+			"workload": map[string]interface{}{},
+		},
+	}}
+	return c.ensureWithConditions(gvrManifestWork, mw, "Applied", "Available")
+}
+
+// ensureAddOns creates the managedClusterAddOns for each addon enabled in the klusterletAddonConfig,
+// deletes those of the disabled addons and deploys the addon agent namespace on the managed cluster.
+func (c *controllers) ensureAddOns(clusterName string) error {
+	kac, err := c.dynamicClient.Resource(gvrKlusterletAddonConfig).Namespace(clusterName).Get(context.TODO(), clusterName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := c.managedCluster(clusterName).ensureNamespace(openClusterManagementAgentAddonNamespace); err != nil {
+		return err
+	}
+	addOns := []string{"work-manager"}
+	for field, names := range klusterletAddonConfigAddOns {
+		if enabled, _, _ := unstructured.NestedBool(kac.Object, "spec", field, "enabled"); enabled {
+			addOns = append(addOns, names...)
+			continue
+		}
+		// the addons disabled in the klusterletAddonConfig are removed with their manifestWork
+		for _, addOnName := range names {
+			err := c.dynamicClient.Resource(gvrManagedClusterAddOn).Namespace(clusterName).Delete(context.TODO(), addOnName, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			err = c.dynamicClient.Resource(gvrManifestWork).Namespace(clusterName).Delete(context.TODO(), addOnManifestWorkName(addOnName), metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	for _, addOnName := range addOns {
+		addOn := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "addon.open-cluster-management.io/v1alpha1",
+			"kind":       "ManagedClusterAddOn",
+			"metadata": map[string]interface{}{
+				"name":      addOnName,
+				"namespace": clusterName,
+			},
+			"spec": map[string]interface{}{
+				"installNamespace": openClusterManagementAgentAddonNamespace,
+			},
+		}}
+		if err := c.ensureWithConditions(gvrManagedClusterAddOn, addOn, "Available"); err != nil {
+			return err
+		}
+		// the addon agent is deployed by a manifestWork applied by the work agent of the managed cluster
+		if _, ok := c.managedClusters[clusterName]; ok {
+			if err := c.create(gvrManifestWork, newAddOnManifestWork(clusterName, addOnName)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func addOnManifestWorkName(addOnName string) string {
+	return "addon-" + addOnName + "-deploy"
+}
+
+// newAddOnManifestWork returns the manifestWork deploying the agent of the addon, labeled as the addon framework does.
+func newAddOnManifestWork(clusterName, addOnName string) *unstructured.Unstructured {
+	labels := map[string]interface{}{"app": addOnName}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "work.open-cluster-management.io/v1",
+		"kind":       "ManifestWork",
+		"metadata": map[string]interface{}{
+			"name":      addOnManifestWorkName(addOnName),
+			"namespace": clusterName,
+			"labels": map[string]interface{}{
+				"open-cluster-management.io/addon-name": addOnName,
+			},
+		},
+		"spec": map[string]interface{}{
+			"workload": map[string]interface{}{
+				"manifests": []interface{}{
+					map[string]interface{}{
+						"apiVersion": "apps/v1",
+						"kind":       "Deployment",
+						"metadata": map[string]interface{}{
+							"name":      addOnName,
+							"namespace": openClusterManagementAgentAddonNamespace,
+						},
+						"spec": map[string]interface{}{
+							"replicas": int64(1),
+							"selector": map[string]interface{}{"matchLabels": labels},
+							"template": map[string]interface{}{
+								"metadata": map[string]interface{}{"labels": labels},
+								"spec": map[string]interface{}{
+									"containers": []interface{}{
+										map[string]interface{}{"name": addOnName, "image": "fake-hub/" + addOnName},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+}
+
+// detach simulates the cleanup done by the import controller when a managedCluster is deleted.
+// The klusterlet is removed from the managed cluster and the cluster namespace is deleted
+// once there is no more clusterDeployment in it.
+func (c *controllers) detach(clusterName string) error {
+	managedCluster := c.managedCluster(clusterName)
+	klusterlet, err := managedCluster.dynamicClient.Resource(gvrKlusterlet).Get(context.TODO(), klusterletName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		if name, _, _ := unstructured.NestedString(klusterlet.Object, "spec", "clusterName"); name == clusterName {
+			if err := managedCluster.dynamicClient.Resource(gvrKlusterlet).Delete(context.TODO(), klusterletName, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			for _, ns := range []string{openClusterManagementAgentAddonNamespace, openClusterManagementAgentNamespace} {
+				if err := managedCluster.kubeClient.CoreV1().Namespaces().Delete(context.TODO(), ns, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+					return err
+				}
+			}
+			klog.V(2).Infof("fake hub: klusterlet of %s removed", clusterName)
+		}
+	}
+	cds, err := c.dynamicClient.Resource(gvrClusterDeployment).Namespace(clusterName).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(cds.Items) != 0 {
+		return nil
+	}
+	if err := c.kubeClient.CoreV1().Namespaces().Delete(context.TODO(), clusterName, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	delete(c.seen, clusterName)
+	klog.V(2).Infof("fake hub: managedCluster %s detached", clusterName)
+	return nil
+}
+
+// startManagedCluster starts the API server of the managed cluster of the clusterName
+// if it is not already running.
+func (c *controllers) startManagedCluster(clusterName string) (*cluster, error) {
+	if managedCluster, ok := c.managedClusters[clusterName]; ok {
+		return managedCluster, nil
+	}
+	klog.V(1).Infof("fake hub: starting the managed cluster %s", clusterName)
+	managedCluster, err := startManagedCluster(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	c.managedClusters[clusterName] = managedCluster
+	return managedCluster, nil
+}
+
+// managedCluster returns the managed cluster where the klusterlet of the clusterName runs,
+// the fake hub for the local-cluster and the clusters without their own API server.
+func (c *controllers) managedCluster(clusterName string) *cluster {
+	if managedCluster, ok := c.managedClusters[clusterName]; ok {
+		return managedCluster
+	}
+	return c.cluster
+}
+
+// stopManagedClusters stops the API servers of the managed clusters.
+func (c *controllers) stopManagedClusters() error {
+	var lastErr error
+	for name, managedCluster := range c.managedClusters {
+		if err := managedCluster.stop(); err != nil {
+			klog.Errorf("fake hub: the managed cluster %s can not be stopped: %s", name, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// deployKlusterlet deploys the klusterlet of the clusterName on its managed cluster.
+func (c *controllers) deployKlusterlet(clusterName string) error {
+	managedCluster := c.managedCluster(clusterName)
+	if err := managedCluster.ensureNamespace(openClusterManagementAgentNamespace); err != nil {
+		return err
+	}
+	klusterlet := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "operator.open-cluster-management.io/v1",
+		"kind":       "Klusterlet",
+		"metadata": map[string]interface{}{
+			"name": klusterletName,
+		},
+		"spec": map[string]interface{}{
+			"clusterName": clusterName,
+		},
+	}}
+	current, err := managedCluster.dynamicClient.Resource(gvrKlusterlet).Get(context.TODO(), klusterletName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return managedCluster.create(gvrKlusterlet, klusterlet)
+	}
+	if err != nil {
+		return err
+	}
+	klusterlet.SetResourceVersion(current.GetResourceVersion())
+	_, err = managedCluster.dynamicClient.Resource(gvrKlusterlet).Update(context.TODO(), klusterlet, metav1.UpdateOptions{})
+	return err
+}
+
+// ensureWithConditions creates the object if it doesn't exist
+// and sets the conditionTypes to True in its status.
+func (c *controllers) ensureWithConditions(gvr schema.GroupVersionResource, u *unstructured.Unstructured, conditionTypes ...string) error {
+	client := c.dynamicClient.Resource(gvr).Namespace(u.GetNamespace())
+	current, err := client.Get(context.TODO(), u.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		current, err = client.Create(context.TODO(), u, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	if hasConditions(current, conditionTypes...) {
+		return nil
+	}
+	now := metav1.Now().UTC().Format(time.RFC3339)
+	conditions := make([]interface{}, 0)
+	for _, t := range conditionTypes {
+		conditions = append(conditions, map[string]interface{}{
+			"type":               t,
+			"status":             string(metav1.ConditionTrue),
+			"reason":             t,
+			"message":            "set by the fake hub",
+			"lastTransitionTime": now,
+		})
+	}
+	if err := unstructured.SetNestedSlice(current.Object, conditions, "status", "conditions"); err != nil {
+		return err
+	}
+	_, err = client.UpdateStatus(context.TODO(), current, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *cluster) create(gvr schema.GroupVersionResource, u *unstructured.Unstructured) error {
+	_, err := c.dynamicClient.Resource(gvr).Namespace(u.GetNamespace()).Create(context.TODO(), u, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *cluster) ensureNamespace(name string) error {
+	_, err := c.kubeClient.CoreV1().Namespaces().Create(context.TODO(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *controllers) ensureDeployment(ns, name string) error {
+	labels := map[string]string{"app": name}
+	_, err := c.kubeClient.AppsV1().Deployments(ns).Create(context.TODO(), &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: name, Image: "fake-hub/" + name},
+					},
+				},
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func newKlusterletAddonConfig(clusterName string) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"clusterName":      clusterName,
+		"clusterNamespace": clusterName,
+	}
+	for field := range klusterletAddonConfigAddOns {
+		spec[field] = map[string]interface{}{"enabled": true}
+	}
+	// search-collector is not deployed on the local-cluster
+	if clusterName == localClusterName {
+		spec["searchCollector"] = map[string]interface{}{"enabled": false}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "agent.open-cluster-management.io/v1",
+		"kind":       "KlusterletAddonConfig",
+		"metadata": map[string]interface{}{
+			"name":      clusterName,
+			"namespace": clusterName,
+		},
+		"spec": spec,
+	}}
+}
+
+func isAvailable(mc *unstructured.Unstructured) bool {
+	return hasConditions(mc, "ManagedClusterConditionAvailable")
+}
+
+func hasConditions(u *unstructured.Unstructured, conditionTypes ...string) bool {
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, t := range conditionTypes {
+		found := false
+		for _, ci := range conditions {
+			if condition, ok := ci.(map[string]interface{}); ok &&
+				condition["type"] == t && condition["status"] == string(metav1.ConditionTrue) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func hasVerb(verbs []string, verb string) bool {
+	for _, v := range verbs {
+		if strings.EqualFold(v, verb) {
+			return true
+		}
+	}
+	return false
+}
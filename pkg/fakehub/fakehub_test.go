@@ -0,0 +1,93 @@
+package fakehub
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// TestProvision starts the fake hub and checks a clusterDeployment is installed
+// and its managedCluster becomes available.
+func TestProvision(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, the envtest binaries are required")
+	}
+	hub, err := Start(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to start the fake hub: %v", err)
+	}
+	defer func() {
+		if err := hub.Stop(); err != nil {
+			t.Errorf("failed to stop the fake hub: %v", err)
+		}
+	}()
+	kubeClient, err := kubernetes.NewForConfig(hub.RestConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(hub.RestConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clusterName := "fake-provision"
+	if _, err := kubeClient.CoreV1().Namespaces().Create(context.TODO(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dynamicClient.Resource(gvrClusterDeployment).Namespace(clusterName).Create(context.TODO(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "hive.openshift.io/v1",
+		"kind":       "ClusterDeployment",
+		"metadata": map[string]interface{}{
+			"name":      clusterName,
+			"namespace": clusterName,
+		},
+		"spec": map[string]interface{}{
+			"baseDomain":  BaseDomain,
+			"clusterName": clusterName,
+		},
+	}}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dynamicClient.Resource(gvrManagedCluster).Create(context.TODO(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cluster.open-cluster-management.io/v1",
+		"kind":       "ManagedCluster",
+		"metadata": map[string]interface{}{
+			"name": clusterName,
+		},
+		"spec": map[string]interface{}{
+			"hubAcceptsClient": true,
+		},
+	}}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		cd, err := dynamicClient.Resource(gvrClusterDeployment).Namespace(clusterName).Get(context.TODO(), clusterName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		installed, _, _ := unstructured.NestedString(cd.Object, "status", "installedTimestamp")
+		return installed != "", nil
+	}); err != nil {
+		t.Fatalf("clusterDeployment %s not installed: %v", clusterName, err)
+	}
+	if err := wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		mc, err := dynamicClient.Resource(gvrManagedCluster).Get(context.TODO(), clusterName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return isAvailable(mc), nil
+	}); err != nil {
+		t.Fatalf("managedCluster %s not available: %v", clusterName, err)
+	}
+}
@@ -0,0 +1,224 @@
+package fakehub
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+)
+
+var (
+	gvrManagedClusterSetBinding = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "managedclustersetbindings"}
+	gvrPlacement                = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "placements"}
+	gvrPlacementDecision        = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "placementdecisions"}
+)
+
+const (
+	clusterSetLabel = "cluster.open-cluster-management.io/clusterset"
+	placementLabel  = "cluster.open-cluster-management.io/placement"
+)
+
+// the allocatable resource scored by the built-in prioritizers
+var prioritizerResources = map[string]string{
+	"ResourceAllocatableCPU":    "cpu",
+	"ResourceAllocatableMemory": "memory",
+}
+
+// reconcilePlacements simulates the placement controller: the available clusters of the managedClusterSets
+// of a placement bound to its namespace are selected by its predicates, sorted by its built-in resource
+// prioritizer, by name otherwise, and the first numberOfClusters are set in its placementDecision.
+func (c *controllers) reconcilePlacements() error {
+	placements, err := c.dynamicClient.Resource(gvrPlacement).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(placements.Items) == 0 {
+		return nil
+	}
+	mcs, err := c.dynamicClient.Resource(gvrManagedCluster).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range placements.Items {
+		p := &placements.Items[i]
+		if p.GetDeletionTimestamp() != nil {
+			continue
+		}
+		decisions, err := c.schedule(p, mcs.Items)
+		if err != nil {
+			return fmt.Errorf("placement %s/%s: %v", p.GetNamespace(), p.GetName(), err)
+		}
+		if err := c.setPlacementDecisions(p, decisions); err != nil {
+			return fmt.Errorf("placement %s/%s: %v", p.GetNamespace(), p.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// schedule returns the names of the clusters decided for the placement.
+func (c *controllers) schedule(p *unstructured.Unstructured, mcs []unstructured.Unstructured) ([]string, error) {
+	bindings, err := c.dynamicClient.Resource(gvrManagedClusterSetBinding).Namespace(p.GetNamespace()).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	bound := map[string]bool{}
+	for _, b := range bindings.Items {
+		bound[b.GetName()] = true
+	}
+	clusterSets := map[string]bool{}
+	if names, _, _ := unstructured.NestedStringSlice(p.Object, "spec", "clusterSets"); len(names) != 0 {
+		for _, name := range names {
+			clusterSets[name] = bound[name]
+		}
+	} else {
+		clusterSets = bound
+	}
+	predicates, _, _ := unstructured.NestedSlice(p.Object, "spec", "predicates")
+
+	var selected []*unstructured.Unstructured
+	for i := range mcs {
+		mc := &mcs[i]
+		if !clusterSets[mc.GetLabels()[clusterSetLabel]] || !isAvailable(mc) {
+			continue
+		}
+		matches, err := matchPredicates(predicates, mc)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			selected = append(selected, mc)
+		}
+	}
+
+	// the built-in resource prioritizer scores the clusters by their allocatable resource
+	var scored string
+	configurations, _, _ := unstructured.NestedSlice(p.Object, "spec", "prioritizerPolicy", "configurations")
+	for _, ci := range configurations {
+		if config, ok := ci.(map[string]interface{}); ok {
+			builtIn, _, _ := unstructured.NestedString(config, "scoreCoordinate", "builtIn")
+			if r, ok := prioritizerResources[builtIn]; ok {
+				scored = r
+			}
+		}
+	}
+	score := func(mc *unstructured.Unstructured) resource.Quantity {
+		value, _, _ := unstructured.NestedString(mc.Object, "status", "allocatable", scored)
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return resource.Quantity{}
+		}
+		return q
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if scored != "" {
+			si, sj := score(selected[i]), score(selected[j])
+			if cmp := si.Cmp(sj); cmp != 0 {
+				return cmp > 0
+			}
+		}
+		return selected[i].GetName() < selected[j].GetName()
+	})
+	if n, found, _ := unstructured.NestedInt64(p.Object, "spec", "numberOfClusters"); found && int(n) < len(selected) {
+		selected = selected[:n]
+	}
+	decisions := make([]string, 0, len(selected))
+	for _, mc := range selected {
+		decisions = append(decisions, mc.GetName())
+	}
+	sort.Strings(decisions)
+	return decisions, nil
+}
+
+// matchPredicates returns true if the cluster matches one of the predicates, their label
+// and claim selectors, or if there is no predicate.
+func matchPredicates(predicates []interface{}, mc *unstructured.Unstructured) (bool, error) {
+	if len(predicates) == 0 {
+		return true, nil
+	}
+	claims := labels.Set{}
+	clusterClaims, _, _ := unstructured.NestedSlice(mc.Object, "status", "clusterClaims")
+	for _, ci := range clusterClaims {
+		if claim, ok := ci.(map[string]interface{}); ok {
+			name, _ := claim["name"].(string)
+			value, _ := claim["value"].(string)
+			claims[name] = value
+		}
+	}
+	for _, pi := range predicates {
+		predicate, ok := pi.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		matches := true
+		for field, set := range map[string]labels.Set{"labelSelector": mc.GetLabels(), "claimSelector": claims} {
+			s, found, _ := unstructured.NestedMap(predicate, "requiredClusterSelector", field)
+			if !found {
+				continue
+			}
+			selector := &metav1.LabelSelector{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(s, selector); err != nil {
+				return false, err
+			}
+			ls, err := metav1.LabelSelectorAsSelector(selector)
+			if err != nil {
+				return false, err
+			}
+			matches = matches && ls.Matches(set)
+		}
+		if matches {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setPlacementDecisions sets the decided clusters in the placementDecision of the placement.
+func (c *controllers) setPlacementDecisions(p *unstructured.Unstructured, clusterNames []string) error {
+	client := c.dynamicClient.Resource(gvrPlacementDecision).Namespace(p.GetNamespace())
+	name := p.GetName() + "-decision-1"
+	decision, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		decision, err = client.Create(context.TODO(), &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cluster.open-cluster-management.io/v1beta1",
+			"kind":       "PlacementDecision",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": p.GetNamespace(),
+				"labels": map[string]interface{}{
+					placementLabel: p.GetName(),
+				},
+			},
+		}}, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	decisions := make([]interface{}, 0, len(clusterNames))
+	for _, clusterName := range clusterNames {
+		decisions = append(decisions, map[string]interface{}{
+			"clusterName": clusterName,
+			"reason":      "",
+		})
+	}
+	current, _, _ := unstructured.NestedSlice(decision.Object, "status", "decisions")
+	if equality.Semantic.DeepEqual(current, decisions) {
+		return nil
+	}
+	if err := unstructured.SetNestedSlice(decision.Object, decisions, "status", "decisions"); err != nil {
+		return err
+	}
+	_, err = client.UpdateStatus(context.TODO(), decision, metav1.UpdateOptions{})
+	if err == nil {
+		klog.V(2).Infof("fake hub: placement %s/%s decisions %v", p.GetNamespace(), p.GetName(), clusterNames)
+	}
+	return err
+}
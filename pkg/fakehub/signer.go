@@ -0,0 +1,121 @@
+package fakehub
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// the validity of the certificates issued by the fake hub
+const certificateValidity = 24 * time.Hour
+
+// signer is a self-signed CA issuing the certificates of the approved CSRs.
+type signer struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newSigner() (*signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake-hub-signer"},
+		NotBefore:             now,
+		NotAfter:              now.Add(10 * certificateValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &signer{cert: cert, key: key}, nil
+}
+
+// sign returns the PEM encoded client certificate of the CSR.
+func (s *signer) sign(csr *certificatesv1.CertificateSigningRequest) ([]byte, error) {
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("CSR %s: the request is not a PEM encoded certificate request", csr.Name)
+	}
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      req.Subject,
+		NotBefore:    now,
+		NotAfter:     now.Add(certificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, s.cert, req.PublicKey, s.key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// reconcileCertificateSigningRequests simulates the signer of the kube-controller-manager which doesn't run
+// in the fake hub, the approved CSRs are signed immediately.
+func (c *controllers) reconcileCertificateSigningRequests() error {
+	csrs, err := c.kubeClient.CertificatesV1().CertificateSigningRequests().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range csrs.Items {
+		csr := &csrs.Items[i]
+		if len(csr.Status.Certificate) != 0 || !isApproved(csr) {
+			continue
+		}
+		csr.Status.Certificate, err = c.signer.sign(csr)
+		if err != nil {
+			return err
+		}
+		if _, err := c.kubeClient.CertificatesV1().CertificateSigningRequests().UpdateStatus(context.TODO(), csr, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		klog.V(2).Infof("fake hub: CSR %s signed", csr.Name)
+	}
+	return nil
+}
+
+func isApproved(csr *certificatesv1.CertificateSigningRequest) bool {
+	approved := false
+	for _, condition := range csr.Status.Conditions {
+		switch condition.Type {
+		case certificatesv1.CertificateDenied, certificatesv1.CertificateFailed:
+			return false
+		case certificatesv1.CertificateApproved:
+			approved = condition.Status == corev1.ConditionTrue
+		}
+	}
+	return approved
+}
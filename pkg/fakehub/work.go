@@ -0,0 +1,307 @@
+package fakehub
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+// the finalizer set by the work agent to delete the manifests of a deleted manifestWork
+const manifestWorkCleanupFinalizer = "cluster.open-cluster-management.io/manifest-work-cleanup"
+
+// reconcileManifestWorks simulates the work agents of the managed clusters: the manifests of the manifestWorks
+// of a cluster are applied on its managed cluster, the deployments are reported ready and their status feedback
+// returned, and the manifests are deleted with the manifestWork unless they are orphaned.
+func (c *controllers) reconcileManifestWorks() error {
+	for clusterName, managedCluster := range c.managedClusters {
+		mws, err := c.dynamicClient.Resource(gvrManifestWork).Namespace(clusterName).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for i := range mws.Items {
+			if err := c.reconcileManifestWork(managedCluster, &mws.Items[i]); err != nil {
+				return fmt.Errorf("manifestWork %s/%s: %v", clusterName, mws.Items[i].GetName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *controllers) reconcileManifestWork(managedCluster *cluster, mw *unstructured.Unstructured) error {
+	client := c.dynamicClient.Resource(gvrManifestWork).Namespace(mw.GetNamespace())
+	manifests, _, _ := unstructured.NestedSlice(mw.Object, "spec", "workload", "manifests")
+	if mw.GetDeletionTimestamp() != nil {
+		if !hasFinalizer(mw, manifestWorkCleanupFinalizer) {
+			return nil
+		}
+		for _, m := range manifests {
+			manifest, ok := m.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if err := managedCluster.deleteManifest(mw, &unstructured.Unstructured{Object: manifest}); err != nil {
+				return err
+			}
+		}
+		mw.SetFinalizers(removeFinalizer(mw.GetFinalizers(), manifestWorkCleanupFinalizer))
+		_, err := client.Update(context.TODO(), mw, metav1.UpdateOptions{})
+		if err == nil {
+			klog.V(2).Infof("fake hub: manifestWork %s/%s deleted", mw.GetNamespace(), mw.GetName())
+		}
+		return err
+	}
+	if !hasFinalizer(mw, manifestWorkCleanupFinalizer) {
+		mw.SetFinalizers(append(mw.GetFinalizers(), manifestWorkCleanupFinalizer))
+		var err error
+		if mw, err = client.Update(context.TODO(), mw, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	statuses := make([]interface{}, 0, len(manifests))
+	for i, m := range manifests {
+		manifest, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		status, err := managedCluster.applyManifest(mw, (&unstructured.Unstructured{Object: manifest}).DeepCopy())
+		if err != nil {
+			return err
+		}
+		status["resourceMeta"].(map[string]interface{})["ordinal"] = int64(i)
+		statuses = append(statuses, status)
+	}
+	if generation, found, _ := unstructured.NestedInt64(mw.Object, "status", "observedGeneration"); found && generation == mw.GetGeneration() {
+		current, _, _ := unstructured.NestedSlice(mw.Object, "status", "resourceStatus", "manifests")
+		if equality.Semantic.DeepEqual(current, statuses) {
+			return nil
+		}
+	}
+	now := metav1.Now().UTC().Format(time.RFC3339)
+	conditions := make([]interface{}, 0)
+	for _, t := range []string{"Applied", "Available"} {
+		conditions = append(conditions, map[string]interface{}{
+			"type":               t,
+			"status":             string(metav1.ConditionTrue),
+			"reason":             t,
+			"message":            "set by the fake hub",
+			"observedGeneration": mw.GetGeneration(),
+			"lastTransitionTime": now,
+		})
+	}
+	if err := unstructured.SetNestedField(mw.Object, map[string]interface{}{
+		"conditions":         conditions,
+		"observedGeneration": mw.GetGeneration(),
+		"resourceStatus": map[string]interface{}{
+			"manifests": statuses,
+		},
+	}, "status"); err != nil {
+		return err
+	}
+	_, err := client.UpdateStatus(context.TODO(), mw, metav1.UpdateOptions{})
+	if err == nil {
+		klog.V(2).Infof("fake hub: manifestWork %s/%s applied", mw.GetNamespace(), mw.GetName())
+	}
+	return err
+}
+
+// applyManifest creates or updates the manifest on the managed cluster, reports the deployments ready
+// as the deployment controller would do, and returns the status of the manifest.
+func (c *cluster) applyManifest(mw, manifest *unstructured.Unstructured) (map[string]interface{}, error) {
+	mapping, err := c.mapping(manifest.GetAPIVersion(), manifest.GetKind())
+	if err != nil {
+		return nil, err
+	}
+	namespace := ""
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace = manifest.GetNamespace()
+	}
+	client := c.dynamicClient.Resource(mapping.Resource).Namespace(namespace)
+	current, err := client.Get(context.TODO(), manifest.GetName(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		current, err = client.Create(context.TODO(), manifest, metav1.CreateOptions{})
+	case err == nil:
+		manifest.SetResourceVersion(current.GetResourceVersion())
+		current, err = client.Update(context.TODO(), manifest, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Resource.Group == "apps" && mapping.Resource.Resource == "deployments" {
+		if current, err = setDeploymentReady(client, current); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{
+		"resourceMeta": map[string]interface{}{
+			"group":     mapping.Resource.Group,
+			"version":   mapping.Resource.Version,
+			"kind":      mapping.GroupVersionKind.Kind,
+			"resource":  mapping.Resource.Resource,
+			"namespace": namespace,
+			"name":      manifest.GetName(),
+		},
+		"conditions": []interface{}{
+			map[string]interface{}{
+				"type":    "Applied",
+				"status":  string(metav1.ConditionTrue),
+				"reason":  "AppliedManifestComplete",
+				"message": "set by the fake hub",
+			},
+		},
+		"statusFeedback": map[string]interface{}{
+			"values": statusFeedback(mw, mapping.Resource.Group, mapping.Resource.Resource, current),
+		},
+	}, nil
+}
+
+// deleteManifest deletes the manifest from the managed cluster unless it is orphaned by the deleteOption.
+func (c *cluster) deleteManifest(mw, manifest *unstructured.Unstructured) error {
+	mapping, err := c.mapping(manifest.GetAPIVersion(), manifest.GetKind())
+	if err != nil {
+		return err
+	}
+	namespace := ""
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace = manifest.GetNamespace()
+	}
+	if isOrphaned(mw, mapping.Resource.Group, mapping.Resource.Resource, namespace, manifest.GetName()) {
+		return nil
+	}
+	err = c.dynamicClient.Resource(mapping.Resource).Namespace(namespace).Delete(context.TODO(), manifest.GetName(), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// setDeploymentReady sets all the replicas of the deployment ready in its status.
+func setDeploymentReady(client dynamic.ResourceInterface, deployment *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	replicas, found, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+	observedGeneration, _, _ := unstructured.NestedInt64(deployment.Object, "status", "observedGeneration")
+	readyReplicas, _, _ := unstructured.NestedInt64(deployment.Object, "status", "readyReplicas")
+	if observedGeneration == deployment.GetGeneration() && readyReplicas == replicas {
+		return deployment, nil
+	}
+	if err := unstructured.SetNestedField(deployment.Object, map[string]interface{}{
+		"observedGeneration": deployment.GetGeneration(),
+		"replicas":           replicas,
+		"updatedReplicas":    replicas,
+		"readyReplicas":      replicas,
+		"availableReplicas":  replicas,
+	}, "status"); err != nil {
+		return nil, err
+	}
+	return client.UpdateStatus(context.TODO(), deployment, metav1.UpdateOptions{})
+}
+
+// statusFeedback returns the values of the feedback rules of the manifestWork matching the object,
+// the well known status of the deployments and the JSON paths of the status.
+func statusFeedback(mw *unstructured.Unstructured, group, resource string, obj *unstructured.Unstructured) []interface{} {
+	values := make([]interface{}, 0)
+	configs, _, _ := unstructured.NestedSlice(mw.Object, "spec", "manifestConfigs")
+	for _, ci := range configs {
+		config, ok := ci.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _, _ := unstructured.NestedStringMap(config, "resourceIdentifier")
+		if id["group"] != group || id["resource"] != resource || id["namespace"] != obj.GetNamespace() || id["name"] != obj.GetName() {
+			continue
+		}
+		rules, _, _ := unstructured.NestedSlice(config, "feedbackRules")
+		for _, ri := range rules {
+			rule, ok := ri.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			paths := map[string]string{}
+			switch rule["type"] {
+			case "WellKnownStatus":
+				if group == "apps" && resource == "deployments" {
+					for _, name := range []string{"ReadyReplicas", "Replicas", "AvailableReplicas"} {
+						paths[name] = strings.ToLower(name[:1]) + name[1:]
+					}
+				}
+			case "JSONPaths":
+				jsonPaths, _, _ := unstructured.NestedSlice(rule, "jsonPaths")
+				for _, pi := range jsonPaths {
+					if p, ok := pi.(map[string]interface{}); ok {
+						name, _ := p["name"].(string)
+						path, _ := p["path"].(string)
+						paths[name] = strings.TrimPrefix(path, ".")
+					}
+				}
+			}
+			names := make([]string, 0, len(paths))
+			for name := range paths {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if i, found, _ := unstructured.NestedInt64(obj.Object, "status", paths[name]); found {
+					values = append(values, map[string]interface{}{
+						"name": name,
+						"fieldValue": map[string]interface{}{
+							"type":    "Integer",
+							"integer": i,
+						},
+					})
+				}
+			}
+		}
+	}
+	return values
+}
+
+// isOrphaned returns true if the manifest is orphaned by the deleteOption of the manifestWork.
+func isOrphaned(mw *unstructured.Unstructured, group, resource, namespace, name string) bool {
+	policy, _, _ := unstructured.NestedString(mw.Object, "spec", "deleteOption", "propagationPolicy")
+	switch policy {
+	case "Orphan":
+		return true
+	case "SelectivelyOrphan":
+		rules, _, _ := unstructured.NestedSlice(mw.Object, "spec", "deleteOption", "selectivelyOrphans", "orphaningRules")
+		for _, ri := range rules {
+			if rule, ok := ri.(map[string]interface{}); ok &&
+				rule["group"] == group && rule["resource"] == resource && rule["name"] == name &&
+				(rule["namespace"] == namespace || (rule["namespace"] == nil && namespace == "")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasFinalizer(u *unstructured.Unstructured, finalizer string) bool {
+	for _, f := range u.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	l := make([]string, 0, len(finalizers))
+	for _, f := range finalizers {
+		if f != finalizer {
+			l = append(l, f)
+		}
+	}
+	return l
+}
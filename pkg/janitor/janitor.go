@@ -0,0 +1,331 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+var (
+	gvrManagedCluster    = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
+	gvrClusterDeployment = schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
+	gvrClusterImageSet   = schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterimagesets"}
+	gvrClusterPool       = schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterpools"}
+	gvrHostedCluster     = schema.GroupVersionResource{Group: "hypershift.openshift.io", Version: "v1beta1", Resource: "hostedclusters"}
+	gvrNamespace         = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+)
+
+// clusterNamePattern matches the generated cluster names: <cloud>-<owner>-<uid>, hcp for the hosted clusters
+var clusterNamePattern = regexp.MustCompile(`^(aws|azure|gcp|baremetal|vsphere|openstack|ibmcloud|hcp)-([a-z0-9-]+)-([a-z0-9]{4})$`)
+
+// imageSetNamePattern matches the names of the clusterImageSets created for a cluster: <release>-<uid>
+var imageSetNamePattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+[a-z0-9.-]*-([a-z0-9]{4})$`)
+
+// Cluster is a cluster created by the tests and left on the hub
+type Cluster struct {
+	Name  string
+	Cloud string
+	// RunID is the run ID label, empty if the cluster was only matched by its name
+	RunID string
+	// Created is the creation time of the oldest of the clusterDeployment and managedCluster
+	Created           time.Time
+	ManagedCluster    bool
+	ClusterDeployment bool
+	// HostedClusterNamespace is the namespace of the hostedCluster, empty if the cluster is not hosted
+	HostedClusterNamespace string
+	// ImageSets are the clusterImageSets created for the cluster
+	ImageSets []string
+}
+
+// Age returns the age of the cluster at now
+func (c Cluster) Age(now time.Time) time.Duration {
+	return now.Sub(c.Created)
+}
+
+// Objects returns the kinds of the objects of the cluster present on the hub
+func (c Cluster) Objects() []string {
+	var l []string
+	if c.ManagedCluster {
+		l = append(l, "ManagedCluster")
+	}
+	if c.ClusterDeployment {
+		l = append(l, "ClusterDeployment")
+	}
+	if c.HostedClusterNamespace != "" {
+		l = append(l, "HostedCluster/"+c.HostedClusterNamespace)
+	}
+	for _, name := range c.ImageSets {
+		l = append(l, "ClusterImageSet/"+name)
+	}
+	return l
+}
+
+// Report lists the objects created by the tests which are older than the ttl
+type Report struct {
+	Clusters []Cluster
+	// ImageSets are the labeled clusterImageSets used by no clusterDeployment
+	ImageSets []string
+}
+
+// Find returns the clusters created by the tests which are older than the ttl.
+// A cluster is created by the tests if its clusterDeployment, hostedCluster or managedCluster has the run ID label
+// or if its name is a cluster name generated for one of the owners. The clusterDeployments of a clusterPool
+// are managed by the pool and are not returned.
+func Find(client dynamic.Interface, ttl time.Duration, now time.Time, owners []string) (*Report, error) {
+	created := func(u *unstructured.Unstructured) bool {
+		return createdBy(u, owners)
+	}
+	clusters := map[string]*Cluster{}
+	get := func(u *unstructured.Unstructured) *Cluster {
+		c, ok := clusters[u.GetName()]
+		if !ok {
+			c = &Cluster{Name: u.GetName(), Created: u.GetCreationTimestamp().Time}
+			clusters[u.GetName()] = c
+		}
+		if u.GetCreationTimestamp().Time.Before(c.Created) {
+			c.Created = u.GetCreationTimestamp().Time
+		}
+		if runID, ok := u.GetLabels()[ownership.RunIDLabel]; ok {
+			c.RunID = runID
+		}
+		return c
+	}
+
+	cds, err := list(client, gvrClusterDeployment)
+	if err != nil {
+		return nil, err
+	}
+	// the imageSets referenced by each clusterDeployment, the clusterDeployments of a clusterPool included
+	refs := map[string][]string{}
+	for i := range cds {
+		cd := &cds[i]
+		if imageSetName, _, _ := unstructured.NestedString(cd.Object, "spec", "provisioning", "imageSetRef", "name"); imageSetName != "" {
+			refs[imageSetName] = append(refs[imageSetName], cd.GetName())
+		}
+		if _, ok, _ := unstructured.NestedMap(cd.Object, "spec", "clusterPoolRef"); ok {
+			continue
+		}
+		if !created(cd) {
+			continue
+		}
+		c := get(cd)
+		c.ClusterDeployment = true
+	}
+
+	// the imageSets referenced by each clusterPool, a pool is never selected so its imageSet is kept
+	pools, err := list(client, gvrClusterPool)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pools {
+		pool := &pools[i]
+		if imageSetName, _, _ := unstructured.NestedString(pool.Object, "spec", "imageSetRef", "name"); imageSetName != "" {
+			refs[imageSetName] = append(refs[imageSetName], pool.GetNamespace()+"/"+pool.GetName())
+		}
+	}
+
+	hcs, err := list(client, gvrHostedCluster)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hcs {
+		hc := &hcs[i]
+		if !created(hc) {
+			continue
+		}
+		c := get(hc)
+		c.HostedClusterNamespace = hc.GetNamespace()
+	}
+
+	mcs, err := list(client, gvrManagedCluster)
+	if err != nil {
+		return nil, err
+	}
+	for i := range mcs {
+		mc := &mcs[i]
+		if !created(mc) {
+			continue
+		}
+		c := get(mc)
+		c.ManagedCluster = true
+		if c.Cloud == "" {
+			c.Cloud = mc.GetLabels()["cloud"]
+		}
+	}
+
+	for name, c := range clusters {
+		if c.Age(now) < ttl {
+			delete(clusters, name)
+			continue
+		}
+		if m := clusterNamePattern.FindStringSubmatch(c.Name); m != nil {
+			c.Cloud = m[1]
+		}
+	}
+
+	r := &Report{}
+	imageSets, err := list(client, gvrClusterImageSet)
+	if err != nil {
+		return nil, err
+	}
+	for i := range imageSets {
+		is := &imageSets[i]
+		if now.Sub(is.GetCreationTimestamp().Time) < ttl {
+			continue
+		}
+		// an imageSet still used by a cluster which is kept is not deleted
+		var owners []*Cluster
+		inUse := false
+		for _, cdName := range refs[is.GetName()] {
+			if c, ok := clusters[cdName]; ok {
+				owners = append(owners, c)
+			} else {
+				inUse = true
+			}
+		}
+		if inUse {
+			continue
+		}
+		_, labeled := is.GetLabels()[ownership.RunIDLabel]
+		if labeled && len(refs[is.GetName()]) == 0 {
+			r.ImageSets = append(r.ImageSets, is.GetName())
+			continue
+		}
+		m := imageSetNamePattern.FindStringSubmatch(is.GetName())
+		for _, c := range owners {
+			if labeled || (m != nil && strings.HasSuffix(c.Name, "-"+m[1])) {
+				c.ImageSets = append(c.ImageSets, is.GetName())
+			}
+		}
+	}
+
+	for _, c := range clusters {
+		sort.Strings(c.ImageSets)
+		r.Clusters = append(r.Clusters, *c)
+	}
+	sort.Slice(r.Clusters, func(i, j int) bool { return r.Clusters[i].Created.Before(r.Clusters[j].Created) })
+	sort.Strings(r.ImageSets)
+	return r, nil
+}
+
+// createdBy returns true if the object was created by the tests: it has the run ID label,
+// or its name is a cluster name generated for one of the owners. On a shared hub,
+// a name alike the generated names is not enough as it may be the name of a cluster of another team.
+func createdBy(u *unstructured.Unstructured, owners []string) bool {
+	if _, ok := u.GetLabels()[ownership.RunIDLabel]; ok {
+		return true
+	}
+	m := clusterNamePattern.FindStringSubmatch(u.GetName())
+	if m == nil {
+		return false
+	}
+	for _, owner := range owners {
+		if m[2] == owner {
+			return true
+		}
+	}
+	return false
+}
+
+func list(client dynamic.Interface, gvr schema.GroupVersionResource) ([]unstructured.Unstructured, error) {
+	l, err := client.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: %v", gvr.Resource, err)
+	}
+	return l.Items, nil
+}
+
+// Destroy destroys the clusters of the report in parallel with the sequence of the destroy tests:
+// the managedCluster is detached, the hostedCluster or the clusterDeployment deleted and its deprovision waited,
+// then the namespace deletion is waited and the clusterImageSets are deleted.
+// The orphaned clusterImageSets are deleted too. The errors of all the clusters are returned.
+func Destroy(client dynamic.Interface, r *Report) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(r.Clusters)+len(r.ImageSets))
+	for i := range r.Clusters {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := destroy(client, r.Clusters[i]); err != nil {
+				errs[i] = fmt.Errorf("cluster %s: %v", r.Clusters[i].Name, err)
+			}
+		}(i)
+	}
+	for i, name := range r.ImageSets {
+		klog.V(1).Infof("Deleting the orphaned clusterImageSet %s", name)
+		errs[len(r.Clusters)+i] = deleteObject(client, gvrClusterImageSet, "", name)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+func destroy(client dynamic.Interface, c Cluster) error {
+	waiter := waiters.NewWaiter(client, options.LongInterval())
+	if c.ManagedCluster {
+		klog.V(1).Infof("Cluster %s: Detaching the managedCluster", c.Name)
+		if err := deleteObject(client, gvrManagedCluster, "", c.Name); err != nil {
+			return err
+		}
+	}
+	if c.HostedClusterNamespace != "" {
+		klog.V(1).Infof("Cluster %s: Deleting the hostedCluster", c.Name)
+		if err := deleteObject(client, gvrHostedCluster, c.HostedClusterNamespace, c.Name); err != nil {
+			return err
+		}
+		if err := waiter.WaitForDeletion(gvrHostedCluster, c.HostedClusterNamespace, c.Name, options.Timeout(options.TimeoutHostedClusterDeletion)); err != nil {
+			return fmt.Errorf("hostedCluster not destroyed: %v", err)
+		}
+		klog.V(1).Infof("Cluster %s: hostedCluster deleted", c.Name)
+	}
+	if c.ClusterDeployment {
+		klog.V(1).Infof("Cluster %s: Deleting the clusterDeployment", c.Name)
+		if err := deleteObject(client, gvrClusterDeployment, c.Name, c.Name); err != nil {
+			return err
+		}
+		if err := waiter.WaitForDeletion(gvrClusterDeployment, c.Name, c.Name, options.Timeout(options.TimeoutDeprovision)); err != nil {
+			return fmt.Errorf("clusterDeployment not deprovisioned: %v", err)
+		}
+		klog.V(1).Infof("Cluster %s: clusterDeployment deleted", c.Name)
+	}
+	klog.V(1).Infof("Cluster %s: Waiting the deletion of the %s namespace", c.Name, c.Name)
+	if err := waiter.WaitForDeletion(gvrNamespace, "", c.Name, options.Timeout(options.TimeoutNamespaceDeletion)); err != nil {
+		return fmt.Errorf("namespace not deleted: %v", err)
+	}
+	for _, name := range c.ImageSets {
+		klog.V(1).Infof("Cluster %s: Deleting the clusterImageSet %s", c.Name, name)
+		if err := deleteObject(client, gvrClusterImageSet, "", name); err != nil {
+			return err
+		}
+		if err := waiter.WaitForDeletion(gvrClusterImageSet, "", name, options.Timeout(options.TimeoutDefault)); err != nil {
+			return fmt.Errorf("clusterImageSet %s not deleted: %v", name, err)
+		}
+	}
+	klog.V(1).Infof("Cluster %s: destroyed", c.Name)
+	return nil
+}
+
+func deleteObject(client dynamic.Interface, gvr schema.GroupVersionResource, namespace, name string) error {
+	err := client.Resource(gvr).Namespace(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("%s %s can not be deleted: %v", gvr.Resource, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+)
+
+// Namespace is the namespace of the run manifest configMaps on the hub
+const Namespace = "default"
+
+// ManifestLabel is set on the run manifest configMaps
+const ManifestLabel = "cluster-lifecycle-e2e.open-cluster-management.io/run-manifest"
+
+// Entry is a cluster created by a run and kept on the hub
+type Entry struct {
+	Cluster   string `json:"cluster"`
+	Cloud     string `json:"cloud"`
+	ImageSet  string `json:"imageSet,omitempty"`
+	Namespace string `json:"namespace"`
+	RunID     string `json:"runID"`
+}
+
+var force bool
+
+// InitFlags adds the -force-destroy flag
+func InitFlags(flagset *flag.FlagSet) {
+	if flagset == nil {
+		flagset = flag.CommandLine
+	}
+	flagset.BoolVar(&force, "force-destroy", false,
+		"Destroy the cluster of the owner found by name when no cluster is recorded in the run manifest")
+}
+
+// Forced returns true if the clusters not recorded in the run manifest can be destroyed
+func Forced() bool {
+	return force
+}
+
+var invalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Name returns the name of the run manifest configMap of the run
+func Name(runID string) string {
+	return "cluster-lifecycle-e2e-run-" + strings.Trim(invalidChars.ReplaceAllString(strings.ToLower(runID), "-"), "-")
+}
+
+// Record adds the entry to the run manifest of its run, the configMap is created if needed.
+// The configMap has the run ID label so 'clc-e2e cleanup' deletes it with the objects of the run.
+func Record(client kubernetes.Interface, e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	configMaps := client.CoreV1().ConfigMaps(Namespace)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(context.TODO(), Name(e.RunID), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      Name(e.RunID),
+					Namespace: Namespace,
+					Labels: map[string]string{
+						ownership.RunIDLabel: e.RunID,
+						ManifestLabel:        "true",
+					},
+				},
+				Data: map[string]string{e.Cluster: string(b)},
+			}
+			_, err = configMaps.Create(context.TODO(), cm, metav1.CreateOptions{})
+			if errors.IsAlreadyExists(err) {
+				// created by a parallel node, retried as a conflict
+				return errors.NewConflict(corev1.Resource("configmaps"), cm.Name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[e.Cluster] = string(b)
+		_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cluster %s can not be recorded in the run manifest %s/%s: %v", e.Cluster, Namespace, Name(e.RunID), err)
+	}
+	klog.V(1).Infof("Cluster %s: recorded in the run manifest %s/%s", e.Cluster, Namespace, Name(e.RunID))
+	return nil
+}
+
+// Entries returns the entries of the run manifest of the run sorted by cluster name,
+// none if the run has no manifest.
+func Entries(client kubernetes.Interface, runID string) ([]Entry, error) {
+	cm, err := client.CoreV1().ConfigMaps(Namespace).Get(context.TODO(), Name(runID), metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	l := make([]Entry, 0, len(cm.Data))
+	for name, data := range cm.Data {
+		var e Entry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return nil, fmt.Errorf("malformed entry %s in the run manifest %s/%s: %v", name, Namespace, cm.Name, err)
+		}
+		l = append(l, e)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Cluster < l[j].Cluster })
+	return l, nil
+}
+
+// Lookup returns the first entry of the run manifest of the run for the cloud, nil if there is none.
+func Lookup(client kubernetes.Interface, runID, cloud string) (*Entry, error) {
+	l, err := Entries(client, runID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range l {
+		if l[i].Cloud == cloud {
+			return &l[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Remove removes the entry of the cluster from the run manifest of the run,
+// the configMap is deleted once empty.
+func Remove(client kubernetes.Interface, runID, cluster string) error {
+	configMaps := client.CoreV1().ConfigMaps(Namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(context.TODO(), Name(runID), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if _, ok := cm.Data[cluster]; !ok {
+			return nil
+		}
+		if len(cm.Data) == 1 {
+			return configMaps.Delete(context.TODO(), cm.Name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &cm.ResourceVersion},
+			})
+		}
+		delete(cm.Data, cluster)
+		_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		return err
+	})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cluster %s can not be removed from the run manifest %s/%s: %v", cluster, Namespace, Name(runID), err)
+	}
+	klog.V(1).Infof("Cluster %s: removed from the run manifest %s/%s", cluster, Namespace, Name(runID))
+	return nil
+}
@@ -0,0 +1,41 @@
+package ownership
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// recordingClient labels the objects it creates with the run ID and records them
+type recordingClient struct {
+	client.Client
+}
+
+// NewClient returns a client which labels the objects it creates with the run ID
+// and records them for the teardown.
+func NewClient(c client.Client) client.Client {
+	return &recordingClient{Client: c}
+}
+
+func (c *recordingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	Own(obj)
+	if err := c.Client.Create(ctx, obj, opts...); err != nil {
+		return err
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if _, ok := obj.(*unstructured.Unstructured); !ok || gvk.Empty() {
+		var err error
+		gvk, err = apiutil.GVKForObject(obj, c.Scheme())
+		if err != nil {
+			return err
+		}
+	}
+	mapping, err := c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+	Record(mapping.Resource, gvk.Kind, obj.GetNamespace(), obj.GetName())
+	return nil
+}
@@ -0,0 +1,233 @@
+package ownership
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+// RunIDLabel is the label set on the objects created by a run
+const RunIDLabel = "cluster-lifecycle-e2e.open-cluster-management.io/run-id"
+
+// resource is a kind of object deleted by the teardown
+type resource struct {
+	gvr  schema.GroupVersionResource
+	kind string
+	// the objects of a phase are deleted once the objects of the previous phases are gone
+	phase int
+}
+
+// the resources in their deletion order, the managedCluster is detached before the clusterDeployment is deprovisioned,
+// the hostedCluster destroyed or its managedClusterSet deleted, and the secrets used by the deprovision are deleted after.
+// The cleanup only finds the kinds listed here, every kind recorded by the tests must be listed.
+var resources = []resource{
+	{gvr: schema.GroupVersionResource{Group: "agent.open-cluster-management.io", Version: "v1", Resource: "klusterletaddonconfigs"}, kind: "KlusterletAddonConfig", phase: 0},
+	{gvr: schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}, kind: "ManagedCluster", phase: 0},
+	{gvr: schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "clustercurators"}, kind: "ClusterCurator", phase: 0},
+	{gvr: schema.GroupVersionResource{Group: "certificates.k8s.io", Version: "v1", Resource: "certificatesigningrequests"}, kind: "CertificateSigningRequest", phase: 0},
+	{gvr: schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterclaims"}, kind: "ClusterClaim", phase: 0},
+	{gvr: schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "placements"}, kind: "Placement", phase: 0},
+	{gvr: schema.GroupVersionResource{Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks"}, kind: "ManifestWork", phase: 0},
+	{gvr: schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "managedclustersetbindings"}, kind: "ManagedClusterSetBinding", phase: 0},
+	{gvr: schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterpools"}, kind: "ClusterPool", phase: 1},
+	{gvr: schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "managedclustersets"}, kind: "ManagedClusterSet", phase: 1},
+	{gvr: schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}, kind: "ClusterDeployment", phase: 1},
+	{gvr: schema.GroupVersionResource{Group: "hypershift.openshift.io", Version: "v1beta1", Resource: "nodepools"}, kind: "NodePool", phase: 1},
+	{gvr: schema.GroupVersionResource{Group: "hypershift.openshift.io", Version: "v1beta1", Resource: "hostedclusters"}, kind: "HostedCluster", phase: 1},
+	{gvr: schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "machinepools"}, kind: "MachinePool", phase: 2},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, kind: "Secret", phase: 2},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, kind: "ConfigMap", phase: 2},
+	{gvr: schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterimagesets"}, kind: "ClusterImageSet", phase: 3},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, kind: "Namespace", phase: 4},
+}
+
+// the phase of the kinds which are not in resources
+const defaultPhase = 2
+
+// the timeout of the timing profile of the deletion of the objects of a phase, the clusterDeployments take
+// the time of a deprovision and the namespaces the time of their deletion, the other phases the default timeout
+var phaseTimeouts = map[int]string{
+	1: options.TimeoutDeprovision,
+	4: options.TimeoutNamespaceDeletion,
+}
+
+// Object is an object created by the run
+type Object struct {
+	GVR       schema.GroupVersionResource
+	Kind      string
+	Namespace string
+	Name      string
+	phase     int
+}
+
+func (o Object) String() string {
+	if o.Namespace == "" {
+		return fmt.Sprintf("%s %s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+var runID string
+
+var (
+	mutex   sync.Mutex
+	objects []Object
+)
+
+// InitFlags adds the -run-id flag
+func InitFlags(flagset *flag.FlagSet) {
+	if flagset == nil {
+		flagset = flag.CommandLine
+	}
+	flagset.StringVar(&runID, "run-id", "",
+		"The ID of the run set as label on the created objects, to clean them with 'clc-e2e cleanup -run-id'. "+
+			"If not set the ginkgo random seed is used")
+}
+
+// RunID returns the ID of the run, the ginkgo random seed is shared by the parallel nodes
+func RunID() string {
+	if runID == "" {
+		runID = fmt.Sprintf("%d", config.GinkgoConfig.RandomSeed)
+	}
+	return runID
+}
+
+// Own sets the run ID label on an object to create
+func Own(obj metav1.Object) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[RunIDLabel] = RunID()
+	obj.SetLabels(labels)
+}
+
+// Record records an object created by the running spec
+func Record(gvr schema.GroupVersionResource, kind, namespace, name string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	o := Object{
+		GVR:       gvr,
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+		phase:     defaultPhase,
+	}
+	for _, r := range resources {
+		if r.kind == kind && r.gvr.Group == gvr.Group {
+			o.phase = r.phase
+		}
+	}
+	klog.V(2).Infof("Run %s: %s created", RunID(), o)
+	objects = append(objects, o)
+}
+
+// Pending returns true if objects are recorded and not yet kept or torn down
+func Pending() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return len(objects) != 0
+}
+
+// Forget forgets the objects recorded, they are kept on the hub (ie: the spec succeeded)
+func Forget() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	objects = nil
+}
+
+// Teardown deletes the objects recorded in the dependency order and forgets them.
+// The deletion continues on errors, the errors are logged and the last one returned.
+func Teardown(client dynamic.Interface) error {
+	mutex.Lock()
+	l := objects
+	objects = nil
+	mutex.Unlock()
+	if len(l) == 0 {
+		return nil
+	}
+	klog.V(1).Infof("Run %s: Tearing down %d objects", RunID(), len(l))
+	// the last created first in a phase
+	for i, j := 0, len(l)-1; i < j; i, j = i+1, j-1 {
+		l[i], l[j] = l[j], l[i]
+	}
+	return deleteObjects(client, RunID(), l, false)
+}
+
+// Cleanup deletes in the dependency order the objects labeled with the run ID on the hub.
+// In dryRun mode the objects are only listed.
+func Cleanup(client dynamic.Interface, runID string, dryRun bool) ([]Object, error) {
+	var l []Object
+	for _, r := range resources {
+		list, err := client.Resource(r.gvr).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", RunIDLabel, runID),
+		})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("%s: %v", r.gvr.Resource, err)
+		}
+		for _, u := range list.Items {
+			l = append(l, Object{
+				GVR:       r.gvr,
+				Kind:      r.kind,
+				Namespace: u.GetNamespace(),
+				Name:      u.GetName(),
+				phase:     r.phase,
+			})
+		}
+	}
+	return l, deleteObjects(client, runID, l, dryRun)
+}
+
+func deleteObjects(client dynamic.Interface, id string, l []Object, dryRun bool) error {
+	sort.SliceStable(l, func(i, j int) bool { return l[i].phase < l[j].phase })
+	var lastErr error
+	for start := 0; start < len(l); {
+		end := start
+		for end < len(l) && l[end].phase == l[start].phase {
+			end++
+		}
+		phase := l[start:end]
+		for _, o := range phase {
+			klog.V(1).Infof("Run %s: Deleting %s", id, o)
+			if dryRun {
+				continue
+			}
+			propagation := metav1.DeletePropagationBackground
+			err := client.Resource(o.GVR).Namespace(o.Namespace).Delete(context.TODO(), o.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil && !errors.IsNotFound(err) {
+				klog.Errorf("Run %s: %s can not be deleted: %s", id, o, err)
+				lastErr = err
+			}
+		}
+		if !dryRun {
+			name, ok := phaseTimeouts[l[start].phase]
+			if !ok {
+				name = options.TimeoutDefault
+			}
+			timeout := options.Timeout(name)
+			waiter := waiters.NewWaiter(client, options.Interval())
+			for _, o := range phase {
+				if err := waiter.WaitForDeletion(o.GVR, o.Namespace, o.Name, timeout); err != nil {
+					klog.Errorf("Run %s: %s not deleted: %s", id, o, err)
+					lastErr = err
+				}
+			}
+		}
+		start = end
+	}
+	return lastErr
+}
@@ -0,0 +1,271 @@
+package reports
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// The names of the steps recorded in the report
+const (
+	StepNamespace            = "namespace-creation"
+	StepInstall              = "clusterdeployment-install"
+	StepImport               = "import"
+	StepAutoImport           = "auto-import-secret-consumed"
+	StepManifestWorks        = "manifestwork-applied"
+	StepAddOnPrefix          = "addon-available/"
+	StepAddOnDisabledPrefix  = "addon-disabled/"
+	StepDetach               = "detach"
+	StepDestroy              = "clusterdeployment-deletion"
+	StepNamespaceDeletion    = "namespace-deletion"
+	StepHibernate            = "hibernate"
+	StepClusterUnknown       = "managedcluster-unknown"
+	StepResume               = "resume"
+	StepClusterPoolReady     = "clusterpool-ready"
+	StepClusterClaimAssigned = "clusterclaim-assigned"
+	StepClusterClaimRelease  = "clusterclaim-release"
+	StepCuratorUpgrade       = "curator-upgrade"
+	StepClusterVersion       = "clusterversion-updated"
+	StepHostedCluster        = "hostedcluster-available"
+	StepNodePool             = "nodepool-ready"
+	StepHostedClusterDelete  = "hostedcluster-deletion"
+	StepPlacementDecisions   = "placement-decisions"
+	StepManifestWorkFeedback = "manifestwork-feedback"
+	StepManifestWorkUpdate   = "manifestwork-updated"
+	StepManifestWorkDeletion = "manifestwork-deletion"
+	StepTeardown             = "teardown"
+)
+
+// The names of the latencies recorded in the report
+const (
+	LatencyCSRSigned        = "csr-signed"
+	LatencyClusterAvailable = "managedcluster-available"
+	LatencyManifestWork     = "manifestwork-applied"
+)
+
+// Step is a timed step of a spec.
+type Step struct {
+	Name      string    `json:"name"`
+	StartTime time.Time `json:"startTime"`
+	//The duration in seconds
+	Duration float64 `json:"duration"`
+	//False if the step failed or was interrupted
+	Completed bool `json:"completed"`
+	//The timeout in seconds of the waits of the step in the timing profile
+	Budget float64 `json:"budget,omitempty"`
+}
+
+// Latency is the distribution of the durations of an operation repeated by a spec,
+// the percentiles are computed with the nearest-rank method.
+type Latency struct {
+	//The number of operations measured
+	Count int `json:"count"`
+	//The durations in seconds
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+	//The number of operations not completed, not included in the percentiles
+	Missing int `json:"missing,omitempty"`
+}
+
+// Entry is the report of a spec run.
+type Entry struct {
+	Spec      string    `json:"spec"`
+	State     string    `json:"state"`
+	StartTime time.Time `json:"startTime"`
+	//The duration in seconds
+	Duration float64 `json:"duration"`
+	Cluster  string  `json:"cluster,omitempty"`
+	Cloud    string  `json:"cloud,omitempty"`
+	Region   string  `json:"region,omitempty"`
+	ImageSet string  `json:"imageSet,omitempty"`
+	Steps    []Step  `json:"steps,omitempty"`
+	//The latencies measured by the spec by name
+	Latencies map[string]Latency `json:"latencies,omitempty"`
+	//The tag of the failure category
+	FailureTag     string `json:"failureTag,omitempty"`
+	FailureReason  string `json:"failureReason,omitempty"`
+	FailureMessage string `json:"failureMessage,omitempty"`
+	//The links related to the spec (ie: failure analysis)
+	Links map[string]string `json:"links,omitempty"`
+	//All the clusters tested by the spec, in order
+	clusters []string
+}
+
+var (
+	mutex   sync.Mutex
+	current *Entry
+
+	budgets       = map[string]time.Duration{}
+	defaultBudget time.Duration
+)
+
+// SetBudgets sets the budgets of the steps by name, a name ending with "/" is the budget of the steps
+// with this prefix. The steps without budget have the default budget.
+func SetBudgets(b map[string]time.Duration, defaultB time.Duration) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	budgets = b
+	defaultBudget = defaultB
+}
+
+func budgetOf(name string) time.Duration {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if b, ok := budgets[name]; ok {
+		return b
+	}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		if b, ok := budgets[name[:i+1]]; ok {
+			return b
+		}
+	}
+	return defaultBudget
+}
+
+// begin starts recording a new entry for the running spec.
+func begin(spec string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	current = &Entry{
+		Spec:      spec,
+		StartTime: time.Now(),
+		Links:     map[string]string{},
+	}
+}
+
+// end returns the entry of the running spec and stops recording.
+func end() *Entry {
+	mutex.Lock()
+	defer mutex.Unlock()
+	e := current
+	current = nil
+	return e
+}
+
+func update(f func(e *Entry)) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if current != nil {
+		f(current)
+	}
+}
+
+// SetCluster records the cluster tested by the running spec.
+func SetCluster(cluster, cloud, region string) {
+	update(func(e *Entry) {
+		e.Cluster = cluster
+		e.Cloud = cloud
+		e.Region = region
+		for _, c := range e.clusters {
+			if c == cluster {
+				return
+			}
+		}
+		e.clusters = append(e.clusters, cluster)
+	})
+}
+
+// CurrentCluster returns the cluster tested by the running spec.
+func CurrentCluster() string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if current == nil {
+		return ""
+	}
+	return current.Cluster
+}
+
+// CurrentClusters returns all the clusters tested by the running spec, in the order they were set.
+func CurrentClusters() []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if current == nil {
+		return nil
+	}
+	return append([]string{}, current.clusters...)
+}
+
+// SetImageSet records the clusterImageSet used by the running spec.
+func SetImageSet(imageSet string) {
+	update(func(e *Entry) {
+		e.ImageSet = imageSet
+	})
+}
+
+// AddLink records a link related to the running spec.
+func AddLink(name, url string) {
+	update(func(e *Entry) {
+		e.Links[name] = url
+	})
+}
+
+// TimeStep runs the body and records its duration as a step of the running spec.
+// The step is recorded even if the body fails, its duration is logged against its budget.
+func TimeStep(name string, body func()) {
+	start := time.Now()
+	completed := false
+	defer func() {
+		duration := time.Since(start)
+		budget := budgetOf(name)
+		cluster := CurrentCluster()
+		switch {
+		case budget == 0:
+			klog.V(1).Infof("Cluster %s: step %s took %s", cluster, name, duration.Round(time.Second))
+		case duration > budget:
+			klog.Warningf("Cluster %s: step %s took %s, over its budget of %s", cluster, name, duration.Round(time.Second), budget)
+		default:
+			klog.V(1).Infof("Cluster %s: step %s took %s of its budget of %s", cluster, name, duration.Round(time.Second), budget)
+		}
+		update(func(e *Entry) {
+			e.Steps = append(e.Steps, Step{
+				Name:      name,
+				StartTime: start,
+				Duration:  duration.Seconds(),
+				Completed: completed,
+				Budget:    budget.Seconds(),
+			})
+		})
+	}()
+	body()
+	completed = true
+}
+
+// NewLatency computes the distribution of the durations,
+// missing is the number of operations which did not complete.
+func NewLatency(durations []time.Duration, missing int) Latency {
+	l := Latency{Count: len(durations), Missing: missing}
+	if len(durations) == 0 {
+		return l
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1].Seconds()
+	}
+	l.P50 = percentile(50)
+	l.P90 = percentile(90)
+	l.P99 = percentile(99)
+	l.Max = sorted[len(sorted)-1].Seconds()
+	return l
+}
+
+// RecordLatency records the latency of an operation repeated by the running spec.
+func RecordLatency(name string, l Latency) {
+	update(func(e *Entry) {
+		if e.Latencies == nil {
+			e.Latencies = map[string]Latency{}
+		}
+		e.Latencies[name] = l
+	})
+}
@@ -0,0 +1,125 @@
+package reports
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	"k8s.io/klog"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+)
+
+// Report is the JSON report of a suite run.
+type Report struct {
+	Suite     string    `json:"suite"`
+	Node      int       `json:"node"`
+	StartTime time.Time `json:"startTime"`
+	//The duration in seconds
+	Duration float64  `json:"duration"`
+	Entries  []*Entry `json:"entries"`
+	//The number of failed specs per failure tag
+	Failures map[string]int `json:"failures,omitempty"`
+}
+
+// JSONReporter is a ginkgo reporter writing a JSON report
+// with the data recorded by the specs.
+type JSONReporter struct {
+	path   string
+	report Report
+	failed []*failures.Failure
+}
+
+// NewJSONReporter creates a ginkgo reporter writing the JSON report in path.
+func NewJSONReporter(path string) *JSONReporter {
+	return &JSONReporter{
+		path: path,
+	}
+}
+
+func (r *JSONReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+	r.report = Report{
+		Suite:     summary.SuiteDescription,
+		Node:      config.ParallelNode,
+		StartTime: time.Now(),
+		Entries:   make([]*Entry, 0),
+	}
+}
+
+func (r *JSONReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (r *JSONReporter) SpecWillRun(specSummary *types.SpecSummary) {
+	texts := specSummary.ComponentTexts
+	if len(texts) > 1 {
+		texts = texts[1:]
+	}
+	begin(strings.Join(texts, " "))
+}
+
+func (r *JSONReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+	e := end()
+	if e == nil || specSummary.State == types.SpecStateSkipped || specSummary.State == types.SpecStatePending {
+		return
+	}
+	e.State = specState(specSummary.State)
+	e.Duration = specSummary.RunTime.Seconds()
+	if specSummary.State.IsFailure() {
+		failure, ok := failures.Parse(specSummary.Failure.Message)
+		if !ok {
+			failure = &failures.Failure{Message: specSummary.Failure.Message}
+		}
+		e.FailureTag = failure.Tag
+		e.FailureReason = failure.Reason
+		e.FailureMessage = failure.Message
+		if failure.Link != "" {
+			e.Links["failureAnalysis"] = failure.Link
+		}
+		r.failed = append(r.failed, failure)
+	}
+	r.report.Entries = append(r.report.Entries, e)
+}
+
+func (r *JSONReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (r *JSONReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	r.report.Duration = time.Since(r.report.StartTime).Seconds()
+	if len(r.failed) != 0 {
+		r.report.Failures = failures.Aggregate(r.failed)
+	}
+	b, err := json.MarshalIndent(r.report, "", "  ")
+	if err != nil {
+		klog.Errorf("failed to marshal the report: %s", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		klog.Errorf("failed to create the report directory: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(r.path, b, 0644); err != nil {
+		klog.Errorf("failed to write the report %s: %s", r.path, err)
+	}
+}
+
+func specState(state types.SpecState) string {
+	switch state {
+	case types.SpecStatePending:
+		return "pending"
+	case types.SpecStateSkipped:
+		return "skipped"
+	case types.SpecStatePassed:
+		return "passed"
+	case types.SpecStateFailed:
+		return "failed"
+	case types.SpecStatePanicked:
+		return "panicked"
+	case types.SpecStateTimedOut:
+		return "timedout"
+	default:
+		return "invalid"
+	}
+}
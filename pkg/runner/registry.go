@@ -0,0 +1,206 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Requirement is an option of the options.yaml required by a test group
+type Requirement string
+
+const (
+	// RequireHub requires the base domain of the hub
+	RequireHub Requirement = "hub"
+	// RequireManagedClusters requires at least one cluster to import
+	RequireManagedClusters Requirement = "clusters"
+	// RequireCloudConnection requires the cloud connection of each cloud provider of the run
+	RequireCloudConnection Requirement = "cloudConnection"
+	// RequireUpgrade requires the desired version of the upgrade
+	RequireUpgrade Requirement = "upgrade"
+	// RequireHostedCluster requires the release image of the hosted cluster
+	RequireHostedCluster Requirement = "hostedCluster"
+)
+
+// Group is a group of tests run by a compiled ginkgo test binary
+type Group struct {
+	// Name of the group, used on the command line and as TEST_GROUP
+	Name string
+	// Aliases are other names of the group (ie: the former TEST_GROUP values)
+	Aliases []string
+	// Description is printed by the list command
+	Description string
+	// Binary is the path of the compiled test binary relative to the test directory
+	Binary string
+	// Focus is the default ginkgo focus
+	Focus string
+	// Nodes is the default number of parallel ginkgo nodes, 0 to run in serial
+	Nodes int
+	// Clouds is the default list of cloud providers, empty if the group is not per cloud provider
+	Clouds []string
+	// Owner is true if the created resources are prefixed by the owner
+	Owner bool
+	// Requires are the options needed by the group
+	Requires []Requirement
+}
+
+var groups = map[string]*Group{}
+
+// Register adds a group to the registry, it panics if the name or an alias is already registered.
+func Register(g *Group) {
+	for _, name := range append([]string{g.Name}, g.Aliases...) {
+		if _, ok := groups[name]; ok {
+			panic(fmt.Sprintf("test group %s already registered", name))
+		}
+		groups[name] = g
+	}
+}
+
+// Lookup returns the group registered with that name or alias.
+func Lookup(name string) (*Group, error) {
+	g, ok := groups[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown test group %q", name)
+	}
+	return g, nil
+}
+
+// Groups returns the registered groups sorted by name.
+func Groups() []*Group {
+	l := make([]*Group, 0, len(groups))
+	for name, g := range groups {
+		if name == g.Name {
+			l = append(l, g)
+		}
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
+var clouds = []string{"aws", "azure", "gcp"}
+
+func init() {
+	Register(&Group{
+		Name:        "import",
+		Description: "import an existing cluster",
+		Binary:      "import_cluster/import_cluster.test",
+		Focus:       "import",
+		Nodes:       4,
+		Requires:    []Requirement{RequireHub, RequireManagedClusters},
+	})
+	Register(&Group{
+		Name:        "provision",
+		Aliases:     []string{"provision-all"},
+		Description: "provision aws, gcp, azure clusters in parallel",
+		Binary:      "create_cluster/create_cluster.test",
+		Focus:       "create",
+		Nodes:       3,
+		Clouds:      clouds,
+		Owner:       true,
+		Requires:    []Requirement{RequireHub, RequireCloudConnection},
+	})
+	Register(&Group{
+		Name:        "destroy",
+		Description: "detach the imported clusters and destroy the provisioned clusters",
+		Binary:      "detach_destroy/detach_destroy.test",
+		Focus:       "detach|destroy",
+		Nodes:       4,
+		Clouds:      clouds,
+		Owner:       true,
+		Requires:    []Requirement{RequireHub},
+	})
+	Register(&Group{
+		Name:        "metrics",
+		Description: "test the clusterlifecycle metrics from prometheus",
+		Binary:      "metrics/metrics.test",
+		Focus:       "metrics",
+		Requires:    []Requirement{RequireHub},
+	})
+	Register(&Group{
+		Name:        "create-baremetal",
+		Description: "provision a baremetal cluster",
+		Binary:      "create_cluster_bm/create_cluster_bm.test",
+		Focus:       "create",
+		Clouds:      []string{"baremetal"},
+		Requires:    []Requirement{RequireHub, RequireCloudConnection},
+	})
+	Register(&Group{
+		Name:        "destroy-baremetal",
+		Description: "destroy the baremetal cluster",
+		Binary:      "destroy_bm/destroy_bm.test",
+		Focus:       "destroy",
+		Clouds:      []string{"baremetal"},
+		Requires:    []Requirement{RequireHub},
+	})
+	Register(&Group{
+		Name:        "hibernate",
+		Description: "hibernate and resume the clusters provisioned by provision, to run before destroy",
+		Binary:      "hibernate_resume/hibernate_resume.test",
+		Focus:       "hibernate",
+		Nodes:       3,
+		Clouds:      clouds,
+		Owner:       true,
+		Requires:    []Requirement{RequireHub},
+	})
+	Register(&Group{
+		Name:        "clusterpool",
+		Description: "create clusterPools, claim a cluster from each pool and release it",
+		Binary:      "clusterpool/clusterpool.test",
+		Focus:       "clusterpool",
+		Nodes:       3,
+		Clouds:      clouds,
+		Owner:       true,
+		Requires:    []Requirement{RequireHub, RequireCloudConnection},
+	})
+	Register(&Group{
+		Name:        "upgrade",
+		Description: "upgrade the clusters provisioned by provision with a clusterCurator, to run before destroy",
+		Binary:      "upgrade/upgrade.test",
+		Focus:       "upgrade",
+		Nodes:       3,
+		Clouds:      clouds,
+		Owner:       true,
+		Requires:    []Requirement{RequireHub, RequireUpgrade},
+	})
+	Register(&Group{
+		Name:        "hypershift",
+		Description: "create a hosted cluster with the hypershift addon, check it is imported in hosted mode and destroy it",
+		Binary:      "hypershift/hypershift.test",
+		Focus:       "hypershift",
+		Owner:       true,
+		Requires:    []Requirement{RequireHub, RequireHostedCluster},
+	})
+	Register(&Group{
+		Name:        "scale",
+		Description: "register simulated clusters and manifestWorks and report the percentiles of their latencies",
+		Binary:      "scale/scale.test",
+		Focus:       "scale",
+		Owner:       true,
+		Requires:    []Requirement{RequireHub},
+	})
+	Register(&Group{
+		Name:        "placement",
+		Description: "schedule placements with predicates and prioritizers on a managedClusterSet of simulated and test clusters",
+		Binary:      "placement/placement.test",
+		Focus:       "placement",
+		Owner:       true,
+		Requires:    []Requirement{RequireHub},
+	})
+	Register(&Group{
+		Name:        "manifestwork",
+		Description: "deliver a manifestWork to each imported cluster and check its status feedback, update and deletion on the cluster",
+		Binary:      "manifestwork/manifestwork.test",
+		Focus:       "manifestwork",
+		Nodes:       4,
+		Owner:       true,
+		Requires:    []Requirement{RequireHub, RequireManagedClusters},
+	})
+	Register(&Group{
+		Name:        "addon-toggle",
+		Description: "disable each add-on of the klusterletAddonConfig of each imported cluster and enable it back",
+		Binary:      "addon_toggle/addon_toggle.test",
+		Focus:       "addon-toggle",
+		Nodes:       4,
+		Owner:       true,
+		Requires:    []Requirement{RequireHub, RequireManagedClusters},
+	})
+}
@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Settings are the settings of a run which override the defaults of the group
+type Settings struct {
+	// Ginkgo is the ginkgo command
+	Ginkgo string
+	// TestDir is the directory of the compiled test binaries
+	TestDir string
+	// OptionsFile is the options.yaml passed to the tests, the tests default apply if empty
+	OptionsFile string
+	// Focus overrides the focus of the group if not empty
+	Focus string
+	// Nodes overrides the parallelism of the group if positive
+	Nodes int
+	// Clouds overrides the cloud providers of the group if not empty
+	Clouds []string
+	// Owner is the prefix of the created resources
+	Owner string
+	// RunID is the label of the created resources
+	RunID string
+	// Verbosity is the klog verbosity of the tests
+	Verbosity int
+	// Args are extra arguments passed to the test binary
+	Args []string
+}
+
+// Plan is the ginkgo command run for a group
+type Plan struct {
+	Group  *Group
+	Clouds []string
+	Args   []string
+}
+
+// NewPlan computes the ginkgo command of the group with the settings.
+func NewPlan(g *Group, s Settings) (*Plan, error) {
+	clouds := g.Clouds
+	if len(s.Clouds) != 0 {
+		if len(g.Clouds) == 0 {
+			return nil, fmt.Errorf("test group %s does not run per cloud provider", g.Name)
+		}
+		clouds = s.Clouds
+	}
+	focus := g.Focus
+	if s.Focus != "" {
+		focus = s.Focus
+	}
+	nodes := g.Nodes
+	if s.Nodes > 0 {
+		nodes = s.Nodes
+	}
+	args := []string{s.Ginkgo, "-v", fmt.Sprintf("-focus=%s", focus)}
+	if nodes > 1 {
+		args = append(args, fmt.Sprintf("--nodes=%d", nodes))
+	}
+	args = append(args, "-trace", "-debug", filepath.Join(s.TestDir, g.Binary), "--", fmt.Sprintf("-v=%d", s.Verbosity))
+	if s.OptionsFile != "" {
+		optionsFile, err := filepath.Abs(s.OptionsFile)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, fmt.Sprintf("-options=%s", optionsFile))
+	}
+	if s.RunID != "" {
+		args = append(args, fmt.Sprintf("-run-id=%s", s.RunID))
+	}
+	if g.Owner && s.Owner != "" {
+		args = append(args, fmt.Sprintf("-owner=%s", s.Owner))
+	}
+	if len(clouds) != 0 {
+		args = append(args, fmt.Sprintf("-cloud-providers=%s", strings.Join(clouds, ",")))
+	}
+	args = append(args, s.Args...)
+	return &Plan{
+		Group:  g,
+		Clouds: clouds,
+		Args:   args,
+	}, nil
+}
+
+// String returns the plan as a shell command
+func (p *Plan) String() string {
+	quoted := make([]string, len(p.Args))
+	for i, arg := range p.Args {
+		if strings.ContainsAny(arg, " |'\"$") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Run runs the plan and returns the error of the ginkgo command
+func (p *Plan) Run() error {
+	cmd := exec.Command(p.Args[0], p.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	return cmd.Run()
+}
@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Validate loads the options file and returns all the problems of the options
+// required by the group for the cloud providers of the run.
+func Validate(g *Group, optionsFile string, clouds []string) []error {
+	if err := options.Load(optionsFile); err != nil {
+		return []error{fmt.Errorf("options can not be loaded: %v", err)}
+	}
+	options.Default()
+	var v options.Validation
+	for _, r := range g.Requires {
+		switch r {
+		case RequireHub:
+			v.Hub = true
+		case RequireManagedClusters:
+			v.ManagedClusters = true
+		case RequireCloudConnection:
+			v.CloudProviders = clouds
+		case RequireUpgrade:
+			v.Upgrade = true
+		case RequireHostedCluster:
+			v.HostedCluster = true
+		}
+	}
+	if err := v.Validate(); err != nil {
+		if agg, ok := err.(utilerrors.Aggregate); ok {
+			return agg.Errors()
+		}
+		return []error{err}
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package scale
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+)
+
+// ClusterNameLabel is set by the registration agent on its CSRs
+const ClusterNameLabel = "open-cluster-management.io/cluster-name"
+
+const (
+	// the lease renewed by the registration agent in the cluster namespace
+	leaseName = "managed-cluster-lease"
+	// the kubernetes version reported by the simulated clusters
+	kubernetesVersion = "v1.24.0-simulated"
+	// the period at which the waits of the agent are re-evaluated
+	resync = 30 * time.Second
+)
+
+var (
+	gvrManagedCluster = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
+	gvrManifestWork   = schema.GroupVersionResource{Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks"}
+	gvrCSR            = schema.GroupVersionResource{Group: "certificates.k8s.io", Version: "v1", Resource: "certificatesigningrequests"}
+)
+
+// Agent simulates the klusterlet of a managed cluster through the hub API.
+// The registration agent posts a CSR, joins the accepted managedCluster and renews its lease,
+// the work agent acknowledges the manifestWorks of the cluster namespace without applying them.
+// The agent uses the hub clients of the tests, the certificate issued for the CSR is not used.
+type Agent struct {
+	ClusterName string
+	//The cluster claims reported in the status of the managedCluster by name
+	ClusterClaims map[string]string
+	//The capacity and allocatable resources reported in the status of the managedCluster (ie: memory: 64Gi)
+	Resources map[string]string
+
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+	leaseDuration time.Duration
+	timeout       time.Duration
+	//The nanoseconds between the creation of the CSR and the issue of its certificate
+	csrLatency int64
+}
+
+// NewAgent creates the simulated agent of the cluster
+// leaseDuration: The period at which the lease is renewed
+// timeout: The time for the CSR to be signed and the managedCluster to be accepted
+func NewAgent(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface, clusterName string, leaseDuration, timeout time.Duration) *Agent {
+	return &Agent{
+		ClusterName:   clusterName,
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		leaseDuration: leaseDuration,
+		timeout:       timeout,
+	}
+}
+
+// CSRLatency returns the time between the creation of the CSR and the issue of its certificate,
+// 0 if the certificate was not issued.
+func (a *Agent) CSRLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&a.csrLatency))
+}
+
+// Run registers the cluster, then renews its lease and acknowledges its manifestWorks until the context is done.
+// It returns an error if the registration fails.
+func (a *Agent) Run(ctx context.Context) error {
+	if err := a.register(ctx); err != nil {
+		return err
+	}
+	if err := a.join(ctx); err != nil {
+		return err
+	}
+	go a.renewLease(ctx)
+	a.ackManifestWorks(ctx)
+	return nil
+}
+
+// register posts the CSR of the registration agent and waits for its certificate.
+func (a *Agent) register(ctx context.Context) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName: fmt.Sprintf("system:open-cluster-management:%s:%s", a.ClusterName, utilrand.String(5)),
+			Organization: []string{
+				"system:open-cluster-management:" + a.ClusterName,
+				"system:open-cluster-management:managed-clusters",
+			},
+		},
+	}, key)
+	if err != nil {
+		return err
+	}
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: a.ClusterName + "-",
+			Labels: map[string]string{
+				ClusterNameLabel: a.ClusterName,
+			},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageClientAuth,
+			},
+		},
+	}
+	ownership.Own(csr)
+	start := time.Now()
+	csr, err = a.kubeClient.CertificatesV1().CertificateSigningRequests().Create(ctx, csr, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("cluster %s: CSR can not be created: %v", a.ClusterName, err)
+	}
+	ownership.Record(gvrCSR, "CertificateSigningRequest", "", csr.Name)
+	klog.V(2).Infof("Cluster %s: CSR %s created", a.ClusterName, csr.Name)
+	err = waiters.NewWaiter(a.dynamicClient, resync).WaitFor(gvrCSR, "", csr.Name, a.timeout,
+		func(csr *unstructured.Unstructured) error {
+			if csr == nil {
+				return fmt.Errorf("not found")
+			}
+			if certificate, _, _ := unstructured.NestedString(csr.Object, "status", "certificate"); certificate == "" {
+				return fmt.Errorf("CSR %s: certificate not issued", csr.GetName())
+			}
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("cluster %s: %v", a.ClusterName, err)
+	}
+	latency := time.Since(start)
+	atomic.StoreInt64(&a.csrLatency, int64(latency))
+	klog.V(2).Infof("Cluster %s: CSR %s signed in %s", a.ClusterName, csr.Name, latency)
+	return nil
+}
+
+// join waits for the managedCluster to be accepted by the hub, then reports it joined and available.
+func (a *Agent) join(ctx context.Context) error {
+	if err := waiters.NewWaiter(a.dynamicClient, resync).WaitFor(gvrManagedCluster, "", a.ClusterName, a.timeout,
+		waiters.ConditionTrue("HubAcceptedManagedCluster")); err != nil {
+		return fmt.Errorf("cluster %s: not accepted: %v", a.ClusterName, err)
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		mc, err := a.dynamicClient.Resource(gvrManagedCluster).Get(ctx, a.ClusterName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, t := range []string{"ManagedClusterJoined", "ManagedClusterConditionAvailable"} {
+			if err := setConditionTrue(mc, t, 0); err != nil {
+				return err
+			}
+		}
+		if err := unstructured.SetNestedField(mc.Object, kubernetesVersion, "status", "version", "kubernetes"); err != nil {
+			return err
+		}
+		if err := a.setStatus(mc); err != nil {
+			return err
+		}
+		_, err = a.dynamicClient.Resource(gvrManagedCluster).UpdateStatus(ctx, mc, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cluster %s: status can not be updated: %v", a.ClusterName, err)
+	}
+	klog.V(2).Infof("Cluster %s: joined", a.ClusterName)
+	return nil
+}
+
+// setStatus reports the cluster claims and the resources of the cluster in the status of the managedCluster.
+func (a *Agent) setStatus(mc *unstructured.Unstructured) error {
+	if len(a.ClusterClaims) != 0 {
+		claims := make([]interface{}, 0, len(a.ClusterClaims))
+		for name, value := range a.ClusterClaims {
+			claims = append(claims, map[string]interface{}{"name": name, "value": value})
+		}
+		if err := unstructured.SetNestedSlice(mc.Object, claims, "status", "clusterClaims"); err != nil {
+			return err
+		}
+	}
+	if len(a.Resources) != 0 {
+		for _, field := range []string{"capacity", "allocatable"} {
+			if err := unstructured.SetNestedStringMap(mc.Object, a.Resources, "status", field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetAvailable reports the availability of the cluster as the status controller of the registration agent does
+// when the API server of the cluster is healthy or not. The lease is still renewed.
+func (a *Agent) SetAvailable(ctx context.Context, available bool) error {
+	status, reason := metav1.ConditionTrue, "ManagedClusterAvailable"
+	if !available {
+		status, reason = metav1.ConditionFalse, "ManagedClusterKubeAPIServerUnavailable"
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		mc, err := a.dynamicClient.Resource(gvrManagedCluster).Get(ctx, a.ClusterName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if err := setCondition(mc, "ManagedClusterConditionAvailable", status, reason, 0); err != nil {
+			return err
+		}
+		_, err = a.dynamicClient.Resource(gvrManagedCluster).UpdateStatus(ctx, mc, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cluster %s: availability can not be updated: %v", a.ClusterName, err)
+	}
+	klog.V(2).Infof("Cluster %s: available %s", a.ClusterName, status)
+	return nil
+}
+
+// renewLease renews the lease of the cluster every lease duration until the context is done.
+func (a *Agent) renewLease(ctx context.Context) {
+	ticker := time.NewTicker(a.leaseDuration)
+	defer ticker.Stop()
+	for {
+		if err := a.updateLease(ctx); err != nil && ctx.Err() == nil {
+			klog.V(2).Infof("Cluster %s: lease not renewed: %v", a.ClusterName, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Agent) updateLease(ctx context.Context) error {
+	leases := a.kubeClient.CoordinationV1().Leases(a.ClusterName)
+	now := metav1.NowMicro()
+	lease, err := leases.Get(ctx, leaseName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		// the lease is created by the registration controller of the hub, unless it is not running yet
+		leaseDurationSeconds := int32(a.leaseDuration.Seconds())
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      leaseName,
+				Namespace: a.ClusterName,
+			},
+			Spec: coordinationv1.LeaseSpec{
+				LeaseDurationSeconds: &leaseDurationSeconds,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// ackManifestWorks acknowledges the manifestWorks of the cluster namespace until the context is done.
+func (a *Agent) ackManifestWorks(ctx context.Context) {
+	informer := dynamicinformer.NewFilteredDynamicInformer(a.dynamicClient, gvrManifestWork, a.ClusterName, resync,
+		cache.Indexers{}, nil).Informer()
+	ack := func(obj interface{}) {
+		if mw, ok := obj.(*unstructured.Unstructured); ok {
+			if err := a.ackManifestWork(ctx, mw.DeepCopy()); err != nil && ctx.Err() == nil {
+				klog.V(2).Infof("Cluster %s: manifestWork %s not acknowledged: %v", a.ClusterName, mw.GetName(), err)
+			}
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ack,
+		UpdateFunc: func(_, obj interface{}) { ack(obj) },
+	})
+	informer.Run(ctx.Done())
+}
+
+// ackManifestWork reports the manifestWork applied and available for its generation,
+// a deleted manifestWork is released by removing its finalizers.
+func (a *Agent) ackManifestWork(ctx context.Context, mw *unstructured.Unstructured) error {
+	client := a.dynamicClient.Resource(gvrManifestWork).Namespace(a.ClusterName)
+	if mw.GetDeletionTimestamp() != nil {
+		if len(mw.GetFinalizers()) == 0 {
+			return nil
+		}
+		mw.SetFinalizers(nil)
+		_, err := client.Update(ctx, mw, metav1.UpdateOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if observedGeneration(mw, "Applied") == mw.GetGeneration() {
+		return nil
+	}
+	for _, t := range []string{"Applied", "Available"} {
+		if err := setConditionTrue(mw, t, mw.GetGeneration()); err != nil {
+			return err
+		}
+	}
+	// a conflict is retried on the update event of the newer version
+	_, err := client.UpdateStatus(ctx, mw, metav1.UpdateOptions{})
+	if err == nil {
+		klog.V(3).Infof("Cluster %s: manifestWork %s acknowledged", a.ClusterName, mw.GetName())
+	}
+	return err
+}
+
+// setConditionTrue sets the status condition conditionType of the object to True,
+// the other conditions are kept.
+func setConditionTrue(u *unstructured.Unstructured, conditionType string, generation int64) error {
+	return setCondition(u, conditionType, metav1.ConditionTrue, conditionType, generation)
+}
+
+// setCondition sets the status condition conditionType of the object, the other conditions are kept.
+func setCondition(u *unstructured.Unstructured, conditionType string, status metav1.ConditionStatus, reason string, generation int64) error {
+	conditions, _, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if err != nil {
+		return err
+	}
+	condition := map[string]interface{}{
+		"type":               conditionType,
+		"status":             string(status),
+		"reason":             reason,
+		"message":            "set by the simulated agent",
+		"lastTransitionTime": metav1.Now().UTC().Format(time.RFC3339),
+	}
+	if generation != 0 {
+		condition["observedGeneration"] = generation
+	}
+	found := false
+	for i, c := range conditions {
+		if c, ok := c.(map[string]interface{}); ok && c["type"] == conditionType {
+			if c["status"] == string(status) {
+				condition["lastTransitionTime"] = c["lastTransitionTime"]
+			}
+			conditions[i] = condition
+			found = true
+		}
+	}
+	if !found {
+		conditions = append(conditions, condition)
+	}
+	return unstructured.SetNestedSlice(u.Object, conditions, "status", "conditions")
+}
+
+// observedGeneration returns the generation observed by the status condition conditionType, -1 if not set.
+func observedGeneration(u *unstructured.Unstructured, conditionType string) int64 {
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		if c, ok := c.(map[string]interface{}); ok && c["type"] == conditionType && c["status"] == string(metav1.ConditionTrue) {
+			if generation, ok := c["observedGeneration"].(int64); ok {
+				return generation
+			}
+		}
+	}
+	return -1
+}
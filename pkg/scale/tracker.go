@@ -0,0 +1,168 @@
+package scale
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// Tracker measures the latency between the creation of objects and the observation
+// of their status condition set to True by a watch of the hub.
+type Tracker struct {
+	client        dynamic.Interface
+	gvr           schema.GroupVersionResource
+	conditionType string
+
+	mutex    sync.Mutex
+	created  map[string]time.Time
+	observed map[string]time.Duration
+}
+
+// NewTracker creates a Tracker of the conditionType of the objects of the gvr created by the run
+func NewTracker(client dynamic.Interface, gvr schema.GroupVersionResource, conditionType string) *Tracker {
+	return &Tracker{
+		client:        client,
+		gvr:           gvr,
+		conditionType: conditionType,
+		created:       map[string]time.Time{},
+		observed:      map[string]time.Duration{},
+	}
+}
+
+// Run watches the objects labeled with the run ID until the context is done.
+func (t *Tracker) Run(ctx context.Context) {
+	informer := dynamicinformer.NewFilteredDynamicInformer(t.client, t.gvr, metav1.NamespaceAll, resync, cache.Indexers{},
+		func(options *metav1.ListOptions) {
+			options.LabelSelector = ownership.RunIDLabel + "=" + ownership.RunID()
+		}).Informer()
+	observe := func(obj interface{}) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok || waiters.ConditionTrue(t.conditionType)(u) != nil {
+			return
+		}
+		now := time.Now()
+		key, _ := cache.MetaNamespaceKeyFunc(u)
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		created, ok := t.created[key]
+		if _, done := t.observed[key]; ok && !done {
+			t.observed[key] = now.Sub(created)
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    observe,
+		UpdateFunc: func(_, obj interface{}) { observe(obj) },
+	})
+	informer.Run(ctx.Done())
+}
+
+// Created records the creation of an object, it must be called before the object is created.
+func (t *Tracker) Created(namespace, name string) {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.created[key] = time.Now()
+}
+
+// Forget forgets the creation of an object which failed to be created.
+func (t *Tracker) Forget(namespace, name string) {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.created, key)
+}
+
+// Wait waits for the condition of all the objects created to be observed,
+// on timeout it returns an error with the number of objects not observed.
+func (t *Tracker) Wait(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		observed, created := t.count()
+		if observed == created {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: condition %s of %d of %d objects not observed within %s",
+				t.gvr.Resource, t.conditionType, created-observed, created, timeout)
+		case <-ticker.C:
+			klog.V(2).Infof("%s: condition %s observed for %d of %d objects", t.gvr.Resource, t.conditionType, observed, created)
+		}
+	}
+}
+
+func (t *Tracker) count() (observed, created int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.observed), len(t.created)
+}
+
+// Latency returns the distribution of the latencies observed,
+// the objects created but not observed are missing.
+func (t *Tracker) Latency() reports.Latency {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	durations := make([]time.Duration, 0, len(t.observed))
+	for _, d := range t.observed {
+		durations = append(durations, d)
+	}
+	return reports.NewLatency(durations, len(t.created)-len(t.observed))
+}
+
+// ApproveCSRs approves the CSRs of the simulated agents of the run until the context is done,
+// as the hub administrator does when accepting a cluster.
+func ApproveCSRs(ctx context.Context, kubeClient kubernetes.Interface) {
+	factory := informers.NewSharedInformerFactoryWithOptions(kubeClient, resync,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = fmt.Sprintf("%s=%s,%s", ownership.RunIDLabel, ownership.RunID(), ClusterNameLabel)
+		}))
+	informer := factory.Certificates().V1().CertificateSigningRequests().Informer()
+	approve := func(obj interface{}) {
+		csr, ok := obj.(*certificatesv1.CertificateSigningRequest)
+		if !ok || len(csr.Status.Conditions) != 0 {
+			return
+		}
+		csr = csr.DeepCopy()
+		csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+			Type:           certificatesv1.CertificateApproved,
+			Status:         corev1.ConditionTrue,
+			Reason:         "AutoApprovedByScaleTest",
+			Message:        "approved by the scale test for the simulated cluster " + csr.Labels[ClusterNameLabel],
+			LastUpdateTime: metav1.Now(),
+		})
+		_, err := kubeClient.CertificatesV1().CertificateSigningRequests().UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{})
+		if err != nil && ctx.Err() == nil {
+			klog.V(2).Infof("CSR %s not approved: %v", csr.Name, err)
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    approve,
+		UpdateFunc: func(_, obj interface{}) { approve(obj) },
+	})
+	informer.Run(ctx.Done())
+}
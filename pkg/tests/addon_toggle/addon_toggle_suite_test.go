@@ -0,0 +1,51 @@
+package addon_toggle
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/reporters"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
+	libgocmd "github.com/stolostron/library-e2e-go/pkg/cmd"
+	"k8s.io/klog"
+)
+
+func init() {
+	klog.SetOutput(GinkgoWriter)
+	klog.InitFlags(nil)
+
+	libgocmd.InitFlags(nil)
+	failures.InitFlags(nil)
+	ownership.InitFlags(nil)
+}
+
+var _ = BeforeSuite(func() {
+	Expect(options.InitVars()).To(BeNil())
+	Expect(options.Validation{
+		Hub:             true,
+		ManagedClusters: true,
+	}.Validate()).To(BeNil())
+})
+
+var _ = AfterEach(func() {
+	utils.CollectDiagnosticsOnFailure("/results")
+	utils.TeardownOnFailure()
+})
+
+var _ = AfterSuite(func() {
+	utils.Teardown()
+})
+
+func TestAddOnToggle(t *testing.T) {
+	RegisterFailHandler(Fail)
+	junitReporter := reporters.NewJUnitReporter(fmt.Sprintf("%s-%d.xml", "/results/result-addon-toggle", config.GinkgoConfig.ParallelNode))
+	jsonReporter := reports.NewJSONReporter(fmt.Sprintf("%s-%d.json", "/results/result-addon-toggle", config.GinkgoConfig.ParallelNode))
+	RunSpecsWithDefaultAndCustomReporters(t, "AddOn Toggle Suite", []Reporter{junitReporter, jsonReporter})
+}
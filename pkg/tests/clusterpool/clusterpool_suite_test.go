@@ -0,0 +1,58 @@
+package clusterpool
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/reporters"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
+	libgocmd "github.com/stolostron/library-e2e-go/pkg/cmd"
+	"k8s.io/klog"
+)
+
+var cloudProviders string
+
+func init() {
+	klog.SetOutput(GinkgoWriter)
+	klog.InitFlags(nil)
+
+	libgocmd.InitFlags(nil)
+	failures.InitFlags(nil)
+	ownership.InitFlags(nil)
+
+	flag.StringVar(&cloudProviders, "cloud-providers", "",
+		"A comma separated list of cloud providers (ie: aws,azure) "+
+			"If set only these cloud providers will be tested")
+}
+
+var _ = BeforeSuite(func() {
+	Expect(options.InitVars()).To(BeNil())
+	Expect(options.Validation{
+		Hub:            true,
+		CloudProviders: options.ParseCloudProviders(cloudProviders, "aws", "azure", "gcp"),
+	}.Validate()).To(BeNil())
+})
+
+var _ = AfterEach(func() {
+	utils.CollectDiagnosticsOnFailure("/results")
+	utils.TeardownOnFailure()
+})
+
+var _ = AfterSuite(func() {
+	utils.Teardown()
+})
+
+func TestClusterPool(t *testing.T) {
+	RegisterFailHandler(Fail)
+	junitReporter := reporters.NewJUnitReporter(fmt.Sprintf("%s-%d.xml", "/results/result-clusterpool", config.GinkgoConfig.ParallelNode))
+	jsonReporter := reports.NewJSONReporter(fmt.Sprintf("%s-%d.json", "/results/result-clusterpool", config.GinkgoConfig.ParallelNode))
+	RunSpecsWithDefaultAndCustomReporters(t, "ClusterPool Suite", []Reporter{junitReporter, jsonReporter})
+}
@@ -0,0 +1,20 @@
+// Copyright (c) 2020 Red Hat, Inc.
+
+package clusterpool
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
+)
+
+var _ = Describe("Cluster-lifecycle: ", func() {
+	utils.ClusterPoolLifecycle("aws", "OpenShift", cloudProviders)
+})
+
+var _ = Describe("Cluster-lifecycle: ", func() {
+	utils.ClusterPoolLifecycle("azure", "OpenShift", cloudProviders)
+})
+
+var _ = Describe("Cluster-lifecycle: ", func() {
+	utils.ClusterPoolLifecycle("gcp", "OpenShift", cloudProviders)
+})
@@ -4,17 +4,16 @@ package create_cluster
 
 import (
 	. "github.com/onsi/ginkgo"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
 )
 
+// The container bodies run once the flags are parsed, a cluster is created per requested cloud provider
 var _ = Describe("Cluster-lifecycle: ", func() {
-	utils.CreateCluster("aws", "OpenShift", cloudProviders)
-})
-
-var _ = Describe("Cluster-lifecycle: ", func() {
-	utils.CreateCluster("azure", "OpenShift", cloudProviders)
-})
-
-var _ = Describe("Cluster-lifecycle: ", func() {
-	utils.CreateCluster("gcp", "OpenShift", cloudProviders)
+	for _, cloud := range options.ParseCloudProviders(cloudProviders, "aws", "azure", "gcp") {
+		cloud := cloud
+		Describe(cloud, func() {
+			utils.CreateCluster(cloud, "OpenShift", cloudProviders)
+		})
+	}
 })
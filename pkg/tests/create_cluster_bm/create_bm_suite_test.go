@@ -9,6 +9,11 @@ import (
 	"github.com/onsi/ginkgo/config"
 	"github.com/onsi/ginkgo/reporters"
 	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
 	libgocmd "github.com/stolostron/library-e2e-go/pkg/cmd"
 	"k8s.io/klog"
 )
@@ -20,6 +25,8 @@ func init() {
 	klog.InitFlags(nil)
 
 	libgocmd.InitFlags(nil)
+	failures.InitFlags(nil)
+	ownership.InitFlags(nil)
 
 	flag.StringVar(&cloudProviders, "cloud-providers", "",
 		"A comma separated list of cloud providers (ie: aws,azure) "+
@@ -28,10 +35,25 @@ func init() {
 }
 
 var _ = BeforeSuite(func() {
+	Expect(options.InitVars()).To(BeNil())
+	Expect(options.Validation{
+		Hub:            true,
+		CloudProviders: options.ParseCloudProviders(cloudProviders, "baremetal"),
+	}.Validate()).To(BeNil())
+})
+
+var _ = AfterEach(func() {
+	utils.CollectDiagnosticsOnFailure("/results")
+	utils.TeardownOnFailure()
+})
+
+var _ = AfterSuite(func() {
+	utils.Teardown()
 })
 
 func TestCreateBM(t *testing.T) {
 	RegisterFailHandler(Fail)
 	junitReporter := reporters.NewJUnitReporter(fmt.Sprintf("%s-%d.xml", "/results/result-create-bm", config.GinkgoConfig.ParallelNode))
-	RunSpecsWithDefaultAndCustomReporters(t, "Create Baremetal Suite", []Reporter{junitReporter})
+	jsonReporter := reports.NewJSONReporter(fmt.Sprintf("%s-%d.json", "/results/result-create-bm", config.GinkgoConfig.ParallelNode))
+	RunSpecsWithDefaultAndCustomReporters(t, "Create Baremetal Suite", []Reporter{junitReporter, jsonReporter})
 }
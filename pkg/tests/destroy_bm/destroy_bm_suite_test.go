@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/manifest"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
 	libgocmd "github.com/stolostron/library-e2e-go/pkg/cmd"
 
 	. "github.com/onsi/ginkgo"
@@ -21,6 +26,9 @@ func init() {
 	klog.InitFlags(nil)
 
 	libgocmd.InitFlags(nil)
+	failures.InitFlags(nil)
+	ownership.InitFlags(nil)
+	manifest.InitFlags(nil)
 
 	flag.StringVar(&cloudProviders, "cloud-providers", "",
 		"A comma separated list of cloud providers (ie: aws,azure) "+
@@ -31,8 +39,18 @@ func init() {
 var _ = BeforeSuite(func() {
 })
 
+var _ = AfterEach(func() {
+	utils.CollectDiagnosticsOnFailure("/results")
+	utils.TeardownOnFailure()
+})
+
+var _ = AfterSuite(func() {
+	utils.Teardown()
+})
+
 func TestDetachDestroy(t *testing.T) {
 	RegisterFailHandler(Fail)
 	junitReporter := reporters.NewJUnitReporter(fmt.Sprintf("%s-%d.xml", "/results/result-destroy-bm", config.GinkgoConfig.ParallelNode))
-	RunSpecsWithDefaultAndCustomReporters(t, "Destroy baremetal Suite", []Reporter{junitReporter})
+	jsonReporter := reports.NewJSONReporter(fmt.Sprintf("%s-%d.json", "/results/result-destroy-bm", config.GinkgoConfig.ParallelNode))
+	RunSpecsWithDefaultAndCustomReporters(t, "Destroy baremetal Suite", []Reporter{junitReporter, jsonReporter})
 }
@@ -4,17 +4,16 @@ package detach_destroy
 
 import (
 	. "github.com/onsi/ginkgo"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
 )
 
+// The container bodies run once the flags are parsed, a cluster is destroyed per requested cloud provider
 var _ = Describe("Cluster-lifecycle: ", func() {
-	utils.DestroyCluster("aws", "OpenShift", cloudProviders)
-})
-
-var _ = Describe("Cluster-lifecycle: ", func() {
-	utils.DestroyCluster("azure", "OpenShift", cloudProviders)
-})
-
-var _ = Describe("Cluster-lifecycle: ", func() {
-	utils.DestroyCluster("gcp", "OpenShift", cloudProviders)
+	for _, cloud := range options.ParseCloudProviders(cloudProviders, "aws", "azure", "gcp") {
+		cloud := cloud
+		Describe(cloud, func() {
+			utils.DestroyCluster(cloud, "OpenShift", cloudProviders)
+		})
+	}
 })
@@ -5,19 +5,21 @@ package detach_destroy
 import (
 	"context"
 	"fmt"
-	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
 
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
 	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
 	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
 	libgodeploymentv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/deployment"
@@ -28,28 +30,37 @@ import (
 )
 
 var _ = Describe("Cluster-lifecycle: [P1][Sev1][cluster-lifecycle] Detach cluster", func() {
+	// the options are loaded to generate a spec per cluster to detach,
+	// each cluster is detached by its own spec which can run on a separate node
+	if err := options.InitVars(); err != nil {
+		It("Given a list of clusters to detach (cluster/g0/detach-service-resources)", func() {
+			Fail(fmt.Sprintf("options can not be loaded: %v", err))
+		})
+		return
+	}
+	for _, managedCluster := range libgooptions.TestOptions.Options.ManagedClusters {
+		detachCluster(managedCluster)
+	}
+})
 
-	var err error
-	var managedClusterKubeClient kubernetes.Interface
-	var managedClusterDynamicClient dynamic.Interface
-	var managedClusterDiscoveryClient *discovery.DiscoveryClient
-	var hubClients *clients.HubClients
+// detachCluster generates the spec detaching the cluster and checking its klusterlet is cleaned.
+func detachCluster(managedCluster libgooptions.Cluster) {
+	clusterName := managedCluster.Name
+	Describe(fmt.Sprintf("cluster %s", clusterName), func() {
+		var hubClients *clients.HubClients
 
-	BeforeEach(func() {
-		hubClients = clients.GetHubClients()
-	})
+		BeforeEach(func() {
+			hubClients = clients.GetHubClients()
+			reports.SetCluster(clusterName, "", "")
+		})
 
-	It("Given a list of clusters to detach (cluster/g0/detach-service-resources)", func() {
-		for _, managedCluster := range libgooptions.TestOptions.Options.ManagedClusters {
-			var clusterName = managedCluster.Name
-			klog.V(1).Infof("========================= Test cluster detach cluster %s ===============================", managedCluster.Name)
-			managedClusterKubeClient, err = libgoclient.NewDefaultKubeClient(managedCluster.KubeConfig)
-			Expect(err).To(BeNil())
-			managedClusterDynamicClient, err = libgoclient.NewDefaultKubeClientDynamic(managedCluster.KubeConfig)
+		It(fmt.Sprintf("Given the cluster %s to detach (cluster/g0/detach-service-resources)", clusterName), func() {
+			klog.V(1).Infof("========================= Test cluster detach cluster %s ===============================", clusterName)
+			managedClusterDynamicClient, err := libgoclient.NewDefaultKubeClientDynamic(managedCluster.KubeConfig)
 			Expect(err).To(BeNil())
 			managedClusterRestConfig, err := libgoconfig.LoadConfig("", managedCluster.KubeConfig, "")
 			Expect(err).To(BeNil())
-			managedClusterDiscoveryClient, err = discovery.NewDiscoveryClientForConfig(managedClusterRestConfig)
+			managedClusterDiscoveryClient, err := discovery.NewDiscoveryClientForConfig(managedClusterRestConfig)
 			Expect(err).To(BeNil())
 			Eventually(func() bool {
 				klog.V(1).Infof("Cluster %s: Check CRDs", clusterName)
@@ -92,103 +103,73 @@ var _ = Describe("Cluster-lifecycle: [P1][Sev1][cluster-lifecycle] Detach cluste
 				Expect(hubClients.DynamicClient.Resource(gvr).Delete(context.TODO(), clusterName, metav1.DeleteOptions{})).Should(BeNil())
 			})
 
-			klog.V(1).Infof("Cluster %s: Wait 20 min for cluster to go in Unknown state", clusterName)
-			time.Sleep(20 * time.Minute)
-
 			When(fmt.Sprintf("the detach of the cluster %s is requested, wait for the effective detach", clusterName), func() {
-				waitDetached(hubClients.DynamicClient, clusterName)
+				reports.TimeStep(reports.StepDetach, func() {
+					waitDetached(hubClients.DynamicClient, clusterName)
+				})
 			})
 
-			When("the namespace is deleted, check if managed cluster is well cleaned", func() {
-				By(fmt.Sprintf("Checking if the %s namespace is deleted", openClusterManagementAgentAddonNamespace), func() {
-					klog.V(1).Infof("Cluster %s: Checking if the %s is deleted", clusterName, openClusterManagementAgentAddonNamespace)
-					Eventually(func() bool {
-						klog.V(1).Infof("Cluster %s: Wait %s namespace deletion...", clusterName, openClusterManagementAgentAddonNamespace)
-						_, err := managedClusterKubeClient.CoreV1().Namespaces().Get(context.TODO(), openClusterManagementAgentAddonNamespace, metav1.GetOptions{})
-						if err != nil {
-							klog.V(1).Infof("Cluster %s: %s", clusterName, err)
-							return errors.IsNotFound(err)
-						}
-						err = utils.PrintLeftOver(managedClusterDynamicClient, managedClusterDiscoveryClient, openClusterManagementAgentAddonNamespace)
-						if err != nil {
-							klog.Error(err)
-						}
-						return false
-					}).Should(BeTrue())
+			if importSettings := options.ImportOf(clusterName); importSettings.Hosted() {
+				When(fmt.Sprintf("the cluster is detached, check the klusterlet is well cleaned on the hosting cluster %s", importSettings.HostingCluster), func() {
+					hostingClientDynamic, err := libgoclient.NewDefaultKubeClientDynamic(importSettings.HostingKubeConfig)
+					Expect(err).To(BeNil())
+					hostingRestConfig, err := libgoconfig.LoadConfig("", importSettings.HostingKubeConfig, "")
+					Expect(err).To(BeNil())
+					hostingClientDiscovery, err := discovery.NewDiscoveryClientForConfig(hostingRestConfig)
+					Expect(err).To(BeNil())
+					utils.WaitHostedKlusterletDeleted(hostingClientDynamic, hostingClientDiscovery, clusterName, failures.ScopeDetach)
 				})
-				By(fmt.Sprintf("Checking if the %s namespace is deleted", openClusterManagementAgentNamespace), func() {
-					klog.V(1).Infof("Cluster %s: Checking if the %s is deleted", clusterName, openClusterManagementAgentNamespace)
-					Eventually(func() bool {
-						klog.V(1).Infof("Cluster %s: Wait %s namespace deletion...", clusterName, openClusterManagementAgentNamespace)
-						_, err := managedClusterKubeClient.CoreV1().Namespaces().Get(context.TODO(), openClusterManagementAgentNamespace, metav1.GetOptions{})
-						if err != nil {
-							klog.V(1).Infof("Cluster %s: %s", clusterName, err)
-							return errors.IsNotFound(err)
-						}
-						err = utils.PrintLeftOver(managedClusterDynamicClient, managedClusterDiscoveryClient, openClusterManagementAgentNamespace)
-						if err != nil {
-							klog.Error(err)
-						}
-						return false
-					}).Should(BeTrue())
-				})
-				By(fmt.Sprintf("Checking if the %s crd is deleted", klusterletCRDName), func() {
-					klog.V(1).Infof("Cluster %s: Checking if the %s crd is deleted", clusterName, klusterletCRDName)
-					gvr := schema.GroupVersionResource{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "klusterlets"}
-					Eventually(func() error {
-						klog.V(1).Infof("Cluster %s: Wait %s crd deletion...", clusterName, klusterletCRDName)
-						_, err := managedClusterDynamicClient.Resource(gvr).Get(context.TODO(), klusterletCRDName, metav1.GetOptions{})
-						if errors.IsNotFound(err) {
-							return nil
-						}
-
-						return utils.GenerateErrorMsg(utils.NeedInvestigate, "", "klusterlet cleanup failed", "klusterlet CR can not be deleted")
-					}).Should(BeNil())
+			} else {
+				When("the namespace is deleted, check if managed cluster is well cleaned", func() {
+					By(fmt.Sprintf("Checking if the %s namespace is deleted", openClusterManagementAgentAddonNamespace), func() {
+						klog.V(1).Infof("Cluster %s: Checking if the %s is deleted", clusterName, openClusterManagementAgentAddonNamespace)
+						utils.WaitNamespaceDeleted(managedClusterDynamicClient, managedClusterDiscoveryClient, openClusterManagementAgentAddonNamespace, failures.ScopeDetach, eventuallyTimeout())
+					})
+					By(fmt.Sprintf("Checking if the %s namespace is deleted", openClusterManagementAgentNamespace), func() {
+						klog.V(1).Infof("Cluster %s: Checking if the %s is deleted", clusterName, openClusterManagementAgentNamespace)
+						utils.WaitNamespaceDeleted(managedClusterDynamicClient, managedClusterDiscoveryClient, openClusterManagementAgentNamespace, failures.ScopeDetach, eventuallyTimeout())
+					})
+					By(fmt.Sprintf("Checking if the %s crd is deleted", klusterletCRDName), func() {
+						klog.V(1).Infof("Cluster %s: Checking if the %s crd is deleted", clusterName, klusterletCRDName)
+						gvr := schema.GroupVersionResource{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "klusterlets"}
+						Expect(waiters.NewWaiter(managedClusterDynamicClient, eventuallyInterval()).WaitFor(gvr, "", klusterletCRDName, eventuallyTimeout(),
+							func(klusterlet *unstructured.Unstructured) error {
+								klog.V(1).Infof("Cluster %s: Wait %s crd deletion...", clusterName, klusterletCRDName)
+								if klusterlet == nil {
+									return nil
+								}
+								return failures.Classify(failures.ScopeDetach, "klusterlet cleanup failed", "klusterlet CR can not be deleted")
+							})).To(BeNil())
+					})
 				})
-			})
+			}
 
 			When("the deletion of the cluster is done, wait for the namespace deletion", func() {
 				By(fmt.Sprintf("Checking the deletion of the %s namespace on the hub", clusterName), func() {
 					klog.V(1).Infof("Cluster %s: Checking the deletion of the %s namespace on the hub", clusterName, clusterName)
-					Eventually(func() bool {
-						klog.V(1).Infof("Cluster %s: Wait %s namespace deletion...", clusterName, clusterName)
-						_, err := hubClients.KubeClient.CoreV1().Namespaces().Get(context.TODO(), clusterName, metav1.GetOptions{})
-						if err != nil {
-							klog.V(1).Infof("Cluster %s: %s", clusterName, err)
-							return errors.IsNotFound(err)
-						}
-						err = utils.PrintLeftOver(hubClients.DynamicClient, hubClients.DiscoveryClient, clusterName)
-						if err != nil {
-							klog.Error(err)
-						}
-						return false
-					}).Should(BeTrue())
+					reports.TimeStep(reports.StepNamespaceDeletion, func() {
+						utils.WaitNamespaceDeleted(hubClients.DynamicClient, hubClients.DiscoveryClient, clusterName, failures.ScopeDetach, eventuallyTimeout())
+					})
 					klog.V(1).Infof("Cluster %s: %s namespace deleted", clusterName, clusterName)
 				})
 			})
-		}
-
+		})
 	})
-})
+}
 
 func waitDetached(hubClientDynamic dynamic.Interface, clusterName string) {
 	By(fmt.Sprintf("Checking the deletion of the %s managedCluster on the hub", clusterName), func() {
 		klog.V(1).Infof("Cluster %s: Checking the deletion of the %s managedCluster on the hub", clusterName, clusterName)
 		gvr := schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
-		Eventually(func() bool {
-			klog.V(1).Infof("Cluster %s: Wait %s managedCluster deletion...", clusterName, clusterName)
-			_, err := hubClientDynamic.Resource(gvr).Get(context.TODO(), clusterName, metav1.GetOptions{})
-			if errors.IsNotFound(err) {
-				return true
-			}
-
-			if err != nil {
-				klog.Errorf("Cluster %s: Failed to get managed cluster: %v", clusterName, err)
-				return false
-			}
-
-			return false
-		}, 60*time.Second, 1*time.Second).Should(BeTrue())
+		klog.V(1).Infof("Cluster %s: Wait %s managedCluster deletion...", clusterName, clusterName)
+		Expect(waiters.NewWaiter(hubClientDynamic, eventuallyInterval()).WaitFor(gvr, "", clusterName, detachTimeout(),
+			func(managedCluster *unstructured.Unstructured) error {
+				if managedCluster == nil {
+					return nil
+				}
+				return failures.Classify(failures.ScopeDetach, "ManagedClusterNotDeleted",
+					fmt.Sprintf("managedCluster %s can not be deleted", clusterName))
+			})).To(BeNil())
 		klog.V(1).Infof("Cluster %s: %s managedCluster deleted", clusterName, clusterName)
 	})
 }
@@ -4,7 +4,14 @@ import (
 	"flag"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/manifest"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
 	libgocmd "github.com/stolostron/library-e2e-go/pkg/cmd"
 
 	. "github.com/onsi/ginkgo"
@@ -20,6 +27,11 @@ const (
 	openClusterManagementAgentAddonNamespace = "open-cluster-management-agent-addon"
 )
 
+// the timeouts and the interval of the waits, read from the timing profile of the options
+func detachTimeout() time.Duration      { return options.Timeout(options.TimeoutDetach) }
+func eventuallyTimeout() time.Duration  { return options.Timeout(options.TimeoutDefault) }
+func eventuallyInterval() time.Duration { return options.Interval() }
+
 var cloudProviders string
 
 func init() {
@@ -27,6 +39,9 @@ func init() {
 	klog.InitFlags(nil)
 
 	libgocmd.InitFlags(nil)
+	failures.InitFlags(nil)
+	ownership.InitFlags(nil)
+	manifest.InitFlags(nil)
 
 	flag.StringVar(&cloudProviders, "cloud-providers", "",
 		"A comma separated list of cloud providers (ie: aws,azure) "+
@@ -37,8 +52,18 @@ func init() {
 var _ = BeforeSuite(func() {
 })
 
+var _ = AfterEach(func() {
+	utils.CollectDiagnosticsOnFailure("/results")
+	utils.TeardownOnFailure()
+})
+
+var _ = AfterSuite(func() {
+	utils.Teardown()
+})
+
 func TestDetachDestroy(t *testing.T) {
 	RegisterFailHandler(Fail)
 	junitReporter := reporters.NewJUnitReporter(fmt.Sprintf("%s-%d.xml", "/results/result-detach-destroy", config.GinkgoConfig.ParallelNode))
-	RunSpecsWithDefaultAndCustomReporters(t, "DetachDestroy Suite", []Reporter{junitReporter})
+	jsonReporter := reports.NewJSONReporter(fmt.Sprintf("%s-%d.json", "/results/result-detach-destroy", config.GinkgoConfig.ParallelNode))
+	RunSpecsWithDefaultAndCustomReporters(t, "DetachDestroy Suite", []Reporter{junitReporter, jsonReporter})
 }
@@ -0,0 +1,20 @@
+// Copyright (c) 2020 Red Hat, Inc.
+
+package hibernate_resume
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
+)
+
+var _ = Describe("Cluster-lifecycle: ", func() {
+	utils.HibernateResumeCluster("aws", "OpenShift", cloudProviders)
+})
+
+var _ = Describe("Cluster-lifecycle: ", func() {
+	utils.HibernateResumeCluster("azure", "OpenShift", cloudProviders)
+})
+
+var _ = Describe("Cluster-lifecycle: ", func() {
+	utils.HibernateResumeCluster("gcp", "OpenShift", cloudProviders)
+})
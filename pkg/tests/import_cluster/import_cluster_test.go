@@ -5,14 +5,18 @@ package import_cluster
 import (
 	"context"
 	"fmt"
-	"time"
+	"io/ioutil"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -21,7 +25,12 @@ import (
 	"github.com/stolostron/applier/pkg/templateprocessor"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/appliers"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
 	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
 	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
 	libgodeploymentv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/deployment"
@@ -32,25 +41,46 @@ import (
 
 const (
 	_v1APIExtensionKubeMinVersion = "v1.16.0"
+
+	// the secret created in the cluster namespace to let the import controller import the cluster
+	autoImportSecretName     = "auto-import-secret"
+	autoImportSecretTemplate = "auto_import_secret_cr.yaml"
+
+	klusterletAddonConfigTemplate = "klusterlet_addon_config_cr.yaml"
 )
 
 var v1APIExtensionMinVersion = version.MustParseGeneric(_v1APIExtensionKubeMinVersion)
 
 var _ = Describe("Cluster-lifecycle: [P1][Sev1][cluster-lifecycle] Import cluster", func() {
-	var hubClients *clients.HubClients
+	// the options are loaded to generate a spec per cluster to import,
+	// each cluster is imported by its own spec which can run on a separate node
+	if err := options.InitVars(); err != nil {
+		It("Given a list of clusters to import (cluster/g0/import-service-resources)", func() {
+			Fail(fmt.Sprintf("options can not be loaded: %v", err))
+		})
+		return
+	}
+	for _, managedCluster := range libgooptions.TestOptions.Options.ManagedClusters {
+		importCluster(managedCluster)
+	}
+})
 
-	var err error
-	var managedClusterClient client.Client
+// importCluster generates the spec importing the cluster with its import mode.
+func importCluster(managedCluster libgooptions.Cluster) {
+	clusterName := managedCluster.Name
+	Describe(fmt.Sprintf("cluster %s", clusterName), func() {
+		var hubClients *clients.HubClients
 
-	BeforeEach(func() {
-		hubClients = clients.GetHubClients()
-	})
+		var err error
+		var managedClusterClient client.Client
 
-	It("Given a list of clusters to import (cluster/g0/import-service-resources)", func() {
-		hubApplier := appliers.GetHubAppliers(hubClients)
-		// for clusterName, clusterKubeconfig := range managedClustersForManualImport {
-		for _, managedCluster := range libgooptions.TestOptions.Options.ManagedClusters {
-			var clusterName = managedCluster.Name
+		BeforeEach(func() {
+			hubClients = clients.GetHubClients()
+			reports.SetCluster(clusterName, "", "")
+		})
+
+		It(fmt.Sprintf("Given the cluster %s to import (cluster/g0/import-service-resources)", clusterName), func() {
+			hubApplier := appliers.GetHubAppliers(hubClients)
 			klog.V(1).Infof("========================= Test cluster import cluster %s ===============================", clusterName)
 			managedClusterClient, err = libgoclient.NewDefaultClient(managedCluster.KubeConfig, client.Options{})
 			Expect(err).To(BeNil())
@@ -91,100 +121,184 @@ var _ = Describe("Cluster-lifecycle: [P1][Sev1][cluster-lifecycle] Import cluste
 			}).Should(BeNil())
 
 			By("creating the namespace in which the cluster will be imported", func() {
-				// Create the cluster NS on master
-				klog.V(1).Infof("Cluster %s: Creating the namespace in which the cluster will be imported", clusterName)
-				namespaces := hubClients.KubeClient.CoreV1().Namespaces()
-				_, err := namespaces.Get(context.TODO(), clusterName, metav1.GetOptions{})
-				if err != nil {
-					if errors.IsNotFound(err) {
-						Expect(namespaces.Create(context.TODO(), &corev1.Namespace{
-							ObjectMeta: metav1.ObjectMeta{
-								Name: clusterName,
-							},
-						}, metav1.CreateOptions{})).NotTo(BeNil())
-						Expect(namespaces.Get(context.TODO(), clusterName, metav1.GetOptions{})).NotTo(BeNil())
-					} else {
-						Fail(err.Error())
+				reports.TimeStep(reports.StepNamespace, func() {
+					// Create the cluster NS on master
+					klog.V(1).Infof("Cluster %s: Creating the namespace in which the cluster will be imported", clusterName)
+					namespaces := hubClients.KubeClient.CoreV1().Namespaces()
+					_, err := namespaces.Get(context.TODO(), clusterName, metav1.GetOptions{})
+					if err != nil {
+						if errors.IsNotFound(err) {
+							namespace := &corev1.Namespace{
+								ObjectMeta: metav1.ObjectMeta{
+									Name: clusterName,
+								},
+							}
+							ownership.Own(namespace)
+							Expect(namespaces.Create(context.TODO(), namespace, metav1.CreateOptions{})).NotTo(BeNil())
+							Expect(namespaces.Get(context.TODO(), clusterName, metav1.GetOptions{})).NotTo(BeNil())
+							ownership.Record(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, "Namespace", "", clusterName)
+						} else {
+							Fail(err.Error())
+						}
 					}
-				}
+				})
 			})
 
+			importSettings := options.ImportOf(clusterName)
 			By("creating the managedCluster and klusterletaddonconfig", func() {
 				klog.V(1).Infof("Cluster %s: Creating the managedCluster and klusterletaddonconfig", clusterName)
 				values := struct {
 					ManagedClusterName string
+					HostingCluster     string
 				}{
 					ManagedClusterName: clusterName,
+					HostingCluster:     importSettings.HostingCluster,
+				}
+				excluded := []string{autoImportSecretTemplate}
+				if importSettings.Hosted() {
+					// the add-ons are not deployed by the klusterletaddonconfig in hosted mode
+					klog.V(1).Infof("Cluster %s: Imported in hosted mode by %s", clusterName, importSettings.HostingCluster)
+					excluded = append(excluded, klusterletAddonConfigTemplate)
 				}
 				Expect(hubApplier.ImportApplier.CreateOrUpdateInPath(".",
-					nil,
+					excluded,
 					false,
 					values)).To(BeNil())
 			})
-			time.Sleep(10 * time.Second)
 
-			var importSecret *corev1.Secret
-			When("the managedcluster is created, wait for import secret", func() {
-				var err error
-				Eventually(func() error {
+			switch importSettings.ImportMode {
+			case options.ImportModeManual:
+				Expect(importSettings.Hosted()).To(BeFalse(), "the hosted mode requires an auto-import mode")
+				var importSecret *corev1.Secret
+				When("the managedcluster is created, wait for import secret", func() {
 					klog.V(1).Infof("Cluster %s: Wait import secret %s...", clusterName, clusterName)
+					gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+					Expect(waiters.NewWaiter(hubClients.DynamicClient, eventuallyInterval()).
+						WaitFor(gvr, clusterName, clusterName+"-import", eventuallyTimeout(), waiters.Exists())).To(BeNil())
 					importSecret, err = hubClients.KubeClient.CoreV1().Secrets(clusterName).Get(context.TODO(), clusterName+"-import", metav1.GetOptions{})
-					if err != nil {
-						klog.V(1).Infof("Cluster %s: %s", clusterName, err)
-					}
-					return err
-				}).Should(BeNil())
-				klog.V(1).Infof("Cluster %s: bootstrap import secret %s created", clusterName, clusterName+"-import")
-			})
+					Expect(err).To(BeNil())
+					klog.V(1).Infof("Cluster %s: bootstrap import secret %s created", clusterName, clusterName+"-import")
+				})
 
-			By("Launching the manual import", func() {
-				klog.V(1).Infof("Cluster %s: Apply the crds.yaml", clusterName)
-				isV1, err := isAPIExtensionV1(managedCluster.KubeConfig)
-				Expect(err).To(BeNil())
-				var importStringReader *templateprocessor.YamlStringReader
-				if isV1 {
-					klog.V(5).Infof("Cluster %s: importSecret.Data[v1]: %s\n", clusterName, importSecret.Data["crdsv1.yaml"])
-					importStringReader = templateprocessor.NewYamlStringReader(string(importSecret.Data["crdsv1.yaml"]), templateprocessor.KubernetesYamlsDelimiter)
-				} else {
-					klog.V(5).Infof("Cluster %s: importSecret.Data[v1beta1]: %s\n", clusterName, importSecret.Data["crdsv1beta1.yaml"])
-					importStringReader = templateprocessor.NewYamlStringReader(string(importSecret.Data["crdsv1beta1.yaml"]), templateprocessor.KubernetesYamlsDelimiter)
-				}
-				managedClusterApplier, err := applier.NewApplier(importStringReader, &templateprocessor.Options{}, managedClusterClient, nil, nil, nil)
-				Expect(err).To(BeNil())
-				Expect(managedClusterApplier.CreateOrUpdateInPath(".", nil, false, nil)).NotTo(HaveOccurred())
-				// Wait 2 sec to make sure the CRDs are effective. The UI does the same.
-				time.Sleep(2 * time.Second)
-				klog.V(1).Infof("Cluster %s: Apply the import.yaml", clusterName)
-				klog.V(5).Infof("Cluster %s: importSecret.Data[import.yaml]: %s\n", clusterName, importSecret.Data["import.yaml"])
-				importStringReader = templateprocessor.NewYamlStringReader(string(importSecret.Data["import.yaml"]), templateprocessor.KubernetesYamlsDelimiter)
-				managedClusterApplier, err = applier.NewApplier(importStringReader, &templateprocessor.Options{}, managedClusterClient, nil, nil, nil)
-				Expect(err).To(BeNil())
-				Expect(managedClusterApplier.CreateOrUpdateInPath(".", nil, false, nil)).NotTo(HaveOccurred())
-			})
+				By("Launching the manual import", func() {
+					klog.V(1).Infof("Cluster %s: Apply the crds.yaml", clusterName)
+					isV1, err := isAPIExtensionV1(managedCluster.KubeConfig)
+					Expect(err).To(BeNil())
+					var importStringReader *templateprocessor.YamlStringReader
+					crdGVR := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}
+					if isV1 {
+						klog.V(5).Infof("Cluster %s: importSecret.Data[v1]: %s\n", clusterName, importSecret.Data["crdsv1.yaml"])
+						importStringReader = templateprocessor.NewYamlStringReader(string(importSecret.Data["crdsv1.yaml"]), templateprocessor.KubernetesYamlsDelimiter)
+						crdGVR.Version = "v1"
+					} else {
+						klog.V(5).Infof("Cluster %s: importSecret.Data[v1beta1]: %s\n", clusterName, importSecret.Data["crdsv1beta1.yaml"])
+						importStringReader = templateprocessor.NewYamlStringReader(string(importSecret.Data["crdsv1beta1.yaml"]), templateprocessor.KubernetesYamlsDelimiter)
+						crdGVR.Version = "v1beta1"
+					}
+					managedClusterApplier, err := applier.NewApplier(importStringReader, &templateprocessor.Options{}, managedClusterClient, nil, nil, nil)
+					Expect(err).To(BeNil())
+					Expect(managedClusterApplier.CreateOrUpdateInPath(".", nil, false, nil)).NotTo(HaveOccurred())
+					// Make sure the CRDs are effective before creating the klusterlet.
+					klog.V(1).Infof("Cluster %s: Wait the %s crd to be established", clusterName, klusterletCRDName)
+					managedClusterDynamicClient, err := libgoclient.NewDefaultKubeClientDynamic(managedCluster.KubeConfig)
+					Expect(err).To(BeNil())
+					Expect(waiters.NewWaiter(managedClusterDynamicClient, eventuallyInterval()).
+						WaitFor(crdGVR, "", "klusterlets.operator.open-cluster-management.io", eventuallyTimeout(), waiters.ConditionTrue("Established"))).To(BeNil())
+					klog.V(1).Infof("Cluster %s: Apply the import.yaml", clusterName)
+					klog.V(5).Infof("Cluster %s: importSecret.Data[import.yaml]: %s\n", clusterName, importSecret.Data["import.yaml"])
+					importStringReader = templateprocessor.NewYamlStringReader(string(importSecret.Data["import.yaml"]), templateprocessor.KubernetesYamlsDelimiter)
+					managedClusterApplier, err = applier.NewApplier(importStringReader, &templateprocessor.Options{}, managedClusterClient, nil, nil, nil)
+					Expect(err).To(BeNil())
+					Expect(managedClusterApplier.CreateOrUpdateInPath(".", nil, false, nil)).NotTo(HaveOccurred())
+				})
+			case options.ImportModeAutoImportKubeconfig, options.ImportModeAutoImportToken:
+				By(fmt.Sprintf("Creating the auto-import-secret with the %s mode", importSettings.ImportMode), func() {
+					createAutoImportSecret(hubApplier.ImportApplier, clusterName, managedCluster, importSettings)
+				})
 
-			time.Sleep(1 * time.Minute)
+				When("the auto-import-secret is created, wait for the import controller to consume it", func() {
+					reports.TimeStep(reports.StepAutoImport, func() {
+						waitAutoImportSecretConsumed(hubClients.DynamicClient, clusterName)
+					})
+				})
+			default:
+				Fail(fmt.Sprintf("Cluster %s: unsupported import mode %q", clusterName, importSettings.ImportMode))
+			}
 
 			When(fmt.Sprintf("Import launched, wait for cluster %s to be ready", clusterName), func() {
 				utils.WaitClusterImported(hubClients.DynamicClient, clusterName)
 			})
 
-			time.Sleep(3 * time.Minute)
+			if importSettings.Hosted() {
+				When(fmt.Sprintf("Cluster %s ready, wait the hosted klusterlet manifestWork to be applied", clusterName), func() {
+					reports.TimeStep(reports.StepManifestWorks, func() {
+						checkHostedManifestWorkApplied(hubClients.DynamicClient, importSettings.HostingCluster, clusterName)
+					})
+				})
+
+				When(fmt.Sprintf("Cluster %s ready, check the klusterlet runs in hosted mode on %s", clusterName, importSettings.HostingCluster), func() {
+					hostingClientDynamic, err := libgoclient.NewDefaultKubeClientDynamic(importSettings.HostingKubeConfig)
+					Expect(err).To(BeNil())
+					hostingClient, err := libgoclient.NewDefaultKubeClient(importSettings.HostingKubeConfig)
+					Expect(err).To(BeNil())
+					utils.ValidateHostedKlusterlet(hubClients.DynamicClient, hostingClientDynamic, hostingClient,
+						importSettings.HostingCluster, clusterName, failures.ScopeImport)
+				})
+				return
+			}
+
 			When(fmt.Sprintf("Cluster %s ready, wait manifestWorks to be applied", clusterName), func() {
-				checkManifestWorksApplied(hubClients.DynamicClient, clusterName)
+				reports.TimeStep(reports.StepManifestWorks, func() {
+					checkManifestWorksApplied(hubClients.DynamicClient, clusterName)
+				})
 			})
 
-			klog.V(1).Infof("Cluster %s: Wait 3 min to settle", clusterName)
-			time.Sleep(3 * time.Minute)
-
 			When(fmt.Sprintf("Import launched, wait for Add-Ons %s to be available", clusterName), func() {
 				utils.WaitClusterAdddonsAvailable(hubClients.DynamicClient, clusterName)
 			})
-
-		}
-
+		})
 	})
+}
 
-})
+// createAutoImportSecret creates the auto-import-secret in the cluster namespace with the kubeconfig of the cluster
+// or with the token of the options and the API server URL of the cluster.
+func createAutoImportSecret(importApplier *applier.Applier, clusterName string, managedCluster libgooptions.Cluster, importSettings options.ManagedClusterImport) {
+	values := struct {
+		ManagedClusterName string
+		KubeConfig         string
+		Token              string
+		Server             string
+	}{
+		ManagedClusterName: clusterName,
+	}
+	if importSettings.ImportMode == options.ImportModeAutoImportKubeconfig {
+		b, err := ioutil.ReadFile(filepath.Clean(managedCluster.KubeConfig))
+		Expect(err).To(BeNil())
+		values.KubeConfig = string(b)
+	} else {
+		Expect(importSettings.Token).NotTo(BeEmpty())
+		values.Token = importSettings.Token
+		values.Server = managedCluster.ApiServerURL
+	}
+	klog.V(1).Infof("Cluster %s: Creating the %s with the %s mode", clusterName, autoImportSecretName, importSettings.ImportMode)
+	Expect(importApplier.CreateOrUpdateResource(autoImportSecretTemplate, values)).To(BeNil())
+}
+
+// waitAutoImportSecretConsumed waits for the import controller to delete the auto-import-secret,
+// which it does once the klusterlet is deployed on the cluster.
+func waitAutoImportSecretConsumed(hubClientDynamic dynamic.Interface, clusterName string) {
+	klog.V(1).Infof("Cluster %s: Wait the %s to be consumed...", clusterName, autoImportSecretName)
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	Expect(waiters.NewWaiter(hubClientDynamic, eventuallyInterval()).WaitFor(gvr, clusterName, autoImportSecretName, eventuallyTimeout(),
+		func(secret *unstructured.Unstructured) error {
+			if secret == nil {
+				return nil
+			}
+			return failures.Classify(failures.ScopeImport, "AutoImportSecretNotConsumed",
+				fmt.Sprintf("%s of the cluster %s not deleted by the import controller", autoImportSecretName, clusterName))
+		})).To(BeNil())
+	klog.V(1).Infof("Cluster %s: %s consumed and deleted", clusterName, autoImportSecretName)
+}
 
 func isAPIExtensionV1(kubeConfig string) (bool, error) {
 
@@ -5,7 +5,6 @@ package import_cluster
 import (
 	"context"
 	"fmt"
-	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -13,6 +12,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
 	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
 	libgodeploymentv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/deployment"
@@ -25,8 +25,8 @@ var _ = Describe("Cluster-lifecycle: [P1][Sev1][cluster-lifecycle] Check local-c
 
 	BeforeEach(func() {
 		hubClients = clients.GetHubClients()
-		SetDefaultEventuallyTimeout(15 * time.Minute)
-		SetDefaultEventuallyPollingInterval(10 * time.Second)
+		SetDefaultEventuallyTimeout(options.Timeout(options.TimeoutImportHub))
+		SetDefaultEventuallyPollingInterval(options.Interval())
 	})
 
 	It("Check if local-cluster is imported on hub", func() {
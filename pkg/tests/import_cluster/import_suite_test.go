@@ -1,19 +1,22 @@
 package import_cluster
 
 import (
-	"context"
 	"flag"
 	"fmt"
 	"testing"
-
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/config"
 	"github.com/onsi/ginkgo/reporters"
 	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
 	libgocmd "github.com/stolostron/library-e2e-go/pkg/cmd"
-	libgounstructuredv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/unstructured"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
@@ -24,8 +27,14 @@ const (
 	klusterletCRDName       = "klusterlet"
 	manifestWorkNamePostfix = "-klusterlet"
 	manifestWorkCRDSPostfix = "-crds"
+	// the manifestWork deploying the klusterlet of a cluster in hosted mode, in the namespace of the hosting cluster
+	manifestWorkHostedPostfix = "-hosted-klusterlet"
 )
 
+// the timeout and the interval of the waits, read from the timing profile of the options
+func eventuallyTimeout() time.Duration  { return options.Timeout(options.TimeoutDefault) }
+func eventuallyInterval() time.Duration { return options.Interval() }
+
 var cloudProviders string
 
 func init() {
@@ -33,6 +42,8 @@ func init() {
 	klog.InitFlags(nil)
 
 	libgocmd.InitFlags(nil)
+	failures.InitFlags(nil)
+	ownership.InitFlags(nil)
 
 	flag.StringVar(&cloudProviders, "cloud-providers", "",
 		"A comma separated list of cloud providers (ie: aws,azure) "+
@@ -43,63 +54,44 @@ func init() {
 var _ = BeforeSuite(func() {
 })
 
+var _ = AfterEach(func() {
+	utils.CollectDiagnosticsOnFailure("/results")
+	utils.TeardownOnFailure()
+})
+
+var _ = AfterSuite(func() {
+	utils.Teardown()
+})
+
 func TestImport(t *testing.T) {
 	RegisterFailHandler(Fail)
 	junitReporter := reporters.NewJUnitReporter(fmt.Sprintf("%s-%d.xml", "/results/result-import", config.GinkgoConfig.ParallelNode))
-	RunSpecsWithDefaultAndCustomReporters(t, "Import Suite", []Reporter{junitReporter})
+	jsonReporter := reports.NewJSONReporter(fmt.Sprintf("%s-%d.json", "/results/result-import", config.GinkgoConfig.ParallelNode))
+	RunSpecsWithDefaultAndCustomReporters(t, "Import Suite", []Reporter{junitReporter, jsonReporter})
 }
 
 func checkManifestWorksApplied(hubClientDynamic dynamic.Interface, clusterName string) {
-	manifestWorkCRDsName := clusterName + manifestWorkNamePostfix + manifestWorkCRDSPostfix
-	By(fmt.Sprintf("Checking manfestwork %s to be applied", manifestWorkCRDsName), func() {
-		klog.V(1).Infof("Cluster %s: Checking manfestwork %s to be applied", clusterName, manifestWorkCRDsName)
-		Eventually(func() error {
-			klog.V(1).Infof("Cluster %s: Wait manifestwork %s to be applied...", clusterName, manifestWorkCRDsName)
-			gvr := schema.GroupVersionResource{Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks"}
-			mwcrd, err := hubClientDynamic.Resource(gvr).Namespace(clusterName).Get(context.TODO(), manifestWorkCRDsName, metav1.GetOptions{})
-			if err != nil {
-				klog.V(4).Infof("Cluster %s: %s", clusterName, err)
-				return err
-			}
-
-			var condition map[string]interface{}
-			condition, err = libgounstructuredv1.GetConditionByType(mwcrd, "Applied")
-			if err != nil {
-				klog.V(4).Infof("Cluster %s: %s", clusterName, err)
-				return err
-			}
-			klog.V(4).Info(condition)
-			if v, ok := condition["status"]; ok && v == string(metav1.ConditionTrue) {
-				return nil
-			}
-			err = fmt.Errorf("Cluster %s: status not found or not true", clusterName)
-			klog.V(4).Infof("Cluster %s: %s", clusterName, err)
-			return err
-		}).Should(BeNil())
-		klog.V(1).Infof("Cluster %s: manifestwork %s applied", clusterName, manifestWorkCRDsName)
-	})
+	gvr := schema.GroupVersionResource{Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks"}
+	for _, manifestWorkName := range []string{
+		clusterName + manifestWorkNamePostfix + manifestWorkCRDSPostfix,
+		clusterName + manifestWorkNamePostfix,
+	} {
+		By(fmt.Sprintf("Checking manfestwork %s to be applied", manifestWorkName), func() {
+			klog.V(1).Infof("Cluster %s: Wait manifestwork %s to be applied...", clusterName, manifestWorkName)
+			Expect(waiters.NewWaiter(hubClientDynamic, eventuallyInterval()).
+				WaitFor(gvr, clusterName, manifestWorkName, eventuallyTimeout(), waiters.ConditionTrue("Applied"))).To(BeNil())
+			klog.V(1).Infof("Cluster %s: manifestwork %s applied", clusterName, manifestWorkName)
+		})
+	}
+}
 
-	manifestWorkYAMLsName := clusterName + manifestWorkNamePostfix
-	By(fmt.Sprintf("Checking manfestwork %s to be applied", manifestWorkYAMLsName), func() {
-		klog.V(1).Infof("Cluster %s: Checking manfestwork %s to be applied", clusterName, manifestWorkYAMLsName)
-		Eventually(func() error {
-			klog.V(1).Infof("Cluster %s: Wait manifestwork %s to be applied...", clusterName, manifestWorkYAMLsName)
-			gvr := schema.GroupVersionResource{Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks"}
-			mwyaml, err := hubClientDynamic.Resource(gvr).Namespace(clusterName).Get(context.TODO(), manifestWorkYAMLsName, metav1.GetOptions{})
-			if err != nil {
-				klog.V(4).Info(err)
-				return err
-			}
-			var condition map[string]interface{}
-			condition, err = libgounstructuredv1.GetConditionByType(mwyaml, "Applied")
-			if err != nil {
-				return err
-			}
-			if v, ok := condition["status"]; ok && v == string(metav1.ConditionTrue) {
-				return nil
-			}
-			return fmt.Errorf("Cluster %s: status not found or not true", clusterName)
-		}).Should(BeNil())
-		klog.V(1).Infof("Cluster %s: manifestwork %s applied", clusterName, manifestWorkYAMLsName)
+func checkHostedManifestWorkApplied(hubClientDynamic dynamic.Interface, hostingCluster, clusterName string) {
+	gvr := schema.GroupVersionResource{Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks"}
+	manifestWorkName := clusterName + manifestWorkHostedPostfix
+	By(fmt.Sprintf("Checking manfestwork %s/%s to be applied", hostingCluster, manifestWorkName), func() {
+		klog.V(1).Infof("Cluster %s: Wait manifestwork %s/%s to be applied...", clusterName, hostingCluster, manifestWorkName)
+		Expect(waiters.NewWaiter(hubClientDynamic, eventuallyInterval()).
+			WaitFor(gvr, hostingCluster, manifestWorkName, eventuallyTimeout(), waiters.ConditionTrue("Applied"))).To(BeNil())
+		klog.V(1).Infof("Cluster %s: manifestwork %s/%s applied", clusterName, hostingCluster, manifestWorkName)
 	})
 }
@@ -0,0 +1,27 @@
+// Copyright (c) 2020 Red Hat, Inc.
+
+package manifestwork
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
+	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
+)
+
+var _ = Describe("Cluster-lifecycle: ", func() {
+	// the options are loaded to generate a spec per imported cluster
+	if err := options.InitVars(); err != nil {
+		It("Given a list of imported clusters (cluster/g1/manifestwork)", func() {
+			Fail(fmt.Sprintf("options can not be loaded: %v", err))
+		})
+		return
+	}
+	for _, managedCluster := range libgooptions.TestOptions.Options.ManagedClusters {
+		Describe(fmt.Sprintf("cluster %s", managedCluster.Name), func() {
+			utils.ManifestWorkDelivery(managedCluster.Name)
+		})
+	}
+})
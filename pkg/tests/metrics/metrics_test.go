@@ -10,7 +10,6 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
-	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -19,6 +18,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
 )
@@ -59,12 +59,13 @@ var _ = Describe("Cluster-lifecycle: [P2][Sev1][cluster-lifecycle] Check metrics
 	BeforeEach(func() {
 		prometheusQueryURL = fmt.Sprintf("%s.%s/%s", prometheusServiceURL, options.BaseDomain, metricQueryURI)
 		klog.Infoln("prometheusQueryURL:", prometheusQueryURL)
-		SetDefaultEventuallyTimeout(1 * time.Minute)
-		SetDefaultEventuallyPollingInterval(10 * time.Second)
+		SetDefaultEventuallyTimeout(options.Timeout(options.TimeoutMetrics))
+		SetDefaultEventuallyPollingInterval(options.Interval())
 	})
 
 	It("Check if local-cluster metrics are available  (cluster/g0/metrics)", func() {
 		clusterName := "local-cluster"
+		reports.SetCluster(clusterName, "", "")
 		klog.V(1).Infof("========================= Test cluster metrics hub %s ===============================", clusterName)
 		By(fmt.Sprintf("Checking cluster %s to be ready", clusterName), func() {
 			utils.WaitClusterImported(hubClients.DynamicClient, clusterName)
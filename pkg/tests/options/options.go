@@ -2,12 +2,16 @@ package options
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"k8s.io/klog"
 
-	"github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
 
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
 	libgocmd "github.com/stolostron/library-e2e-go/pkg/cmd"
 	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
 )
@@ -16,14 +20,226 @@ var BaseDomain string
 var KubeadminUser string
 var KubeadminCredential string
 
+// TestOptionsContainer holds the options of the options.yaml which are specific to this project,
+// the other options are loaded in libgooptions.TestOptions
+type TestOptionsContainer struct {
+	Options TestOptionsT `json:"options"`
+}
+
+type TestOptionsT struct {
+	Upgrade Upgrade `json:"upgrade,omitempty"`
+	//The directory of the templates overriding the embedded templates, in a sub-directory per scenario (ie: create)
+	TemplatesOverrideDir string `json:"templatesOverrideDir,omitempty"`
+	//The cloud connection of the cloud providers which are not supported by libgooptions
+	CloudConnection CloudConnection `json:"cloudConnection,omitempty"`
+	//The hosted cluster created through the hypershift addon in the hypershift tests
+	HostedCluster HostedCluster `json:"hostedCluster,omitempty"`
+	//The import settings of the clusters to import, read from the same clusters section as libgooptions
+	ManagedClusters []ManagedClusterImport `json:"clusters,omitempty"`
+	//The simulated clusters registered by the scale tests
+	Scale Scale `json:"scale,omitempty"`
+	//The overrides of the add-ons expected on the managed clusters
+	AddOns AddOns `json:"addons,omitempty"`
+	//The timeouts and intervals of the waits
+	Timing Timing `json:"timing,omitempty"`
+}
+
+// The modes of import of a cluster to import
+const (
+	// ImportModeManual applies the manifests of the import secret on the cluster
+	ImportModeManual = "manual"
+	// ImportModeAutoImportKubeconfig creates the auto-import-secret with the kubeconfig of the cluster
+	ImportModeAutoImportKubeconfig = "auto-import-kubeconfig"
+	// ImportModeAutoImportToken creates the auto-import-secret with a token and the API server URL of the cluster
+	ImportModeAutoImportToken = "auto-import-token"
+)
+
+// ImportModes are the supported import modes
+var ImportModes = []string{ImportModeManual, ImportModeAutoImportKubeconfig, ImportModeAutoImportToken}
+
+// ManagedClusterImport defines how a cluster of the clusters section is imported
+type ManagedClusterImport struct {
+	Name string `json:"name,omitempty"`
+	//The import mode, manual (default), auto-import-kubeconfig or auto-import-token
+	ImportMode string `json:"importMode,omitempty"`
+	//The token of a service account with the cluster-admin role used by the auto-import-token mode,
+	//the server is the apiServerURL of the cluster
+	Token string `json:"token,omitempty"`
+	//The name of the managedCluster running the klusterlet of the cluster in hosted mode,
+	//the klusterlet runs on the cluster if not set. The hosted mode requires an auto-import mode.
+	HostingCluster string `json:"hostingCluster,omitempty"`
+	//The kubeconfig of the hosting cluster, default the kubeconfig of the hub
+	HostingKubeConfig string `json:"hostingKubeconfig,omitempty"`
+}
+
+// Hosted returns true if the klusterlet of the cluster runs on a hosting cluster
+func (mc ManagedClusterImport) Hosted() bool {
+	return mc.HostingCluster != ""
+}
+
+// CloudConnection holds the API keys of the cloud providers not supported by libgooptions,
+// they are read from the same cloudConnection.apiKeys section of the options.yaml
+type CloudConnection struct {
+	APIKeys APIKeys `json:"apiKeys,omitempty"`
+}
+
+// APIKeys are the API keys of the vsphere, openstack and ibmcloud cloud providers
+type APIKeys struct {
+	VSphere   VSphereAPIKey   `json:"vsphere,omitempty"`
+	OpenStack OpenStackAPIKey `json:"openstack,omitempty"`
+	IBMCloud  IBMCloudAPIKey  `json:"ibmcloud,omitempty"`
+}
+
+// VSphereAPIKey defines the vCenter where the vsphere clusters are installed
+type VSphereAPIKey struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	//The vCenter host name
+	VCenter string `json:"vCenter,omitempty"`
+	//The PEM encoded CA certificates of the vCenter
+	CACertificate    string `json:"caCertificate,omitempty"`
+	Datacenter       string `json:"datacenter,omitempty"`
+	DefaultDatastore string `json:"defaultDatastore,omitempty"`
+	Cluster          string `json:"cluster,omitempty"`
+	Network          string `json:"network,omitempty"`
+	//The folder of the virtual machines, optional
+	Folder string `json:"folder,omitempty"`
+	//The CIDR of the network of the virtual machines, it contains the VIPs
+	MachineCIDR   string `json:"machineCIDR,omitempty"`
+	APIVIP        string `json:"apiVIP,omitempty"`
+	IngressVIP    string `json:"ingressVIP,omitempty"`
+	BaseDNSDomain string `json:"baseDnsDomain,omitempty"`
+}
+
+// OpenStackAPIKey defines the OpenStack cloud where the openstack clusters are installed
+type OpenStackAPIKey struct {
+	//The content of the clouds.yaml
+	CloudsYAML string `json:"cloudsYaml,omitempty"`
+	//The name of the cloud in the clouds.yaml
+	Cloud             string `json:"cloud,omitempty"`
+	ExternalNetwork   string `json:"externalNetwork,omitempty"`
+	ComputeFlavor     string `json:"computeFlavor,omitempty"`
+	APIFloatingIP     string `json:"apiFloatingIP,omitempty"`
+	IngressFloatingIP string `json:"ingressFloatingIP,omitempty"`
+	BaseDNSDomain     string `json:"baseDnsDomain,omitempty"`
+}
+
+// IBMCloudAPIKey defines the IBM Cloud account where the ibmcloud clusters are installed
+type IBMCloudAPIKey struct {
+	APIKey    string `json:"apiKey,omitempty"`
+	AccountID string `json:"accountID,omitempty"`
+	//The CRN of the Cloud Internet Services instance of the base domain
+	CISInstanceCRN string `json:"cisInstanceCRN,omitempty"`
+	Region         string `json:"region,omitempty"`
+	BaseDNSDomain  string `json:"baseDnsDomain,omitempty"`
+	//The directory of the credentials manifests generated by ccoctl for the manual credentials mode
+	CredentialsManifestsDir string `json:"credentialsManifestsDir,omitempty"`
+}
+
+// Upgrade defines the upgrade done by the clusterCurator
+type Upgrade struct {
+	//The OCP version to upgrade to (ie: 4.11.5), the upgrade tests are skipped if not set
+	DesiredVersion string `json:"desiredVersion,omitempty"`
+	//The channel to use for the upgrade (ie: stable-4.11)
+	Channel string `json:"channel,omitempty"`
+}
+
+// HostedCluster defines the hosted cluster created by the hypershift tests on the KubeVirt platform,
+// its control plane runs on the hosting cluster, a managedCluster of the hub with the hypershift addon
+type HostedCluster struct {
+	//The OCP release image of the hosted cluster (ie: quay.io/openshift-release-dev/ocp-release:4.12.0-x86_64),
+	//the hypershift tests are skipped if not set
+	ReleaseImage string `json:"releaseImage,omitempty"`
+	//The namespace of the hostedClusters on the hub, default clusters
+	Namespace string `json:"namespace,omitempty"`
+	//The name of the managedCluster of the hub where the hypershift addon is enabled, default local-cluster
+	HostingCluster string `json:"hostingCluster,omitempty"`
+	//The kubeconfig of the hosting cluster where the klusterlet runs in hosted mode, default the kubeconfig of the hub
+	HostingKubeConfig string `json:"hostingKubeconfig,omitempty"`
+	//The base domain of the hosted cluster, default apps.<hub base domain>
+	BaseDomain string `json:"baseDomain,omitempty"`
+	//The number of nodes of the nodePool, default 2
+	NodePoolReplicas int `json:"nodePoolReplicas,omitempty"`
+	//The cores and memory of the KubeVirt virtual machines of the nodes, default 2 and 8Gi
+	Cores  int    `json:"cores,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+// Scale defines the simulated managed clusters registered by the scale tests,
+// their klusterlets are simulated by goroutines of the test process through the hub API
+type Scale struct {
+	//The number of simulated clusters, default 100
+	Clusters int `json:"clusters,omitempty"`
+	//The number of manifestWorks created in each cluster namespace, default 1
+	ManifestWorks int `json:"manifestWorks,omitempty"`
+	//The lease duration of the simulated clusters, default 60
+	LeaseDurationSeconds int `json:"leaseDurationSeconds,omitempty"`
+	//The number of clusters created at once, default 20
+	Concurrency int `json:"concurrency,omitempty"`
+	//The queries per second to the hub of the simulated agents, default 100
+	QPS int `json:"qps,omitempty"`
+}
+
+// AddOns overrides the add-ons expected on the managed clusters, which are by default the add-ons enabled
+// by the klusterletAddonConfig of the cluster and the default add-ons having a clusterManagementAddOn on the hub
+type AddOns struct {
+	//The overrides, all the overrides matching a cluster are applied in order
+	Overrides []AddOnOverride `json:"overrides,omitempty"`
+}
+
+// AddOnOverride adds or removes expected add-ons on the clusters of a cloud and of a range of OCP versions
+type AddOnOverride struct {
+	//The cloud label of the managedClusters (ie: aws for the created clusters, Amazon for the imported ones),
+	//case insensitive, all the clouds if not set
+	Cloud string `json:"cloud,omitempty"`
+	//The minimum OCP version included (ie: 4.12), the clusters without OCP version don't match if set
+	MinVersion string `json:"minVersion,omitempty"`
+	//The maximum OCP version excluded (ie: 4.14), the clusters without OCP version don't match if set
+	MaxVersion string `json:"maxVersion,omitempty"`
+	//The add-ons expected in addition
+	Expected []string `json:"expected,omitempty"`
+	//The add-ons not expected
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+var TestOptions TestOptionsContainer
+
+// ImportOf returns the import settings of the cluster to import,
+// the manual mode if the cluster has no settings.
+func ImportOf(name string) ManagedClusterImport {
+	for _, mc := range TestOptions.Options.ManagedClusters {
+		if mc.Name == name {
+			return mc
+		}
+	}
+	return ManagedClusterImport{Name: name, ImportMode: ImportModeManual}
+}
+
 func InitVars() error {
 
-	err := libgooptions.LoadOptions(libgocmd.End2End.OptionsFile)
-	if err != nil {
-		klog.Errorf("--options error: %v", err)
+	if err := Load(libgocmd.End2End.OptionsFile); err != nil {
 		return err
 	}
 
+	Default()
+
+	if err := (Validation{Hub: true}).Validate(); err != nil {
+		return err
+	}
+
+	// an unreadable or invalid -failure-rules file fails the suite rather than being ignored
+	if err := failures.Load(); err != nil {
+		return err
+	}
+
+	klog.Infof("options:%#v", Redacted())
+	klog.Infof("test options:%#v", RedactedTestOptions())
+	reports.SetBudgets(StepBudgets(), Timeout(TimeoutDefault))
+	return nil
+}
+
+// Default sets the defaults of the loaded options
+func Default() {
 	if libgooptions.TestOptions.Options.Hub.KubeConfig == "" {
 		libgooptions.TestOptions.Options.Hub.KubeConfig = os.Getenv("KUBECONFIG")
 	}
@@ -34,8 +250,7 @@ func InitVars() error {
 		if libgooptions.TestOptions.Options.Hub.ApiServerURL == "" {
 			libgooptions.TestOptions.Options.Hub.ApiServerURL = fmt.Sprintf("https://api.%s:6443", libgooptions.TestOptions.Options.Hub.BaseDomain)
 		}
-	} else {
-		gomega.Expect(BaseDomain).NotTo(gomega.BeEmpty(), "The `baseDomain` is required.")
+	} else if BaseDomain != "" {
 		libgooptions.TestOptions.Options.Hub.BaseDomain = BaseDomain
 		libgooptions.TestOptions.Options.Hub.ApiServerURL = fmt.Sprintf("https://api.%s.%s:6443", libgooptions.TestOptions.Options.Hub.Name, BaseDomain)
 	}
@@ -47,6 +262,60 @@ func InitVars() error {
 		KubeadminCredential = libgooptions.TestOptions.Options.Hub.Password
 	}
 
+	hc := &TestOptions.Options.HostedCluster
+	if hc.Namespace == "" {
+		hc.Namespace = "clusters"
+	}
+	if hc.HostingCluster == "" {
+		hc.HostingCluster = "local-cluster"
+	}
+	if hc.HostingKubeConfig == "" {
+		hc.HostingKubeConfig = libgooptions.TestOptions.Options.Hub.KubeConfig
+	}
+	if hc.BaseDomain == "" && libgooptions.TestOptions.Options.Hub.BaseDomain != "" {
+		hc.BaseDomain = "apps." + libgooptions.TestOptions.Options.Hub.BaseDomain
+	}
+	if hc.NodePoolReplicas == 0 {
+		hc.NodePoolReplicas = 2
+	}
+	if hc.Cores == 0 {
+		hc.Cores = 2
+	}
+	if hc.Memory == "" {
+		hc.Memory = "8Gi"
+	}
+
+	if TestOptions.Options.Timing.Profile == "" {
+		TestOptions.Options.Timing.Profile = TimingProfileCloud
+	}
+
+	sc := &TestOptions.Options.Scale
+	if sc.Clusters == 0 {
+		sc.Clusters = 100
+	}
+	if sc.ManifestWorks == 0 {
+		sc.ManifestWorks = 1
+	}
+	if sc.LeaseDurationSeconds == 0 {
+		sc.LeaseDurationSeconds = 60
+	}
+	if sc.Concurrency == 0 {
+		sc.Concurrency = 20
+	}
+	if sc.QPS == 0 {
+		sc.QPS = 100
+	}
+
+	for i := range TestOptions.Options.ManagedClusters {
+		mc := &TestOptions.Options.ManagedClusters[i]
+		if mc.ImportMode == "" {
+			mc.ImportMode = ImportModeManual
+		}
+		if mc.Hosted() && mc.HostingKubeConfig == "" {
+			mc.HostingKubeConfig = libgooptions.TestOptions.Options.Hub.KubeConfig
+		}
+	}
+
 	if libgooptions.TestOptions.Options.ManagedClusters != nil && len(libgooptions.TestOptions.Options.ManagedClusters) > 0 {
 		for i, mc := range libgooptions.TestOptions.Options.ManagedClusters {
 			if mc.ApiServerURL == "" {
@@ -57,7 +326,31 @@ func InitVars() error {
 			}
 		}
 	}
+}
 
-	klog.Infof("options:%#v", libgooptions.TestOptions.Options)
+// Load loads the options.yaml in libgooptions.TestOptions and TestOptions without setting the defaults
+func Load(optionsFile string) error {
+	if err := libgooptions.LoadOptions(optionsFile); err != nil {
+		return err
+	}
+	if err := loadOptions(optionsFile); err != nil {
+		klog.Errorf("--options error: %v", err)
+		return err
+	}
 	return nil
 }
+
+// loadOptions loads the options specific to this project from the same file as libgooptions.LoadOptions
+func loadOptions(optionsFile string) error {
+	if optionsFile == "" {
+		optionsFile = os.Getenv("OPTIONS")
+	}
+	if optionsFile == "" {
+		optionsFile = "resources/options.yaml"
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(optionsFile))
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, &TestOptions)
+}
@@ -0,0 +1,57 @@
+package options
+
+import (
+	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
+)
+
+const redacted = "*****"
+
+// Redacted returns a copy of the loaded options where the credentials are masked, to be logged.
+// The options specific to this project are masked by RedactedTestOptions.
+func Redacted() libgooptions.TestOptionsT {
+	opts := libgooptions.TestOptions.Options
+	opts.Hub = redactCluster(opts.Hub)
+	opts.ManagedClusters = make([]libgooptions.Cluster, len(libgooptions.TestOptions.Options.ManagedClusters))
+	for i, mc := range libgooptions.TestOptions.Options.ManagedClusters {
+		opts.ManagedClusters[i] = redactCluster(mc)
+	}
+	redact(&opts.ImageRegistry.Password)
+	redact(&opts.CloudConnection.PullSecret)
+	redact(&opts.CloudConnection.SSHPrivateKey)
+	keys := &opts.CloudConnection.APIKeys
+	redact(&keys.AWS.AWSAccessSecret)
+	redact(&keys.GCP.ServiceAccountJSONKey)
+	redact(&keys.Azure.ClientSecret)
+	keys.BareMetal.Hosts = make([]libgooptions.Hosts, len(libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.Hosts))
+	for i, host := range libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.Hosts {
+		redact(&host.Bmc.Password)
+		keys.BareMetal.Hosts[i] = host
+	}
+	return opts
+}
+
+// RedactedTestOptions returns a copy of the loaded options specific to this project where the credentials are masked.
+func RedactedTestOptions() TestOptionsT {
+	opts := TestOptions.Options
+	keys := &opts.CloudConnection.APIKeys
+	redact(&keys.VSphere.Password)
+	redact(&keys.OpenStack.CloudsYAML)
+	redact(&keys.IBMCloud.APIKey)
+	opts.ManagedClusters = make([]ManagedClusterImport, len(TestOptions.Options.ManagedClusters))
+	for i, mc := range TestOptions.Options.ManagedClusters {
+		redact(&mc.Token)
+		opts.ManagedClusters[i] = mc
+	}
+	return opts
+}
+
+func redactCluster(c libgooptions.Cluster) libgooptions.Cluster {
+	redact(&c.Password)
+	return c
+}
+
+func redact(s *string) {
+	if *s != "" {
+		*s = redacted
+	}
+}
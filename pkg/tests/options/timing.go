@@ -0,0 +1,225 @@
+package options
+
+import (
+	"sort"
+	"time"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+)
+
+// The timeouts of the timing profiles, named after the waits using them
+const (
+	//The waits without a specific timeout (ie: import, add-ons, manifestWorks)
+	TimeoutDefault = "default"
+	//The import of the local-cluster
+	TimeoutImportHub = "importHub"
+	//The installation of a clusterDeployment
+	TimeoutInstall = "install"
+	//The deprovision of a clusterDeployment
+	TimeoutDeprovision = "deprovision"
+	//The deletion of a cluster namespace once its cluster is destroyed
+	TimeoutNamespaceDeletion = "namespaceDeletion"
+	//The detach of a managedCluster, the cluster can take up to 20 min to go in Unknown state before being detached
+	TimeoutDetach = "detach"
+	//The power state of a hibernated or resumed clusterDeployment
+	TimeoutPowerState = "powerState"
+	//The Unknown state of a hibernated managedCluster
+	TimeoutClusterUnknown = "clusterUnknown"
+	//The first cluster of a clusterPool ready to be claimed
+	TimeoutClusterPoolReady = "clusterPoolReady"
+	//The deletion of a clusterPool and of its clusters
+	TimeoutClusterPoolDeletion = "clusterPoolDeletion"
+	//The upgrade job of a clusterCurator
+	TimeoutCuratorJob = "curatorJob"
+	//The clusterVersion of an upgraded cluster
+	TimeoutClusterVersion = "clusterVersion"
+	//The availability of a hostedCluster
+	TimeoutHostedCluster = "hostedCluster"
+	//The nodes of a nodePool
+	TimeoutNodePool = "nodePool"
+	//The deletion of a hostedCluster
+	TimeoutHostedClusterDeletion = "hostedClusterDeletion"
+	//The metrics of the hub
+	TimeoutMetrics = "metrics"
+	//The simulated clusters of the scale tests to be available and their manifestWorks applied
+	TimeoutScale = "scale"
+)
+
+// The timing profiles
+const (
+	//A kind or fake hub where the clusters are not provisioned by a cloud provider
+	TimingProfileFastKind = "fast-kind"
+	//The clusters provisioned on aws, azure, gcp... (default)
+	TimingProfileCloud = "cloud"
+	//The clusters provisioned on baremetal hosts
+	TimingProfileBareMetal = "baremetal"
+)
+
+// TimingProfile defines the timeouts and the intervals of the waits
+type TimingProfile struct {
+	//The timeouts in seconds by name
+	Timeouts map[string]int
+	//The interval in seconds at which the waits re-check their condition
+	Interval int
+	//The interval in seconds of the long running operations such as the install or the deletion
+	LongInterval int
+}
+
+var cloudTimeouts = map[string]int{
+	TimeoutDefault:               600,
+	TimeoutImportHub:             900,
+	TimeoutInstall:               5400,
+	TimeoutDeprovision:           3600,
+	TimeoutNamespaceDeletion:     3600,
+	TimeoutDetach:                1500,
+	TimeoutPowerState:            1800,
+	TimeoutClusterUnknown:        900,
+	TimeoutClusterPoolReady:      5400,
+	TimeoutClusterPoolDeletion:   3600,
+	TimeoutCuratorJob:            1800,
+	TimeoutClusterVersion:        5400,
+	TimeoutHostedCluster:         1800,
+	TimeoutNodePool:              2700,
+	TimeoutHostedClusterDeletion: 1800,
+	TimeoutMetrics:               60,
+	TimeoutScale:                 1800,
+}
+
+// TimingProfiles are the supported timing profiles
+var TimingProfiles = map[string]TimingProfile{
+	TimingProfileFastKind: {
+		Timeouts: map[string]int{
+			TimeoutDefault:               120,
+			TimeoutImportHub:             300,
+			TimeoutInstall:               300,
+			TimeoutDeprovision:           300,
+			TimeoutNamespaceDeletion:     300,
+			TimeoutDetach:                300,
+			TimeoutPowerState:            300,
+			TimeoutClusterUnknown:        300,
+			TimeoutClusterPoolReady:      600,
+			TimeoutClusterPoolDeletion:   600,
+			TimeoutCuratorJob:            600,
+			TimeoutClusterVersion:        900,
+			TimeoutHostedCluster:         900,
+			TimeoutNodePool:              900,
+			TimeoutHostedClusterDeletion: 600,
+			TimeoutMetrics:               60,
+			TimeoutScale:                 900,
+		},
+		Interval:     2,
+		LongInterval: 10,
+	},
+	TimingProfileCloud: {
+		Timeouts:     cloudTimeouts,
+		Interval:     10,
+		LongInterval: 60,
+	},
+	// the baremetal hosts are inspected, provisioned and cleaned, which takes longer than the cloud instances
+	TimingProfileBareMetal: {
+		Timeouts: withTimeouts(cloudTimeouts, map[string]int{
+			TimeoutDefault:          900,
+			TimeoutInstall:          7200,
+			TimeoutDeprovision:      5400,
+			TimeoutPowerState:       3600,
+			TimeoutClusterPoolReady: 7200,
+			TimeoutClusterVersion:   7200,
+		}),
+		Interval:     10,
+		LongInterval: 60,
+	},
+}
+
+// withTimeouts returns the timeouts overridden by the overrides
+func withTimeouts(timeouts, overrides map[string]int) map[string]int {
+	m := make(map[string]int, len(timeouts))
+	for name, timeout := range timeouts {
+		m[name] = timeout
+	}
+	for name, timeout := range overrides {
+		m[name] = timeout
+	}
+	return m
+}
+
+// TimingProfileNames returns the sorted names of the timing profiles
+func TimingProfileNames() []string {
+	l := make([]string, 0, len(TimingProfiles))
+	for name := range TimingProfiles {
+		l = append(l, name)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// Timing selects the timing profile of the waits and overrides some of its values
+type Timing struct {
+	//The profile, fast-kind, cloud (default) or baremetal
+	Profile string `json:"profile,omitempty"`
+	//The timeouts in seconds overriding those of the profile by name (ie: install: 7200)
+	Timeouts map[string]int `json:"timeouts,omitempty"`
+	//The intervals in seconds overriding those of the profile
+	Interval     int `json:"interval,omitempty"`
+	LongInterval int `json:"longInterval,omitempty"`
+}
+
+// profile returns the timing profile of the options, the cloud profile if the profile is unknown
+func (t Timing) profile() TimingProfile {
+	if p, ok := TimingProfiles[t.Profile]; ok {
+		return p
+	}
+	return TimingProfiles[TimingProfileCloud]
+}
+
+// Timeout returns the timeout of the wait in the timing profile of the options or its override,
+// the default timeout for an unknown name.
+func Timeout(name string) time.Duration {
+	t := TestOptions.Options.Timing
+	timeout, ok := t.Timeouts[name]
+	if !ok {
+		timeout, ok = t.profile().Timeouts[name]
+	}
+	if !ok {
+		return Timeout(TimeoutDefault)
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// Interval returns the interval at which the waits re-check their condition
+func Interval() time.Duration {
+	t := TestOptions.Options.Timing
+	if t.Interval != 0 {
+		return time.Duration(t.Interval) * time.Second
+	}
+	return time.Duration(t.profile().Interval) * time.Second
+}
+
+// LongInterval returns the interval of the waits of the long running operations
+func LongInterval() time.Duration {
+	t := TestOptions.Options.Timing
+	if t.LongInterval != 0 {
+		return time.Duration(t.LongInterval) * time.Second
+	}
+	return time.Duration(t.profile().LongInterval) * time.Second
+}
+
+// StepBudgets returns the budgets of the steps of the report, the timeouts of the waits of the steps
+func StepBudgets() map[string]time.Duration {
+	return map[string]time.Duration{
+		reports.StepInstall:             Timeout(TimeoutInstall),
+		reports.StepDetach:              Timeout(TimeoutDetach),
+		reports.StepDestroy:             Timeout(TimeoutDeprovision),
+		reports.StepNamespaceDeletion:   Timeout(TimeoutNamespaceDeletion),
+		reports.StepHibernate:           Timeout(TimeoutPowerState),
+		reports.StepResume:              Timeout(TimeoutPowerState),
+		reports.StepClusterUnknown:      Timeout(TimeoutClusterUnknown),
+		reports.StepClusterPoolReady:    Timeout(TimeoutClusterPoolReady),
+		reports.StepCuratorUpgrade:      Timeout(TimeoutCuratorJob),
+		reports.StepClusterVersion:      Timeout(TimeoutClusterVersion),
+		reports.StepHostedCluster:       Timeout(TimeoutHostedCluster),
+		reports.StepNodePool:            Timeout(TimeoutNodePool),
+		reports.StepHostedClusterDelete: Timeout(TimeoutHostedClusterDeletion),
+		// the teardown deletes the cluster and its namespace
+		reports.StepTeardown: Timeout(TimeoutDeprovision) + Timeout(TimeoutNamespaceDeletion),
+	}
+}
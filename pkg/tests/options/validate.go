@@ -0,0 +1,368 @@
+package options
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
+	"k8s.io/apimachinery/pkg/api/resource"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
+)
+
+// The cloud providers for which the options can be validated
+var CloudProviders = []string{"aws", "azure", "gcp", "baremetal", "vsphere", "openstack", "ibmcloud"}
+
+// Validation defines which options are validated
+type Validation struct {
+	// Hub validates the hub base domain
+	Hub bool
+	// ManagedClusters validates the clusters to import
+	ManagedClusters bool
+	// CloudProviders validates the SSH keys and the API keys of each cloud provider
+	CloudProviders []string
+	// Upgrade validates the upgrade of the clusterCurator
+	Upgrade bool
+	// HostedCluster validates the hosted cluster of the hypershift tests
+	HostedCluster bool
+	// Scale validates the simulated clusters of the scale tests
+	Scale bool
+}
+
+// Validate validates the loaded options once defaulted
+// and returns an aggregate of all the problems found.
+func (v Validation) Validate() error {
+	opts := libgooptions.TestOptions.Options
+	errs := &validationErrors{}
+	if v.Hub {
+		errs.required("hub.baseDomain", opts.Hub.BaseDomain)
+	}
+	if v.ManagedClusters {
+		if len(opts.ManagedClusters) == 0 {
+			errs.add("clusters: at least one cluster is required")
+		}
+		for i, mc := range opts.ManagedClusters {
+			errs.required(fmt.Sprintf("clusters[%d].name", i), mc.Name)
+			errs.required(fmt.Sprintf("clusters[%d].kubeconfig", i), mc.KubeConfig)
+			// both lists are read from the same clusters section
+			if i < len(TestOptions.Options.ManagedClusters) {
+				validateImport(errs, i, TestOptions.Options.ManagedClusters[i], mc)
+			}
+		}
+	}
+	if len(v.CloudProviders) != 0 {
+		validateSSHKeys(errs, opts.CloudConnection)
+	}
+	keys := opts.CloudConnection.APIKeys
+	for _, cloud := range v.CloudProviders {
+		switch cloud {
+		case "aws":
+			validateAWS(errs, keys.AWS)
+		case "azure":
+			validateAzure(errs, keys.Azure)
+		case "gcp":
+			validateGCP(errs, keys.GCP)
+		case "baremetal":
+			validateBareMetal(errs, keys.BareMetal)
+		case "vsphere":
+			validateVSphere(errs, TestOptions.Options.CloudConnection.APIKeys.VSphere)
+		case "openstack":
+			validateOpenStack(errs, TestOptions.Options.CloudConnection.APIKeys.OpenStack)
+		case "ibmcloud":
+			validateIBMCloud(errs, TestOptions.Options.CloudConnection.APIKeys.IBMCloud)
+		default:
+			errs.add("cloud provider %q is not supported, it must be one of %s", cloud, strings.Join(CloudProviders, ","))
+		}
+	}
+	if dir := TestOptions.Options.TemplatesOverrideDir; dir != "" {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			errs.add("templatesOverrideDir %s is not a directory", dir)
+		}
+	}
+	validateAddOns(errs, TestOptions.Options.AddOns)
+	validateTiming(errs, TestOptions.Options.Timing)
+	if v.Upgrade {
+		errs.required("upgrade.desiredVersion", TestOptions.Options.Upgrade.DesiredVersion)
+	}
+	if v.HostedCluster {
+		validateHostedCluster(errs, TestOptions.Options.HostedCluster)
+	}
+	if v.Scale {
+		validateScale(errs, TestOptions.Options.Scale)
+	}
+	return utilerrors.NewAggregate(errs.errs)
+}
+
+// ParseCloudProviders returns the cloud providers of a comma separated list,
+// or the defaults if the list is empty.
+func ParseCloudProviders(cloudProviders string, defaults ...string) []string {
+	if cloudProviders == "" {
+		return defaults
+	}
+	return strings.Split(cloudProviders, ",")
+}
+
+type validationErrors struct {
+	errs []error
+}
+
+func (e *validationErrors) add(format string, a ...interface{}) {
+	e.errs = append(e.errs, fmt.Errorf(format, a...))
+}
+
+func (e *validationErrors) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		e.add("%s is required", field)
+	}
+}
+
+func validateSSHKeys(errs *validationErrors, cc libgooptions.CloudConnection) {
+	errs.required("cloudConnection.sshPublickey", cc.SSHPublicKey)
+	errs.required("cloudConnection.sshPrivatekey", cc.SSHPrivateKey)
+	if cc.SSHPublicKey != "" && len(strings.Fields(cc.SSHPublicKey)) < 2 {
+		errs.add("cloudConnection.sshPublickey must be in the authorized_keys format (ie: ssh-rsa AAAA...)")
+	}
+	if cc.SSHPrivateKey != "" && !strings.Contains(cc.SSHPrivateKey, "PRIVATE KEY-----") {
+		errs.add("cloudConnection.sshPrivatekey must be a PEM encoded private key")
+	}
+}
+
+func validateAWS(errs *validationErrors, key libgooptions.AWSAPIKey) {
+	errs.required("cloudConnection.apiKeys.aws.awsAccessKeyID", key.AWSAccessKeyID)
+	errs.required("cloudConnection.apiKeys.aws.awsSecretAccessKeyID", key.AWSAccessSecret)
+	errs.required("cloudConnection.apiKeys.aws.baseDnsDomain", key.BaseDNSDomain)
+	errs.required("cloudConnection.apiKeys.aws.region", key.Region)
+}
+
+func validateAzure(errs *validationErrors, key libgooptions.AzureAPIKey) {
+	errs.required("cloudConnection.apiKeys.azure.azureBaseDomainRGN", key.BaseDomainRGN)
+	errs.required("cloudConnection.apiKeys.azure.baseDnsDomain", key.BaseDNSDomain)
+	errs.required("cloudConnection.apiKeys.azure.subscriptionID", key.SubscriptionID)
+	errs.required("cloudConnection.apiKeys.azure.clientID", key.ClientID)
+	errs.required("cloudConnection.apiKeys.azure.clientSecret", key.ClientSecret)
+	errs.required("cloudConnection.apiKeys.azure.tenantID", key.TenantID)
+	errs.required("cloudConnection.apiKeys.azure.region", key.Region)
+}
+
+func validateGCP(errs *validationErrors, key libgooptions.GCPAPIKey) {
+	errs.required("cloudConnection.apiKeys.gcp.gcpProjectID", key.ProjectID)
+	errs.required("cloudConnection.apiKeys.gcp.gcpServiceAccountJsonKey", key.ServiceAccountJSONKey)
+	if strings.TrimSpace(key.ServiceAccountJSONKey) != "" && !json.Valid([]byte(key.ServiceAccountJSONKey)) {
+		errs.add("cloudConnection.apiKeys.gcp.gcpServiceAccountJsonKey must be a JSON document")
+	}
+	errs.required("cloudConnection.apiKeys.gcp.baseDnsDomain", key.BaseDNSDomain)
+	errs.required("cloudConnection.apiKeys.gcp.region", key.Region)
+}
+
+func validateBareMetal(errs *validationErrors, key libgooptions.BareMetalAPIKey) {
+	errs.required("cloudConnection.apiKeys.baremetal.clusterName", key.ClusterName)
+	errs.required("cloudConnection.apiKeys.baremetal.baseDnsDomain", key.BaseDNSDomain)
+	errs.required("cloudConnection.apiKeys.baremetal.libvirtURI", key.LibvirtURI)
+	for _, vip := range []struct{ field, value string }{
+		{"cloudConnection.apiKeys.baremetal.apiVIP", key.APIVIP},
+		{"cloudConnection.apiKeys.baremetal.ingressVIP", key.IngressVIP},
+	} {
+		if vip.value == "" {
+			errs.add("%s is required", vip.field)
+		} else if net.ParseIP(vip.value) == nil {
+			errs.add("%s %q is not an IP address", vip.field, vip.value)
+		}
+	}
+	if key.APIVIP != "" && key.APIVIP == key.IngressVIP {
+		errs.add("cloudConnection.apiKeys.baremetal.apiVIP and ingressVIP must be different")
+	}
+	if len(key.Hosts) == 0 {
+		errs.add("cloudConnection.apiKeys.baremetal.hosts: at least one host is required")
+	}
+	for i, host := range key.Hosts {
+		field := fmt.Sprintf("cloudConnection.apiKeys.baremetal.hosts[%d]", i)
+		errs.required(field+".name", host.Name)
+		errs.required(field+".role", host.Role)
+		errs.required(field+".bmc.address", host.Bmc.Address)
+		errs.required(field+".bmc.username", host.Bmc.Username)
+		errs.required(field+".bmc.password", host.Bmc.Password)
+		if host.BootMACAddress == "" {
+			errs.add("%s.bootMACAddress is required", field)
+		} else if _, err := net.ParseMAC(host.BootMACAddress); err != nil {
+			errs.add("%s.bootMACAddress %q is not a MAC address", field, host.BootMACAddress)
+		}
+	}
+}
+
+func validateVSphere(errs *validationErrors, key VSphereAPIKey) {
+	errs.required("cloudConnection.apiKeys.vsphere.username", key.Username)
+	errs.required("cloudConnection.apiKeys.vsphere.password", key.Password)
+	errs.required("cloudConnection.apiKeys.vsphere.vCenter", key.VCenter)
+	errs.required("cloudConnection.apiKeys.vsphere.caCertificate", key.CACertificate)
+	if key.CACertificate != "" && !strings.Contains(key.CACertificate, "BEGIN CERTIFICATE-----") {
+		errs.add("cloudConnection.apiKeys.vsphere.caCertificate must be PEM encoded certificates")
+	}
+	errs.required("cloudConnection.apiKeys.vsphere.datacenter", key.Datacenter)
+	errs.required("cloudConnection.apiKeys.vsphere.defaultDatastore", key.DefaultDatastore)
+	errs.required("cloudConnection.apiKeys.vsphere.cluster", key.Cluster)
+	errs.required("cloudConnection.apiKeys.vsphere.network", key.Network)
+	errs.required("cloudConnection.apiKeys.vsphere.baseDnsDomain", key.BaseDNSDomain)
+	for _, vip := range []struct{ field, value string }{
+		{"cloudConnection.apiKeys.vsphere.apiVIP", key.APIVIP},
+		{"cloudConnection.apiKeys.vsphere.ingressVIP", key.IngressVIP},
+	} {
+		if vip.value == "" {
+			errs.add("%s is required", vip.field)
+		} else if net.ParseIP(vip.value) == nil {
+			errs.add("%s %q is not an IP address", vip.field, vip.value)
+		}
+	}
+	if key.APIVIP != "" && key.APIVIP == key.IngressVIP {
+		errs.add("cloudConnection.apiKeys.vsphere.apiVIP and ingressVIP must be different")
+	}
+	if key.MachineCIDR == "" {
+		errs.add("cloudConnection.apiKeys.vsphere.machineCIDR is required")
+	} else if _, cidr, err := net.ParseCIDR(key.MachineCIDR); err != nil {
+		errs.add("cloudConnection.apiKeys.vsphere.machineCIDR %q is not a CIDR", key.MachineCIDR)
+	} else {
+		for _, vip := range []string{key.APIVIP, key.IngressVIP} {
+			if ip := net.ParseIP(vip); ip != nil && !cidr.Contains(ip) {
+				errs.add("cloudConnection.apiKeys.vsphere VIP %s is not in the machineCIDR %s", vip, key.MachineCIDR)
+			}
+		}
+	}
+}
+
+func validateOpenStack(errs *validationErrors, key OpenStackAPIKey) {
+	errs.required("cloudConnection.apiKeys.openstack.cloudsYaml", key.CloudsYAML)
+	errs.required("cloudConnection.apiKeys.openstack.cloud", key.Cloud)
+	if key.CloudsYAML != "" && key.Cloud != "" {
+		clouds := struct {
+			Clouds map[string]interface{} `json:"clouds"`
+		}{}
+		if err := yaml.Unmarshal([]byte(key.CloudsYAML), &clouds); err != nil {
+			errs.add("cloudConnection.apiKeys.openstack.cloudsYaml is not a clouds.yaml: %v", err)
+		} else if _, ok := clouds.Clouds[key.Cloud]; !ok {
+			errs.add("cloudConnection.apiKeys.openstack.cloud %q is not in the cloudsYaml", key.Cloud)
+		}
+	}
+	errs.required("cloudConnection.apiKeys.openstack.externalNetwork", key.ExternalNetwork)
+	errs.required("cloudConnection.apiKeys.openstack.computeFlavor", key.ComputeFlavor)
+	errs.required("cloudConnection.apiKeys.openstack.baseDnsDomain", key.BaseDNSDomain)
+	for _, ip := range []struct{ field, value string }{
+		{"cloudConnection.apiKeys.openstack.apiFloatingIP", key.APIFloatingIP},
+		{"cloudConnection.apiKeys.openstack.ingressFloatingIP", key.IngressFloatingIP},
+	} {
+		if ip.value == "" {
+			errs.add("%s is required", ip.field)
+		} else if net.ParseIP(ip.value) == nil {
+			errs.add("%s %q is not an IP address", ip.field, ip.value)
+		}
+	}
+}
+
+func validateIBMCloud(errs *validationErrors, key IBMCloudAPIKey) {
+	errs.required("cloudConnection.apiKeys.ibmcloud.apiKey", key.APIKey)
+	errs.required("cloudConnection.apiKeys.ibmcloud.accountID", key.AccountID)
+	errs.required("cloudConnection.apiKeys.ibmcloud.cisInstanceCRN", key.CISInstanceCRN)
+	errs.required("cloudConnection.apiKeys.ibmcloud.region", key.Region)
+	errs.required("cloudConnection.apiKeys.ibmcloud.baseDnsDomain", key.BaseDNSDomain)
+	if key.CredentialsManifestsDir == "" {
+		errs.add("cloudConnection.apiKeys.ibmcloud.credentialsManifestsDir is required, IBM Cloud is installed in the manual credentials mode")
+	} else if fi, err := os.Stat(key.CredentialsManifestsDir); err != nil || !fi.IsDir() {
+		errs.add("cloudConnection.apiKeys.ibmcloud.credentialsManifestsDir %s is not a directory", key.CredentialsManifestsDir)
+	}
+}
+
+func validateHostedCluster(errs *validationErrors, hc HostedCluster) {
+	errs.required("hostedCluster.releaseImage", hc.ReleaseImage)
+	errs.required("hostedCluster.baseDomain", hc.BaseDomain)
+	if hc.NodePoolReplicas < 0 {
+		errs.add("hostedCluster.nodePoolReplicas must be positive")
+	}
+	if hc.Cores < 0 {
+		errs.add("hostedCluster.cores must be positive")
+	}
+	if hc.Memory != "" {
+		if _, err := resource.ParseQuantity(hc.Memory); err != nil {
+			errs.add("hostedCluster.memory %s is not a quantity: %v", hc.Memory, err)
+		}
+	}
+}
+
+func validateScale(errs *validationErrors, sc Scale) {
+	for _, f := range []struct {
+		field string
+		value int
+	}{
+		{"scale.clusters", sc.Clusters},
+		{"scale.manifestWorks", sc.ManifestWorks},
+		{"scale.leaseDurationSeconds", sc.LeaseDurationSeconds},
+		{"scale.concurrency", sc.Concurrency},
+		{"scale.qps", sc.QPS},
+	} {
+		if f.value < 0 {
+			errs.add("%s must be positive", f.field)
+		}
+	}
+}
+
+func validateAddOns(errs *validationErrors, a AddOns) {
+	for i, o := range a.Overrides {
+		for _, f := range []struct {
+			field string
+			value string
+		}{
+			{fmt.Sprintf("addons.overrides[%d].minVersion", i), o.MinVersion},
+			{fmt.Sprintf("addons.overrides[%d].maxVersion", i), o.MaxVersion},
+		} {
+			if _, err := version.ParseGeneric(f.value); f.value != "" && err != nil {
+				errs.add("%s %q is not a version: %v", f.field, f.value, err)
+			}
+		}
+		if len(o.Expected) == 0 && len(o.Excluded) == 0 {
+			errs.add("addons.overrides[%d] must have expected or excluded add-ons", i)
+		}
+	}
+}
+
+func validateTiming(errs *validationErrors, t Timing) {
+	if _, ok := TimingProfiles[t.Profile]; t.Profile != "" && !ok {
+		errs.add("timing.profile %q is not supported, it must be one of %s", t.Profile, strings.Join(TimingProfileNames(), ","))
+	}
+	names := make([]string, 0, len(t.Timeouts))
+	for name := range t.Timeouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := TimingProfiles[TimingProfileCloud].Timeouts[name]; !ok {
+			errs.add("timing.timeouts.%s is not a known timeout", name)
+		} else if t.Timeouts[name] <= 0 {
+			errs.add("timing.timeouts.%s must be greater than 0", name)
+		}
+	}
+	if t.Interval < 0 {
+		errs.add("timing.interval must be positive")
+	}
+	if t.LongInterval < 0 {
+		errs.add("timing.longInterval must be positive")
+	}
+}
+
+func validateImport(errs *validationErrors, i int, mc ManagedClusterImport, cluster libgooptions.Cluster) {
+	switch mc.ImportMode {
+	case ImportModeManual, ImportModeAutoImportKubeconfig:
+	case ImportModeAutoImportToken:
+		errs.required(fmt.Sprintf("clusters[%d].token", i), mc.Token)
+		errs.required(fmt.Sprintf("clusters[%d].apiServerURL", i), cluster.ApiServerURL)
+	default:
+		errs.add("clusters[%d].importMode %q is not supported, it must be one of %s", i, mc.ImportMode, strings.Join(ImportModes, ","))
+	}
+	if mc.Hosted() {
+		if mc.ImportMode == ImportModeManual {
+			errs.add("clusters[%d].hostingCluster requires the %s or %s importMode", i, ImportModeAutoImportKubeconfig, ImportModeAutoImportToken)
+		}
+		errs.required(fmt.Sprintf("clusters[%d].hostingKubeconfig", i), mc.HostingKubeConfig)
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright (c) 2020 Red Hat, Inc.
+
+// Package resources embeds the templates of the resources created on the hub by the tests.
+package resources
+
+import "embed"
+
+// Hub contains the templates of each scenario in hub/<scenario>
+//
+//go:embed hub/*/*.yaml hub/*/*/*.yaml
+var Hub embed.FS
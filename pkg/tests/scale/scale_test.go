@@ -0,0 +1,12 @@
+// Copyright (c) 2020 Red Hat, Inc.
+
+package scale
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/utils"
+)
+
+var _ = Describe("Cluster-lifecycle: ", func() {
+	utils.ScaleRegistration()
+})
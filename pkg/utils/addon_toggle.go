@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/diagnostics"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
+	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+// the label set by the addon framework on the manifestWorks of an add-on
+const addOnNameLabel = "open-cluster-management.io/addon-name"
+
+// addOnManifest is a namespace or a deployment deployed on the managed cluster by the manifestWork of an add-on
+type addOnManifest struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+func (m addOnManifest) String() string {
+	if m.namespace == "" {
+		return fmt.Sprintf("%s %s", m.gvr.Resource, m.name)
+	}
+	return fmt.Sprintf("%s %s/%s", m.gvr.Resource, m.namespace, m.name)
+}
+
+// AddOnToggling disables each add-on enabled in the klusterletAddonConfig of the cluster and enables it back.
+// It checks the managedClusterAddOns and their manifestWorks are deleted and re-created on the hub,
+// and the namespaces and deployments of their manifestWorks deleted and re-created on the managed cluster.
+// The klusterletAddonConfig is restored at the end.
+func AddOnToggling(clusterName string) {
+	var hubClients *clients.HubClients
+
+	BeforeEach(func() {
+		hubClients = clients.GetHubClients()
+		reports.SetCluster(clusterName, "", "")
+		klog.V(1).Infof(`========================= Start Test add-on toggling on cluster %s ===============================`, clusterName)
+	})
+
+	It(fmt.Sprintf("[P2][Sev2][cluster-lifecycle] Disable and enable the add-ons of the cluster %s (cluster/g1/addon-toggle)", clusterName), func() {
+		By("Checking the minimal requirements", func() {
+			Eventually(func() bool {
+				klog.V(2).Infof("Cluster %s: Check CRDs", clusterName)
+				has, missing, _ := libgocrdv1.HasCRDs(hubClients.APIExtensionClient,
+					[]string{
+						"managedclusters.cluster.open-cluster-management.io",
+						"manifestworks.work.open-cluster-management.io",
+						"managedclusteraddons.addon.open-cluster-management.io",
+						"clustermanagementaddons.addon.open-cluster-management.io",
+						"klusterletaddonconfigs.agent.open-cluster-management.io",
+					})
+				if !has {
+					klog.Errorf("Cluster %s: Missing CRDs\n%#v", clusterName, missing)
+				}
+				return has
+			}).Should(BeTrue())
+		})
+
+		WaitClusterImported(hubClients.DynamicClient, clusterName)
+
+		kac, err := hubClients.DynamicClient.Resource(gvrKlusterletAddonConfig).Namespace(clusterName).Get(context.TODO(), clusterName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			Skip(fmt.Sprintf("Cluster %s has no klusterletAddonConfig", clusterName))
+		}
+		Expect(err).To(BeNil())
+		managed, err := managedClusterClients(hubClients, clusterName)
+		Expect(err).To(BeNil())
+		cmas, err := clusterManagementAddOns(hubClients.DynamicClient)
+		Expect(err).To(BeNil())
+
+		When("the cluster is imported, wait for its add-ons to be available", func() {
+			WaitClusterAdddonsAvailable(hubClients.DynamicClient, clusterName)
+		})
+
+		// the add-ons enabled by each field of the klusterletAddonConfig and having a clusterManagementAddOn
+		fields := map[string][]string{}
+		var enabledFields []string
+		for _, c := range klusterletAddonConfigAddOns {
+			if enabled, _, _ := unstructured.NestedBool(kac.Object, "spec", c.field, "enabled"); !enabled {
+				klog.V(1).Infof("Cluster %s: %s not enabled in the klusterletAddonConfig, not toggled", clusterName, c.field)
+				continue
+			}
+			for _, addOnName := range c.addOns {
+				if cmas[addOnName] {
+					fields[c.field] = append(fields[c.field], addOnName)
+				}
+			}
+			if len(fields[c.field]) == 0 {
+				klog.V(1).Infof("Cluster %s: %s has no clusterManagementAddOn, not toggled", clusterName, c.field)
+				continue
+			}
+			enabledFields = append(enabledFields, c.field)
+		}
+		defer func() {
+			for _, field := range enabledFields {
+				if err := setAddOnEnabled(hubClients.DynamicClient, clusterName, field, true); err != nil {
+					klog.Errorf("Cluster %s: %v", clusterName, err)
+				}
+			}
+		}()
+
+		// the manifests of every add-on, a manifest shared with another add-on is not deleted with the add-on
+		manifests := map[string][]addOnManifest{}
+		for _, field := range enabledFields {
+			for _, addOnName := range fields[field] {
+				manifests[addOnName], err = addOnManifests(hubClients.DynamicClient, clusterName, addOnName)
+				Expect(err).To(BeNil())
+				klog.V(2).Infof("Cluster %s: Add-On %s deploys %v", clusterName, addOnName, manifests[addOnName])
+			}
+		}
+
+		for _, field := range enabledFields {
+			addOnNames := fields[field]
+			By(fmt.Sprintf("Disabling %s in the klusterletAddonConfig", field), func() {
+				klog.V(1).Infof("Cluster %s: Disabling %s, Add-Ons %v", clusterName, field, addOnNames)
+				Expect(setAddOnEnabled(hubClients.DynamicClient, clusterName, field, false)).To(BeNil())
+			})
+
+			When(fmt.Sprintf("%s is disabled, wait for the Add-Ons %v to be removed", field, addOnNames), func() {
+				for _, addOnName := range addOnNames {
+					addOnName := addOnName
+					reports.TimeStep(reports.StepAddOnDisabledPrefix+addOnName, func() {
+						waitAddOnRemoved(hubClients, managed, clusterName, addOnName, exclusiveManifests(manifests, addOnName, addOnNames))
+					})
+				}
+			})
+
+			By(fmt.Sprintf("Enabling %s in the klusterletAddonConfig", field), func() {
+				klog.V(1).Infof("Cluster %s: Enabling %s, Add-Ons %v", clusterName, field, addOnNames)
+				Expect(setAddOnEnabled(hubClients.DynamicClient, clusterName, field, true)).To(BeNil())
+			})
+
+			When(fmt.Sprintf("%s is enabled, wait for the Add-Ons %v to be available", field, addOnNames), func() {
+				for _, addOnName := range addOnNames {
+					addOnName := addOnName
+					reports.TimeStep(reports.StepAddOnPrefix+addOnName, func() {
+						waitAddOnRestored(hubClients, managed, clusterName, addOnName, manifests[addOnName])
+					})
+				}
+			})
+		}
+	})
+}
+
+// setAddOnEnabled enables or disables the field of the klusterletAddonConfig of the cluster.
+func setAddOnEnabled(hubClientDynamic dynamic.Interface, clusterName, field string, enabled bool) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			field: map[string]interface{}{
+				"enabled": enabled,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = hubClientDynamic.Resource(gvrKlusterletAddonConfig).Namespace(clusterName).Patch(context.TODO(), clusterName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("klusterletAddonConfig %s: %s.enabled can not be set to %t: %v", clusterName, field, enabled, err)
+	}
+	return nil
+}
+
+// addOnManifestWorks returns the manifestWorks of the add-on in the cluster namespace,
+// labeled by the addon framework or named addon-<addOnName>-deploy by its former releases.
+func addOnManifestWorks(hubClientDynamic dynamic.Interface, clusterName, addOnName string) ([]unstructured.Unstructured, error) {
+	mws, err := hubClientDynamic.Resource(gvrManifestWork).Namespace(clusterName).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var l []unstructured.Unstructured
+	for _, mw := range mws.Items {
+		if mw.GetLabels()[addOnNameLabel] == addOnName || strings.HasPrefix(mw.GetName(), "addon-"+addOnName+"-deploy") {
+			l = append(l, mw)
+		}
+	}
+	return l, nil
+}
+
+// addOnManifests returns the namespaces and the deployments applied by the manifestWorks of the add-on.
+func addOnManifests(hubClientDynamic dynamic.Interface, clusterName, addOnName string) ([]addOnManifest, error) {
+	mws, err := addOnManifestWorks(hubClientDynamic, clusterName, addOnName)
+	if err != nil {
+		return nil, err
+	}
+	var l []addOnManifest
+	for _, mw := range mws {
+		statuses, _, _ := unstructured.NestedSlice(mw.Object, "status", "resourceStatus", "manifests")
+		for _, s := range statuses {
+			status, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			meta, _, _ := unstructured.NestedStringMap(status, "resourceMeta")
+			if !(meta["group"] == "" && meta["kind"] == "Namespace") && !(meta["group"] == "apps" && meta["kind"] == "Deployment") {
+				continue
+			}
+			l = append(l, addOnManifest{
+				gvr:       schema.GroupVersionResource{Group: meta["group"], Version: meta["version"], Resource: meta["resource"]},
+				namespace: meta["namespace"],
+				name:      meta["name"],
+			})
+		}
+	}
+	return l, nil
+}
+
+// exclusiveManifests returns the manifests of the add-on which are not deployed by an add-on not toggled with it.
+func exclusiveManifests(manifests map[string][]addOnManifest, addOnName string, toggled []string) []addOnManifest {
+	shared := map[addOnManifest]bool{}
+	for name, l := range manifests {
+		isToggled := false
+		for _, t := range toggled {
+			isToggled = isToggled || t == name
+		}
+		if isToggled {
+			continue
+		}
+		for _, m := range l {
+			shared[m] = true
+		}
+	}
+	var l []addOnManifest
+	for _, m := range manifests[addOnName] {
+		if !shared[m] {
+			l = append(l, m)
+		}
+	}
+	return l
+}
+
+// waitAddOnRemoved waits for the managedClusterAddOn and the manifestWorks of the add-on to be deleted
+// and for its manifests to be deleted from the managed cluster.
+func waitAddOnRemoved(hubClients *clients.HubClients, managed *diagnostics.Clients, clusterName, addOnName string, manifests []addOnManifest) {
+	klog.V(1).Infof("Cluster %s: Wait Add-On %s to be removed...", clusterName, addOnName)
+	Expect(newWaiter(hubClients.DynamicClient, eventuallyInterval()).WaitFor(gvrManagedClusterAddOn, clusterName, addOnName,
+		eventuallyTimeout(),
+		func(addOn *unstructured.Unstructured) error {
+			if addOn == nil {
+				return nil
+			}
+			return failures.Classify(failures.ScopeAddon, "AddOnNotRemoved",
+				fmt.Sprintf("cluster %s - Add-On %s: managedClusterAddOn not deleted", clusterName, addOnName))
+		})).To(BeNil())
+	Eventually(func() error {
+		mws, err := addOnManifestWorks(hubClients.DynamicClient, clusterName, addOnName)
+		if err != nil {
+			return err
+		}
+		if len(mws) != 0 {
+			return failures.Classify(failures.ScopeAddon, "AddOnNotRemoved",
+				fmt.Sprintf("cluster %s - Add-On %s: manifestWork %s not deleted", clusterName, addOnName, mws[0].GetName()))
+		}
+		for _, m := range manifests {
+			_, err := managed.DynamicClient.Resource(m.gvr).Namespace(m.namespace).Get(context.TODO(), m.name, metav1.GetOptions{})
+			if !errors.IsNotFound(err) {
+				return failures.Classify(failures.ScopeAddon, "AddOnManifestsNotDeleted",
+					fmt.Sprintf("cluster %s - Add-On %s: %s not deleted from the managed cluster: %v", clusterName, addOnName, m, err))
+			}
+		}
+		return nil
+	}, eventuallyTimeout(), eventuallyInterval()).Should(BeNil())
+	klog.V(1).Infof("Cluster %s: Add-On %s removed", clusterName, addOnName)
+}
+
+// waitAddOnRestored waits for the add-on to be available again, its manifestWorks applied
+// and its manifests re-created on the managed cluster.
+func waitAddOnRestored(hubClients *clients.HubClients, managed *diagnostics.Clients, clusterName, addOnName string, manifests []addOnManifest) {
+	klog.V(1).Infof("Cluster %s: Wait Add-On %s to be restored...", clusterName, addOnName)
+	Expect(newWaiter(hubClients.DynamicClient, eventuallyInterval()).WaitFor(gvrManagedClusterAddOn, clusterName, addOnName,
+		eventuallyTimeout(),
+		func(addOn *unstructured.Unstructured) error {
+			return validateClusterAddOnAvailable(addOn, clusterName, addOnName)
+		})).To(BeNil())
+	Eventually(func() error {
+		mws, err := addOnManifestWorks(hubClients.DynamicClient, clusterName, addOnName)
+		if err != nil {
+			return err
+		}
+		if len(mws) == 0 {
+			return failures.Classify(failures.ScopeAddon, "AddOnNotRestored",
+				fmt.Sprintf("cluster %s - Add-On %s: no manifestWork re-created", clusterName, addOnName))
+		}
+		for i := range mws {
+			if err := waiters.ConditionTrue("Applied")(&mws[i]); err != nil {
+				return failures.Classify(failures.ScopeAddon, "AddOnNotRestored",
+					fmt.Sprintf("cluster %s - Add-On %s: %v", clusterName, addOnName, err))
+			}
+		}
+		for _, m := range manifests {
+			_, err := managed.DynamicClient.Resource(m.gvr).Namespace(m.namespace).Get(context.TODO(), m.name, metav1.GetOptions{})
+			if err != nil {
+				return failures.Classify(failures.ScopeAddon, "AddOnNotRestored",
+					fmt.Sprintf("cluster %s - Add-On %s: %s not re-created on the managed cluster: %v", clusterName, addOnName, m, err))
+			}
+		}
+		return nil
+	}, eventuallyTimeout(), eventuallyInterval()).Should(BeNil())
+	klog.V(1).Infof("Cluster %s: Add-On %s restored", clusterName, addOnName)
+}
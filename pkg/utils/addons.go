@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+var (
+	gvrManagedClusterAddOn    = schema.GroupVersionResource{Group: "addon.open-cluster-management.io", Version: "v1alpha1", Resource: "managedclusteraddons"}
+	gvrClusterManagementAddOn = schema.GroupVersionResource{Group: "addon.open-cluster-management.io", Version: "v1alpha1", Resource: "clustermanagementaddons"}
+	gvrKlusterletAddonConfig  = schema.GroupVersionResource{Group: "agent.open-cluster-management.io", Version: "v1", Resource: "klusterletaddonconfigs"}
+)
+
+// the add-ons deployed by the klusterletAddonConfig when the spec field is enabled
+var klusterletAddonConfigAddOns = []struct {
+	field  string
+	addOns []string
+}{
+	{field: "applicationManager", addOns: []string{"application-manager"}},
+	{field: "certPolicyController", addOns: []string{"cert-policy-controller"}},
+	{field: "iamPolicyController", addOns: []string{"iam-policy-controller"}},
+	{field: "policyController", addOns: []string{"config-policy-controller", "governance-policy-framework"}},
+	{field: "searchCollector", addOns: []string{"search-collector"}},
+}
+
+// the add-ons installed on every managed cluster
+var defaultAddOns = []string{"work-manager"}
+
+// the label of the OCP version of the managedClusters
+const openshiftVersionLabel = "openshiftVersion"
+
+// expectedAddOns returns the sorted add-ons expected on the cluster: the add-ons enabled by its klusterletAddonConfig
+// and the default add-ons which have a clusterManagementAddOn on the hub, with the overrides of the options
+// matching the cloud and the OCP version of the cluster.
+func expectedAddOns(hubClientDynamic dynamic.Interface, clusterName string) ([]string, error) {
+	managed, err := clusterManagementAddOns(hubClientDynamic)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := append([]string{}, defaultAddOns...)
+	kac, err := hubClientDynamic.Resource(gvrKlusterletAddonConfig).Namespace(clusterName).Get(context.TODO(), clusterName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		klog.V(1).Infof("Cluster %s: no klusterletAddonConfig, only the default add-ons are expected", clusterName)
+	case err != nil:
+		return nil, err
+	default:
+		for _, c := range klusterletAddonConfigAddOns {
+			if enabled, _, _ := unstructured.NestedBool(kac.Object, "spec", c.field, "enabled"); enabled {
+				candidates = append(candidates, c.addOns...)
+			}
+		}
+	}
+	expected := map[string]bool{}
+	for _, addOnName := range candidates {
+		if !managed[addOnName] {
+			klog.V(2).Infof("Cluster %s: Add-On %s has no clusterManagementAddOn, not expected", clusterName, addOnName)
+			continue
+		}
+		expected[addOnName] = true
+	}
+
+	mc, err := hubClientDynamic.Resource(gvrManagedCluster).Get(context.TODO(), clusterName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cloud := mc.GetLabels()["cloud"]
+	ocpVersion := mc.GetLabels()[openshiftVersionLabel]
+	for i, o := range options.TestOptions.Options.AddOns.Overrides {
+		if !addOnOverrideMatches(o, cloud, ocpVersion) {
+			continue
+		}
+		klog.V(2).Infof("Cluster %s: Add-Ons override %d applied, expected %v, excluded %v", clusterName, i, o.Expected, o.Excluded)
+		for _, addOnName := range o.Expected {
+			expected[addOnName] = true
+		}
+		for _, addOnName := range o.Excluded {
+			delete(expected, addOnName)
+		}
+	}
+
+	l := make([]string, 0, len(expected))
+	for addOnName := range expected {
+		l = append(l, addOnName)
+	}
+	sort.Strings(l)
+	return l, nil
+}
+
+// clusterManagementAddOns returns the names of the clusterManagementAddOns of the hub.
+func clusterManagementAddOns(hubClientDynamic dynamic.Interface) (map[string]bool, error) {
+	cmas, err := hubClientDynamic.Resource(gvrClusterManagementAddOn).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	managed := map[string]bool{}
+	for _, cma := range cmas.Items {
+		managed[cma.GetName()] = true
+	}
+	return managed, nil
+}
+
+// addOnOverrideMatches returns true if the override applies to a cluster of the cloud and of the OCP version,
+// a cluster without OCP version doesn't match an override with a version range.
+func addOnOverrideMatches(o options.AddOnOverride, cloud, ocpVersion string) bool {
+	if o.Cloud != "" && !strings.EqualFold(o.Cloud, cloud) {
+		return false
+	}
+	if o.MinVersion == "" && o.MaxVersion == "" {
+		return true
+	}
+	v, err := version.ParseGeneric(ocpVersion)
+	if err != nil {
+		return false
+	}
+	// the versions are validated with the options
+	if o.MinVersion != "" && v.LessThan(version.MustParseGeneric(o.MinVersion)) {
+		return false
+	}
+	if o.MaxVersion != "" && !v.LessThan(version.MustParseGeneric(o.MaxVersion)) {
+		return false
+	}
+	return true
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -14,6 +13,12 @@ import (
 	"github.com/stolostron/applier/pkg/templateprocessor"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/appliers"
 	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/manifest"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
 	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
 	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
 	libgodeploymentv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/deployment"
@@ -21,64 +26,47 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
 )
 
-// list of manifestwork name for addon crs
-var managedClusteraddOns = []string{
-	"application-manager",
-	"cert-policy-controller",
-	"cert-policy-controller",
-	"governance-policy-framework",
-	"search-collector",
-	"work-manager",
-}
-
-var (
-	eventuallyTimeout  = 600
-	eventuallyInterval = 10
-)
+var gvrManagedCluster = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
 
-const (
-	NeedInvestigate              = "[need investigate]"
-	KnownIssueTag                = "[known issue]"
-	DetachKnownIssueLink         = "https://github.com/stolostron/cluster-lifecycle-e2e/blob/main/doc/e2eFailedAnalysis.md#klusterlet-crd-can-not-be-deleted"
-	QuotaLimitTag                = "[quota limit]"
-	ProvisionQuotaLimitErrorLink = "https://github.com/stolostron/cluster-lifecycle-e2e/blob/main/doc/e2eFailedAnalysis.md#quota-limit-in-awsazuregcp"
-	UnknownError                 = "[unknown error]"
-	ProvisionUnknownErrorLink    = "https://github.com/stolostron/cluster-lifecycle-e2e/blob/main/doc/e2eFailedAnalysis.md#cloud-providerawsgcpazure-bug-or-ocp-installer-bug"
+// the timeout and the interval of the waits, read from the timing profile of the options
+func eventuallyTimeout() time.Duration  { return options.Timeout(options.TimeoutDefault) }
+func eventuallyInterval() time.Duration { return options.Interval() }
 
-	UnknownErrorLink = "https://github.com/stolostron/cluster-lifecycle-e2e/blob/main/doc/e2eFailedAnalysis.md#unknown-error"
-	// List key word about quota limit which can not be identified in clusterdeployment.
-
-	// failed to fetch dependency of \"Cluster\": failed to generate asset \"Platform Quota Check\": error(MissingQuota): compute.googleapis.com/firewalls is not available in global because the required number of resources (6) is more than remaining quota of 0\n,",
-	gcpQuotaLimitMsg = "more than remaining quota"
-)
+// interval used to re-check long running operations such as install or deletion
+func longInterval() time.Duration { return options.LongInterval() }
 
 func WaitClusterImported(hubClientDynamic dynamic.Interface, clusterName string) {
-	Eventually(func() error {
-		klog.V(1).Infof("Cluster %s: Wait %s to be imported...", clusterName, clusterName)
-		return checkClusterImported(hubClientDynamic, clusterName)
-	}, eventuallyTimeout, eventuallyInterval).Should(BeNil())
+	klog.V(1).Infof("Cluster %s: Wait %s to be imported...", clusterName, clusterName)
+	reports.TimeStep(reports.StepImport, func() {
+		Expect(newWaiter(hubClientDynamic, eventuallyInterval()).WaitFor(gvrManagedCluster, "", clusterName, eventuallyTimeout(),
+			func(managedCluster *unstructured.Unstructured) error {
+				return checkClusterImported(managedCluster, clusterName)
+			})).To(BeNil())
+	})
 	klog.V(1).Infof("Cluster %s: imported", clusterName)
 }
 
-func checkClusterImported(hubClientDynamic dynamic.Interface, clusterName string) error {
+func newWaiter(client dynamic.Interface, interval time.Duration) *waiters.Waiter {
+	return waiters.NewWaiter(client, interval)
+}
+
+func checkClusterImported(managedCluster *unstructured.Unstructured, clusterName string) error {
 	klog.V(1).Infof("Cluster %s: Check %s is imported...", clusterName, clusterName)
-	gvr := schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
-	managedCluster, err := hubClientDynamic.Resource(gvr).Get(context.TODO(), clusterName, metav1.GetOptions{})
-	if err != nil {
-		klog.V(4).Info(err)
-		return err
+	if managedCluster == nil {
+		return fmt.Errorf("Cluster %s: managedCluster not found", clusterName)
 	}
-	var condition map[string]interface{}
-	condition, err = libgounstructuredv1.GetConditionByType(managedCluster, "ManagedClusterConditionAvailable")
+	condition, err := libgounstructuredv1.GetConditionByType(managedCluster, "ManagedClusterConditionAvailable")
 	if err != nil {
 		return err
 	}
@@ -87,11 +75,12 @@ func checkClusterImported(hubClientDynamic dynamic.Interface, clusterName string
 		return nil
 	} else {
 		klog.V(4).Infof("Cluster %s: Current is not equal to \"%s\" but \"%v\"", clusterName, metav1.ConditionTrue, v)
-		return GenerateErrorMsg(UnknownError, UnknownErrorLink, "Import cluster fail, Cluster status in unknown", "Import cluster fail, Cluster status in unknown")
+		return failures.Classify(failures.ScopeImport, "Import cluster fail, Cluster status in unknown", "Import cluster fail, Cluster status in unknown")
 	}
 }
 
 func CreateCluster(cloud, vendor, cloudProviders string) {
+	var provider *Provider
 	var clusterNameObj *libgooptions.ClusterName
 	var clusterName string
 	var err error
@@ -105,7 +94,9 @@ func CreateCluster(cloud, vendor, cloudProviders string) {
 		if cloudProviders != "" && !isRequestedCloudProvider(cloud, cloudProviders) {
 			Skip(fmt.Sprintf("Cloud provider %s skipped", cloud))
 		}
-		clusterNameObj, err = libgooptions.NewClusterName(cloud)
+		provider, err = GetProvider(cloud)
+		Expect(err).To(BeNil())
+		clusterNameObj, err = newClusterName(cloud)
 		Expect(err).To(BeNil())
 		clusterName = clusterNameObj.String()
 		if cloud == "baremetal" {
@@ -113,8 +104,8 @@ func CreateCluster(cloud, vendor, cloudProviders string) {
 		}
 		klog.V(1).Infof(`========================= Start Test create cluster %s
 with image %s ===============================`, clusterName, imageRefName)
-		SetDefaultEventuallyTimeout(10 * time.Minute)
-		SetDefaultEventuallyPollingInterval(10 * time.Second)
+		SetDefaultEventuallyTimeout(eventuallyTimeout())
+		SetDefaultEventuallyPollingInterval(eventuallyInterval())
 	})
 
 	AfterEach(func() {
@@ -183,22 +174,27 @@ with image %s ===============================`, clusterName, imageRefName)
 		})
 
 		By("creating the namespace in which the cluster will be imported", func() {
-			// Create the cluster NS on master
-			klog.V(1).Infof("Cluster %s: Creating the namespace in which the cluster will be imported", clusterName)
-			namespaces := hubClients.KubeClient.CoreV1().Namespaces()
-			_, err := namespaces.Get(context.TODO(), clusterName, metav1.GetOptions{})
-			if err != nil {
-				if errors.IsNotFound(err) {
-					Expect(namespaces.Create(context.TODO(), &corev1.Namespace{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: clusterName,
-						},
-					}, metav1.CreateOptions{})).NotTo(BeNil())
-					Expect(namespaces.Get(context.TODO(), clusterName, metav1.GetOptions{})).NotTo(BeNil())
-				} else {
-					Fail(err.Error())
+			reports.TimeStep(reports.StepNamespace, func() {
+				// Create the cluster NS on master
+				klog.V(1).Infof("Cluster %s: Creating the namespace in which the cluster will be imported", clusterName)
+				namespaces := hubClients.KubeClient.CoreV1().Namespaces()
+				_, err := namespaces.Get(context.TODO(), clusterName, metav1.GetOptions{})
+				if err != nil {
+					if errors.IsNotFound(err) {
+						namespace := &corev1.Namespace{
+							ObjectMeta: metav1.ObjectMeta{
+								Name: clusterName,
+							},
+						}
+						ownership.Own(namespace)
+						Expect(namespaces.Create(context.TODO(), namespace, metav1.CreateOptions{})).NotTo(BeNil())
+						Expect(namespaces.Get(context.TODO(), clusterName, metav1.GetOptions{})).NotTo(BeNil())
+						ownership.Record(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, "Namespace", "", clusterName)
+					} else {
+						Fail(err.Error())
+					}
 				}
-			}
+			})
 		})
 
 		By("Creating the needed resources", func() {
@@ -234,68 +230,38 @@ with image %s ===============================`, clusterName, imageRefName)
 				false,
 				values)).To(BeNil())
 
-			if cloud != "baremetal" {
-				klog.V(1).Infof("Cluster %s: Creating the %s cred secret", clusterName, cloud)
-				Expect(createCredentialsSecret(hubAppliers.CreateApplier, clusterName, cloud)).To(BeNil())
-			}
+			klog.V(1).Infof("Cluster %s: Creating the %s cred secret", clusterName, cloud)
+			Expect(createCredentialsSecret(hubAppliers.CreateApplier, clusterName, cloud)).To(BeNil())
 
 			klog.V(1).Infof("Cluster %s: Creating install config secret", clusterName)
 			Expect(createInstallConfig(hubAppliers.CreateApplier, hubAppliers.CreateTemplateProcessor, clusterName, cloud)).To(BeNil())
 
-			// imageRefName = libgooptions.TestOptions.ManagedClusters.ImageSetRefName
-
-			if libgooptions.TestOptions.Options.OCPReleaseVersion != "" && cloud != "baremetal" {
-				imageRefName, err = createClusterImageSet(hubAppliers.CreateApplier, clusterNameObj, libgooptions.TestOptions.Options.OCPReleaseVersion)
-				Expect(err).To(BeNil())
-				// imageRefName = libgooptions.TestOptions.Options.OCPReleaseVersion
-			} else {
-				gvr := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterimagesets"}
-				imagesetsList, err := hubClients.DynamicClient.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
-				Expect(err).To(BeNil())
-
-				for _, imageset := range imagesetsList.Items {
-					if metadata, ok := imageset.Object["metadata"]; ok {
-						if m, ok := metadata.(map[string]interface{}); ok {
-							if name, ok := m["name"]; ok {
-								strName := fmt.Sprintf("%v", name)
-								klog.V(1).Infof("Cluster %s: Add imageset: %s", clusterName, strName)
-								if len(imageRefName) == 0 {
-									imageRefName = strName
-									continue
-								}
-								// get the max version to deploy
-								if compareImageVersion(imageRefName, strName) < 0 {
-									imageRefName = strName
-								}
-							}
-						}
-					}
-				}
-			}
-			if libgooptions.TestOptions.Options.OCPReleaseVersion != "" && cloud == "baremetal" {
-				imageRefName = libgooptions.TestOptions.Options.OCPReleaseVersion
-			}
+			imageRefName, err = getClusterImageSetName(hubClients.DynamicClient, hubAppliers.CreateApplier, clusterNameObj, cloud)
+			Expect(err).To(BeNil())
 			Expect(imageRefName).NotTo(Equal(""))
+			reports.SetImageSet(imageRefName)
 		})
 
 		By("creating the clusterDeployment", func() {
-			var region string
-			if cloud != "baremetal" {
-				region, err = libgooptions.GetRegion(cloud)
-				Expect(err).To(BeNil())
-			}
-			baseDomain, err := libgooptions.GetBaseDomain(cloud)
+			region, err := provider.region()
+			Expect(err).To(BeNil())
+			reports.SetCluster(clusterName, cloud, region)
+			baseDomain, err := provider.BaseDomain()
+			Expect(err).To(BeNil())
+			platform, err := renderPlatform(hubAppliers.CreateTemplateProcessor, clusterName, cloud, region)
+			Expect(err).To(BeNil())
+			manifestsConfigMap, err := createCredentialsManifests(hubClients.KubeClient, clusterName, cloud)
 			Expect(err).To(BeNil())
 			values := struct {
-				ManagedClusterName          string
-				ManagedClusterCloud         string
-				ManagedClusterRegion        string
-				ManagedClusterVendor        string
-				ManagedClusterBaseDomain    string
-				ManagedClusterImageRefName  string
-				ManagedClusterBaseDomainRGN string
-				SSHKnownHosts               []string
-				Hosts                       []libgooptions.Hosts
+				ManagedClusterName               string
+				ManagedClusterCloud              string
+				ManagedClusterRegion             string
+				ManagedClusterVendor             string
+				ManagedClusterBaseDomain         string
+				ManagedClusterImageRefName       string
+				ManagedClusterPlatform           string
+				ManagedClusterManifestsConfigMap string
+				SSHKnownHosts                    []string
 			}{
 				ManagedClusterName:       clusterName,
 				ManagedClusterCloud:      cloud,
@@ -303,10 +269,10 @@ with image %s ===============================`, clusterName, imageRefName)
 				ManagedClusterVendor:     vendor,
 				ManagedClusterBaseDomain: baseDomain,
 				// TODO: parametrize the image
-				ManagedClusterImageRefName:  imageRefName,
-				ManagedClusterBaseDomainRGN: libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.BaseDomainRGN,
-				SSHKnownHosts:               libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.SSHKnownHostsList,
-				Hosts:                       libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.Hosts,
+				ManagedClusterImageRefName:       imageRefName,
+				ManagedClusterPlatform:           platform,
+				ManagedClusterManifestsConfigMap: manifestsConfigMap,
+				SSHKnownHosts:                    libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.SSHKnownHostsList,
 			}
 			klog.V(1).Infof("Cluster %s: Creating the clusterDeployment", clusterName)
 			Expect(hubAppliers.CreateApplier.CreateOrUpdateResource("cluster_deployment_cr.yaml", values)).To(BeNil())
@@ -318,46 +284,32 @@ with image %s ===============================`, clusterName, imageRefName)
 		})
 
 		When("Import launched, wait for cluster to be installed", func() {
+			klog.V(1).Infof("Cluster %s: Wait %s to be installed...", clusterName, clusterName)
 			gvr := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
-			Eventually(func() error {
-				klog.V(1).Infof("Cluster %s: Wait %s to be installed...", clusterName, clusterName)
-				clusterDeployment, err := hubClients.DynamicClient.Resource(gvr).Namespace(clusterName).Get(context.TODO(), clusterName, metav1.GetOptions{})
-				if err == nil {
-					if si, ok := clusterDeployment.Object["status"]; ok {
-						s := si.(map[string]interface{})
-						if ti, ok := s["installedTimestamp"]; ok && ti != nil {
-							return nil
-						}
-					}
-					var condition map[string]interface{}
-					condition, err = libgounstructuredv1.GetConditionByType(clusterDeployment, "ProvisionFailed")
-					if err == nil {
-						if v, ok := condition["status"]; ok && v == string(metav1.ConditionTrue) {
-							if strings.HasSuffix(condition["reason"].(string), "LimitExceeded") {
-								return GenerateErrorMsg(QuotaLimitTag, ProvisionQuotaLimitErrorLink, condition["reason"].(string), condition["message"].(string))
-							}
-
-							if strings.Contains(condition["message"].(string), gcpQuotaLimitMsg) {
-								return GenerateErrorMsg(QuotaLimitTag, ProvisionQuotaLimitErrorLink, condition["reason"].(string), condition["message"].(string))
+			reports.TimeStep(reports.StepInstall, func() {
+				Expect(newWaiter(hubClients.DynamicClient, longInterval()).WaitFor(gvr, clusterName, clusterName, options.Timeout(options.TimeoutInstall), func(clusterDeployment *unstructured.Unstructured) error {
+					if clusterDeployment != nil {
+						if si, ok := clusterDeployment.Object["status"]; ok {
+							s := si.(map[string]interface{})
+							if ti, ok := s["installedTimestamp"]; ok && ti != nil {
+								return nil
 							}
-
-							if condition["reason"].(string) == "UnknownError" {
-								return GenerateErrorMsg(UnknownError, ProvisionUnknownErrorLink, condition["reason"].(string), condition["message"].(string))
+						}
+						condition, err := libgounstructuredv1.GetConditionByType(clusterDeployment, "ProvisionFailed")
+						if err == nil {
+							if v, ok := condition["status"]; ok && v == string(metav1.ConditionTrue) {
+								return failures.Classify(failures.ScopeProvision, condition["reason"].(string), condition["message"].(string))
 							}
-							return GenerateErrorMsg("", "", condition["reason"].(string), condition["message"].(string))
 						}
+						return fmt.Errorf("Failed to get provision result.")
 					}
-					return fmt.Errorf("Failed to get provision result.")
-				} else {
-					klog.V(4).Info(err)
-				}
-
-				return err
-			}, 5400, 60).Should(BeNil())
+					return fmt.Errorf("Cluster %s: clusterDeployment not found", clusterName)
+				})).To(BeNil())
+			})
 		})
 
 		When(fmt.Sprintf("Import launched, wait for cluster %s to be ready", clusterName), func() {
-			waitClusterImported(hubClients.DynamicClient, clusterName)
+			WaitClusterImported(hubClients.DynamicClient, clusterName)
 		})
 
 		if cloud != "baremetal" {
@@ -366,27 +318,69 @@ with image %s ===============================`, clusterName, imageRefName)
 			})
 		}
 
-		klog.V(1).Infof("Cluster %s: Wait 3 min to settle", clusterName)
-		time.Sleep(3 * time.Minute)
-
 		if cloud != "baremetal" {
 			When(fmt.Sprintf("Import launched, wait for Add-Ons %s to be available", clusterName), func() {
 				WaitClusterAdddonsAvailable(hubClients.DynamicClient, clusterName)
 			})
 		}
 
+		By(fmt.Sprintf("Recording the cluster %s in the run manifest", clusterName), func() {
+			Expect(manifest.Record(hubClients.KubeClient, manifest.Entry{
+				Cluster:   clusterName,
+				Cloud:     cloud,
+				ImageSet:  imageRefName,
+				Namespace: clusterName,
+				RunID:     ownership.RunID(),
+			})).To(BeNil())
+		})
+
 		klog.V(1).Infof("========================= End Test create cluster %s ===============================", clusterName)
 
 	})
 
 }
 
+// GenerateErrorMsg returns a failure which is not classified by the rules.
 func GenerateErrorMsg(tag, solution, reason, errmsg string) error {
-	return fmt.Errorf("Tag: %v, "+
-		"Possible Solution: %v, "+
-		"Reason: %v, "+
-		"Error message: %v,",
-		tag, solution, reason, errmsg)
+	return &failures.Failure{
+		Tag:     tag,
+		Link:    solution,
+		Reason:  reason,
+		Message: errmsg,
+	}
+}
+
+// getClusterImageSetName returns the clusterImageSet to use for the cluster,
+// the one created for the OCPReleaseVersion option if set, otherwise the most recent one on the hub.
+func getClusterImageSetName(hubClientDynamic dynamic.Interface,
+	hubCreateApplier *applier.Applier,
+	clusterNameObj *libgooptions.ClusterName,
+	cloud string) (string, error) {
+	if libgooptions.TestOptions.Options.OCPReleaseVersion != "" {
+		if cloud == "baremetal" {
+			return libgooptions.TestOptions.Options.OCPReleaseVersion, nil
+		}
+		return createClusterImageSet(hubCreateApplier, clusterNameObj, libgooptions.TestOptions.Options.OCPReleaseVersion)
+	}
+	var imageRefName string
+	gvr := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterimagesets"}
+	imagesetsList, err := hubClientDynamic.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, imageset := range imagesetsList.Items {
+		strName := imageset.GetName()
+		klog.V(1).Infof("Cluster %s: Add imageset: %s", clusterNameObj, strName)
+		if len(imageRefName) == 0 {
+			imageRefName = strName
+			continue
+		}
+		// get the max version to deploy
+		if compareImageVersion(imageRefName, strName) < 0 {
+			imageRefName = strName
+		}
+	}
+	return imageRefName, nil
 }
 
 // compareImageVersion returns an integer comparing two strings lexicographically.
@@ -450,151 +444,33 @@ func isRequestedCloudProvider(cloud, cloudProviders string) bool {
 }
 
 func createCredentialsSecret(hubCreateApplier *applier.Applier, clusterName, cloud string) error {
-	switch cloud {
-	case "aws":
-		cloudCredSecretValues := struct {
-			ManagedClusterName string
-			AWSAccessKeyID     string
-			AWSSecretAccessKey string
-		}{
-			ManagedClusterName: clusterName,
-			AWSAccessKeyID:     libgooptions.TestOptions.Options.CloudConnection.APIKeys.AWS.AWSAccessKeyID,
-			AWSSecretAccessKey: libgooptions.TestOptions.Options.CloudConnection.APIKeys.AWS.AWSAccessSecret,
-		}
-		return hubCreateApplier.CreateOrUpdateResource(filepath.Join(cloud, "creds_secret_cr.yaml"), cloudCredSecretValues)
-	case "azure":
-		cloudCredSecretValues := struct {
-			ManagedClusterName           string
-			ManagedClusterClientId       string
-			ManagedClusterClientSecret   string
-			ManagedClusterTenantId       string
-			ManagedClusterSubscriptionId string
-		}{
-			ManagedClusterName:           clusterName,
-			ManagedClusterClientId:       libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.ClientID,
-			ManagedClusterClientSecret:   libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.ClientSecret,
-			ManagedClusterTenantId:       libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.TenantID,
-			ManagedClusterSubscriptionId: libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.SubscriptionID,
-		}
-		return hubCreateApplier.CreateOrUpdateResource(filepath.Join(cloud, "creds_secret_cr.yaml"), cloudCredSecretValues)
-	case "gcp":
-		cloudCredSecretValues := struct {
-			ManagedClusterName      string
-			GCPOSServiceAccountJson string
-		}{
-			ManagedClusterName:      clusterName,
-			GCPOSServiceAccountJson: libgooptions.TestOptions.Options.CloudConnection.APIKeys.GCP.ServiceAccountJSONKey,
-		}
-		return hubCreateApplier.CreateOrUpdateResource(filepath.Join(cloud, "creds_secret_cr.yaml"), cloudCredSecretValues)
-	// case "baremetal":
-	// 	return fmt.Println("baremetal")
-	default:
-		return fmt.Errorf("unsupporter cloud %s", cloud)
+	p, err := GetProvider(cloud)
+	if err != nil {
+		return err
+	}
+	if len(p.CredsSecretTemplates) == 0 {
+		return nil
 	}
+	return hubCreateApplier.CreateOrUpdateResources(p.CredsSecretTemplates, p.CredsSecretValues(clusterName))
 }
 
 func createInstallConfig(hubCreateApplier *applier.Applier,
 	createTemplateProcessor *templateprocessor.TemplateProcessor,
 	clusterName,
 	cloud string) error {
-	baseDomain, err := libgooptions.GetBaseDomain(cloud)
+	p, err := GetProvider(cloud)
 	if err != nil {
 		return err
 	}
-
-	var region string
-	if cloud != "baremetal" {
-		region, err = libgooptions.GetRegion(cloud)
-		if err != nil {
-			return err
-		}
+	baseDomain, err := p.BaseDomain()
+	if err != nil {
+		return err
 	}
-
-	var b []byte
-	switch cloud {
-	case "aws":
-		installConfigValues := struct {
-			ManagedClusterName         string
-			ManagedClusterBaseDomain   string
-			ManagedClusterRegion       string
-			ManagedClusterSSHPublicKey string
-		}{
-			ManagedClusterName:         clusterName,
-			ManagedClusterBaseDomain:   baseDomain,
-			ManagedClusterRegion:       region,
-			ManagedClusterSSHPublicKey: libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
-		}
-		b, err = createTemplateProcessor.TemplateResource(filepath.Join(cloud, "install_config.yaml"), installConfigValues)
-	case "azure":
-		installConfigValues := struct {
-			ManagedClusterName          string
-			ManagedClusterBaseDomain    string
-			ManagedClusterBaseDomainRGN string
-			ManagedClusterRegion        string
-			ManagedClusterSSHPublicKey  string
-		}{
-			ManagedClusterName:          clusterName,
-			ManagedClusterBaseDomain:    baseDomain,
-			ManagedClusterBaseDomainRGN: libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.BaseDomainRGN,
-			ManagedClusterRegion:        region,
-			ManagedClusterSSHPublicKey:  libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
-		}
-		b, err = createTemplateProcessor.TemplateResource(filepath.Join(cloud, "install_config.yaml"), installConfigValues)
-	case "gcp":
-		installConfigValues := struct {
-			ManagedClusterName         string
-			ManagedClusterBaseDomain   string
-			ManagedClusterProjectID    string
-			ManagedClusterRegion       string
-			ManagedClusterSSHPublicKey string
-		}{
-			ManagedClusterName:         clusterName,
-			ManagedClusterBaseDomain:   baseDomain,
-			ManagedClusterProjectID:    libgooptions.TestOptions.Options.CloudConnection.APIKeys.GCP.ProjectID,
-			ManagedClusterRegion:       region,
-			ManagedClusterSSHPublicKey: libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
-		}
-		b, err = createTemplateProcessor.TemplateResource(filepath.Join(cloud, "install_config.yaml"), installConfigValues)
-	case "baremetal":
-		installConfigValues := struct {
-			ManagedClusterName             string
-			ManagedClusterBaseDomain       string
-			LibvirtURI                     string
-			ProvisioningNetworkCIDR        string
-			ProvisioningNetworkInterface   string
-			ProvisioningBridge             string
-			ExternalBridge                 string
-			APIVIP                         string
-			IngressVIP                     string
-			ManagedClusterBootstrapOSImage string
-			ManagedClusterClusterOSImage   string
-			ManagedClusterSSHPublicKey     string
-			ManagedClusterTrustBundle      string
-			ImageRegistryMirror            string
-			Hosts                          []libgooptions.Hosts
-		}{
-			ManagedClusterName:             clusterName,
-			ManagedClusterBaseDomain:       baseDomain,
-			LibvirtURI:                     libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.LibvirtURI,
-			ProvisioningNetworkCIDR:        libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.ProvisioningNetworkCIDR,
-			ProvisioningNetworkInterface:   libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.ProvisioningNetworkInterface,
-			ProvisioningBridge:             libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.ProvisioningBridge,
-			ExternalBridge:                 libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.ExternalBridge,
-			APIVIP:                         libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.APIVIP,
-			IngressVIP:                     libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.IngressVIP,
-			ManagedClusterBootstrapOSImage: libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.BootstrapOSImage,
-			ManagedClusterClusterOSImage:   libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.ClusterOSImage,
-			ManagedClusterSSHPublicKey:     libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
-			ManagedClusterTrustBundle:      libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.TrustBundle,
-			ImageRegistryMirror:            libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.ImageRegistryMirror,
-			Hosts:                          libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.Hosts,
-		}
-
-		b, err = createTemplateProcessor.TemplateResource(filepath.Join(cloud, "install_config.yaml"), installConfigValues)
-	default:
-		return fmt.Errorf("unsupporter cloud %s", cloud)
-
+	region, err := p.region()
+	if err != nil {
+		return err
 	}
+	b, err := createTemplateProcessor.TemplateResource(p.InstallConfigTemplate, p.InstallConfigValues(clusterName, baseDomain, region))
 	if err != nil {
 		return err
 	}
@@ -608,6 +484,51 @@ func createInstallConfig(hubCreateApplier *applier.Applier,
 	return hubCreateApplier.CreateOrUpdateResource("install_config_secret_cr.yaml", installConfigSecretValues)
 }
 
+// renderPlatform returns the platform stanza of the clusterDeployment or clusterPool of the cloud provider
+func renderPlatform(createTemplateProcessor *templateprocessor.TemplateProcessor, name, cloud, region string) (string, error) {
+	p, err := GetProvider(cloud)
+	if err != nil {
+		return "", err
+	}
+	b, err := createTemplateProcessor.TemplateResource(p.PlatformTemplate, p.PlatformValues(name, region))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// createCredentialsManifests creates the configMap of the manifests of the manual credentials mode
+// if the cloud provider needs it and returns its name, "" otherwise.
+func createCredentialsManifests(hubClient kubernetes.Interface, clusterName, cloud string) (string, error) {
+	p, err := GetProvider(cloud)
+	if err != nil {
+		return "", err
+	}
+	if p.CredentialsManifests == nil {
+		return "", nil
+	}
+	manifests, err := p.CredentialsManifests()
+	if err != nil {
+		return "", err
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      clusterName + "-credentials-manifests",
+			Namespace: clusterName,
+		},
+		Data: manifests,
+	}
+	ownership.Own(configMap)
+	_, err = hubClient.CoreV1().ConfigMaps(clusterName).Create(context.TODO(), configMap, metav1.CreateOptions{})
+	switch {
+	case err == nil:
+		ownership.Record(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "ConfigMap", clusterName, configMap.Name)
+	case !errors.IsAlreadyExists(err):
+		return "", err
+	}
+	return configMap.Name, nil
+}
+
 func createKlusterletAddonConfig(hubCreateApplier *applier.Applier, clusterName, cloud, vendor string) {
 	By("creating the klusterletaddonconfig", func() {
 		values := struct {
@@ -667,31 +588,28 @@ func waitDetroyed(hubClientDynamic dynamic.Interface, clusterName string) {
 	By(fmt.Sprintf("Checking the deletion of the %s clusterDeployment on the hub", clusterName), func() {
 		klog.V(1).Infof("Cluster %s: Checking the deletion of the %s clusterDeployment on the hub", clusterName, clusterName)
 		gvr := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
-		Eventually(func() bool {
-			klog.V(1).Infof("Cluster %s: Wait %s clusterDeployment deletion...", clusterName, clusterName)
-			_, err := hubClientDynamic.Resource(gvr).Namespace(clusterName).Get(context.TODO(), clusterName, metav1.GetOptions{})
-			if err != nil {
-				klog.V(1).Info(err)
-				return errors.IsNotFound(err)
-			}
-			return false
-		}, 3600, 60).Should(BeTrue())
+		klog.V(1).Infof("Cluster %s: Wait %s clusterDeployment deletion...", clusterName, clusterName)
+		reports.TimeStep(reports.StepDestroy, func() {
+			Expect(newWaiter(hubClientDynamic, longInterval()).WaitFor(gvr, clusterName, clusterName, options.Timeout(options.TimeoutDeprovision),
+				func(clusterDeployment *unstructured.Unstructured) error {
+					if clusterDeployment == nil {
+						return nil
+					}
+					return failures.Classify(failures.ScopeDestroy, "ClusterDeploymentNotDeleted",
+						fmt.Sprintf("clusterDeployment %s can not be deleted", clusterName))
+				})).To(BeNil())
+		})
 		klog.V(1).Infof("Cluster %s: %s clusterDeployment deleted", clusterName, clusterName)
 	})
 }
 
-func waitClusterImported(hubClientDynamic dynamic.Interface, clusterName string) {
-	Eventually(func() error {
-		klog.V(1).Infof("Cluster %s: Wait %s to be imported...", clusterName, clusterName)
-		return checkClusterImported(hubClientDynamic, clusterName)
-	}, eventuallyTimeout, eventuallyInterval).Should(BeNil())
-	klog.V(1).Infof("Cluster %s: imported", clusterName)
-}
-
-func validateClusterImported(hubClientDynamic dynamic.Interface, hubClient kubernetes.Interface, clusterName string) {
+// getAdminKubeConfig returns the rest config of the admin kubeconfig of the clusterDeployment
+func getAdminKubeConfig(hubClientDynamic dynamic.Interface, hubClient kubernetes.Interface, clusterName string) (*rest.Config, error) {
 	gvr := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
 	clusterDeployment, err := hubClientDynamic.Resource(gvr).Namespace(clusterName).Get(context.TODO(), clusterName, metav1.GetOptions{})
-	Expect(err).To(BeNil())
+	if err != nil {
+		return nil, err
+	}
 	var configSecretRef string
 	if si, ok := clusterDeployment.Object["spec"]; ok {
 		s := si.(map[string]interface{})
@@ -706,15 +624,23 @@ func validateClusterImported(hubClientDynamic dynamic.Interface, hubClient kuber
 		}
 	}
 	if configSecretRef == "" {
-		Fail(fmt.Sprintf("adminKubeconfigSecretRef.name not found in clusterDeployment %s", clusterName))
+		return nil, fmt.Errorf("adminKubeconfigSecretRef.name not found in clusterDeployment %s", clusterName)
 	}
 	s, err := hubClient.CoreV1().Secrets(clusterName).Get(context.TODO(), configSecretRef, metav1.GetOptions{})
-	Expect(err).To(BeNil())
+	if err != nil {
+		return nil, err
+	}
 	config, err := clientcmd.Load(s.Data["kubeconfig"])
-	Expect(err).To(BeNil())
-	rconfig, err := clientcmd.NewDefaultClientConfig(
+	if err != nil {
+		return nil, err
+	}
+	return clientcmd.NewDefaultClientConfig(
 		*config,
 		&clientcmd.ConfigOverrides{}).ClientConfig()
+}
+
+func validateClusterImported(hubClientDynamic dynamic.Interface, hubClient kubernetes.Interface, clusterName string) {
+	rconfig, err := getAdminKubeConfig(hubClientDynamic, hubClient, clusterName)
 	Expect(err).To(BeNil())
 	By("Checking if \"open-cluster-management-agent\" namespace on managed cluster exists", func() {
 		clientset, err := kubernetes.NewForConfig(rconfig)
@@ -733,44 +659,65 @@ func validateClusterImported(hubClientDynamic dynamic.Interface, hubClient kuber
 	})
 }
 
+// WaitClusterAdddonsAvailable waits for the add-ons expected on the cluster to be available,
+// neither degraded nor progressing.
 func WaitClusterAdddonsAvailable(hubClientDynamic dynamic.Interface, clusterName string) {
-	// gvr := schema.GroupVersionResource{Group: "addon.open-cluster-management.io", Version: "v1alpha1", Resource: "managedclusteraddons"}
-	for _, addOnName := range managedClusteraddOns {
-		if !(clusterName == "local-cluster" && addOnName == "search-collector") {
-			Eventually(func() error {
-				klog.V(1).Infof("Cluster %s: Checking Add-On %s is available...", clusterName, addOnName)
-				return validateClusterAddOnAvailable(hubClientDynamic, clusterName, addOnName)
-			}, eventuallyTimeout, eventuallyInterval).Should(BeNil())
-			klog.V(1).Infof("Cluster %s: all add-ons are available", clusterName)
-		}
+	addOnNames, err := expectedAddOns(hubClientDynamic, clusterName)
+	Expect(err).To(BeNil())
+	klog.V(1).Infof("Cluster %s: Add-Ons %v expected", clusterName, addOnNames)
+	for _, addOnName := range addOnNames {
+		klog.V(1).Infof("Cluster %s: Checking Add-On %s is available...", clusterName, addOnName)
+		addOnName := addOnName
+		reports.TimeStep(reports.StepAddOnPrefix+addOnName, func() {
+			Expect(newWaiter(hubClientDynamic, eventuallyInterval()).WaitFor(gvrManagedClusterAddOn, clusterName, addOnName, eventuallyTimeout(),
+				func(managedClusterAddon *unstructured.Unstructured) error {
+					return validateClusterAddOnAvailable(managedClusterAddon, clusterName, addOnName)
+				})).To(BeNil())
+		})
 	}
+	klog.V(1).Infof("Cluster %s: all add-ons are available", clusterName)
 }
 
-func validateClusterAddOnAvailable(hubClientDynamic dynamic.Interface, clusterName string, addOnName string) error {
-
-	gvr := schema.GroupVersionResource{Group: "addon.open-cluster-management.io", Version: "v1alpha1", Resource: "managedclusteraddons"}
-	managedClusterAddon, err := hubClientDynamic.Resource(gvr).Namespace(clusterName).Get(context.TODO(), addOnName, metav1.GetOptions{})
-	Expect(err).To(BeNil())
+// validateClusterAddOnAvailable returns nil if the add-on is available and its Degraded and Progressing conditions,
+// when set, are not true.
+func validateClusterAddOnAvailable(managedClusterAddon *unstructured.Unstructured, clusterName string, addOnName string) error {
+	if managedClusterAddon == nil {
+		return fmt.Errorf("cluster %s - Add-On %s: not found", clusterName, addOnName)
+	}
 
-	var condition map[string]interface{}
-	condition, err = libgounstructuredv1.GetConditionByType(managedClusterAddon, "Available")
+	condition, err := libgounstructuredv1.GetConditionByType(managedClusterAddon, "Available")
 	if err != nil {
 		klog.V(4).Infof("Cluster %s - Add-On %s: %s", clusterName, addOnName, err)
 		return err
 	}
 	klog.V(4).Info(condition)
-	if v, ok := condition["status"]; ok && v == string(metav1.ConditionTrue) {
-		klog.V(1).Infof("Cluster %s: Add-On %s is available...", clusterName, addOnName)
-		return nil
+	if v, ok := condition["status"]; !ok || v != string(metav1.ConditionTrue) {
+		err = failures.Classify(failures.ScopeAddon, "AddOnNotAvailable",
+			fmt.Sprintf("cluster %s - Add-On %s: status not found or not true", clusterName, addOnName))
+		klog.V(4).Infof("Cluster %s - Add-On %s: %s", clusterName, addOnName, err)
+		return err
 	}
-	err = fmt.Errorf("cluster %s - Add-On %s: status not found or not true", clusterName, addOnName)
-	klog.V(4).Infof("Cluster %s - Add-On %s: %s", clusterName, addOnName, err)
-	return err
-
+	for _, c := range []struct {
+		conditionType string
+		reason        string
+	}{
+		{conditionType: "Degraded", reason: "AddOnDegraded"},
+		{conditionType: "Progressing", reason: "AddOnProgressing"},
+	} {
+		// the conditions not reported by the add-on are not found
+		if status, _ := waiters.ConditionStatus(managedClusterAddon, c.conditionType); status == string(metav1.ConditionTrue) {
+			err := failures.Classify(failures.ScopeAddon, c.reason,
+				fmt.Sprintf("cluster %s - Add-On %s: condition %s is true", clusterName, addOnName, c.conditionType))
+			klog.V(4).Infof("Cluster %s - Add-On %s: %s", clusterName, addOnName, err)
+			return err
+		}
+	}
+	klog.V(1).Infof("Cluster %s: Add-On %s is available...", clusterName, addOnName)
+	return nil
 }
 
 func DestroyCluster(cloud, vendor, cloudProviders string) {
-	var clusterName string
+	var clusterName, namespace, imageSetName string
 	var hubClients *clients.HubClients
 
 	BeforeEach(func() {
@@ -780,32 +727,33 @@ func DestroyCluster(cloud, vendor, cloudProviders string) {
 		}
 
 		hubClients = clients.GetHubClients()
-		gvrClusterDeployment := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
-		clusterDeploymentList, err := hubClients.DynamicClient.Resource(gvrClusterDeployment).List(context.TODO(), metav1.ListOptions{})
+		entry, err := manifest.Lookup(hubClients.KubeClient, ownership.RunID(), cloud)
 		Expect(err).To(BeNil())
-
-		for _, cd := range clusterDeploymentList.Items {
-			if metadata, ok := cd.Object["metadata"]; ok {
-				meta := metadata.(map[string]interface{})
-				if name, ok := meta["name"]; ok {
-					if strings.HasPrefix(name.(string), cloud+"-"+libgooptions.GetOwner()) {
-						clusterName = name.(string)
-						break
-					}
-				}
+		switch {
+		case entry != nil:
+			clusterName = entry.Cluster
+			namespace = entry.Namespace
+			imageSetName = entry.ImageSet
+		case manifest.Forced():
+			clusterName = getOwnedClusterName(hubClients.DynamicClient, cloud)
+			if len(clusterName) == 0 {
+				Fail(fmt.Sprintf("No cluster for Cloud provider %s to delete", cloud))
 			}
-		}
-		if len(clusterName) == 0 {
-			Fail(fmt.Sprintf("No cluster for Cloud provider %s to delete", cloud))
-		}
-
-		if cloud == "baremetal" {
-			clusterName = libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.ClusterName
+			if cloud == "baremetal" {
+				clusterName = libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.ClusterName
+			}
+			namespace = clusterName
+			imageSetName = ""
+		default:
+			Fail(fmt.Sprintf("No cluster for Cloud provider %s recorded in the run manifest %s/%s of the run %s, "+
+				"set -run-id to the run which created it or -force-destroy to delete the cluster of the owner %s",
+				cloud, manifest.Namespace, manifest.Name(ownership.RunID()), ownership.RunID(), libgooptions.GetOwner()))
 		}
 
+		reports.SetCluster(clusterName, cloud, "")
 		klog.V(1).Infof(`========================= Start Test destroy cluster %s  ===============================`, clusterName)
-		SetDefaultEventuallyTimeout(10 * time.Minute)
-		SetDefaultEventuallyPollingInterval(10 * time.Second)
+		SetDefaultEventuallyTimeout(eventuallyTimeout())
+		SetDefaultEventuallyPollingInterval(eventuallyInterval())
 	})
 
 	AfterEach(func() {
@@ -861,22 +809,36 @@ func DestroyCluster(cloud, vendor, cloudProviders string) {
 
 		By(fmt.Sprintf("Detaching the %s CR on the hub", clusterName), func() {
 			klog.V(1).Infof("Cluster %s: Detaching the %s CR on the hub", clusterName, clusterName)
-			gvr := schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
-			Expect(hubClients.DynamicClient.Resource(gvr).Delete(context.TODO(), clusterName, metav1.DeleteOptions{})).Should(BeNil())
+			Expect(hubClients.DynamicClient.Resource(gvrManagedCluster).Delete(context.TODO(), clusterName, metav1.DeleteOptions{})).Should(BeNil())
 		})
 
 		When(fmt.Sprintf("Detached, delete the clusterDeployment %s", clusterName), func() {
 			klog.V(1).Infof("Cluster %s: Deleting the clusterDeployment for cluster %s", clusterName, clusterName)
 			gvr := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
-			Expect(hubClients.DynamicClient.Resource(gvr).Namespace(clusterName).Delete(context.TODO(), clusterName, metav1.DeleteOptions{})).Should(BeNil())
+			clusterDeployment, err := hubClients.DynamicClient.Resource(gvr).Namespace(namespace).Get(context.TODO(), clusterName, metav1.GetOptions{})
+			Expect(err).To(BeNil())
+			if imageSetName == "" {
+				imageSetName, _, _ = unstructured.NestedString(clusterDeployment.Object, "spec", "provisioning", "imageSetRef", "name")
+			}
+			Expect(hubClients.DynamicClient.Resource(gvr).Namespace(namespace).Delete(context.TODO(), clusterName, metav1.DeleteOptions{})).Should(BeNil())
 		})
 
 		When(fmt.Sprintf("Wait clusterDeployment %s to be deleted", clusterName), func() {
 			waitDetroyed(hubClients.DynamicClient, clusterName)
 		})
 
-		When(fmt.Sprintf("Wait namespace %s to be deleted", clusterName), func() {
-			waitNamespaceDeleted(hubClients.KubeClient, hubClients.DynamicClient, hubClients.DiscoveryClient, clusterName)
+		When(fmt.Sprintf("Wait namespace %s to be deleted", namespace), func() {
+			waitNamespaceDeleted(hubClients.DynamicClient, hubClients.DiscoveryClient, namespace)
+		})
+
+		if imageSetName != "" {
+			When(fmt.Sprintf("Namespace %s deleted, delete the clusterImageSet %s created for the cluster", namespace, imageSetName), func() {
+				Expect(deleteOwnedClusterImageSet(hubClients.DynamicClient, clusterName, imageSetName)).To(BeNil())
+			})
+		}
+
+		By(fmt.Sprintf("Removing the cluster %s from the run manifest", clusterName), func() {
+			Expect(manifest.Remove(hubClients.KubeClient, ownership.RunID(), clusterName)).To(BeNil())
 		})
 
 		klog.V(1).Infof("========================= End Test destroy cluster %s ===============================", clusterName)
@@ -885,30 +847,63 @@ func DestroyCluster(cloud, vendor, cloudProviders string) {
 
 }
 
+// getOwnedClusterName returns the name of a clusterDeployment created by the owner on the cloud
+// or "" if none is found. It may be the cluster of another run of the owner, it is only used
+// by the forced destroy of a cluster not recorded in a run manifest.
+func getOwnedClusterName(hubClientDynamic dynamic.Interface, cloud string) string {
+	gvrClusterDeployment := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
+	clusterDeploymentList, err := hubClientDynamic.Resource(gvrClusterDeployment).List(context.TODO(), metav1.ListOptions{})
+	Expect(err).To(BeNil())
+
+	for _, cd := range clusterDeploymentList.Items {
+		if metadata, ok := cd.Object["metadata"]; ok {
+			meta := metadata.(map[string]interface{})
+			if name, ok := meta["name"]; ok {
+				if strings.HasPrefix(name.(string), cloud+"-"+libgooptions.GetOwner()) {
+					return name.(string)
+				}
+			}
+		}
+	}
+	return ""
+}
+
 func waitNamespaceDeleted(
-	hubClient kubernetes.Interface,
 	hubClientDynamic dynamic.Interface,
 	hubClientDiscovery *discovery.DiscoveryClient,
 	clusterName string) {
 	By(fmt.Sprintf("Checking the deletion of the %s namespace on the hub", clusterName), func() {
 		klog.V(1).Infof("Cluster %s: Checking the deletion of the %s namespace on the hub", clusterName, clusterName)
-		Eventually(func() bool {
-			klog.V(1).Infof("Cluster %s: Wait %s namespace deletion...", clusterName, clusterName)
-			_, err := hubClient.CoreV1().Namespaces().Get(context.TODO(), clusterName, metav1.GetOptions{})
-			if err != nil {
-				klog.V(1).Info(err)
-				return errors.IsNotFound(err)
-			}
-			err = PrintLeftOver(hubClientDynamic, hubClientDiscovery, clusterName)
-			if err != nil {
-				klog.Error(err)
-			}
-			return false
-		}, 3600, 60).Should(BeTrue())
+		reports.TimeStep(reports.StepNamespaceDeletion, func() {
+			WaitNamespaceDeleted(hubClientDynamic, hubClientDiscovery, clusterName, failures.ScopeDestroy, options.Timeout(options.TimeoutNamespaceDeletion))
+		})
 		klog.V(1).Infof("Cluster %s: %s namespace deleted", clusterName, clusterName)
 	})
 }
 
+// WaitNamespaceDeleted waits for the namespace to be deleted and prints
+// the resources left in the namespace each time it is checked.
+// On timeout, the failure is classified in the scope.
+func WaitNamespaceDeleted(
+	dynamicClient dynamic.Interface,
+	discoveryClient *discovery.DiscoveryClient,
+	ns string,
+	scope string,
+	timeout time.Duration) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	Expect(newWaiter(dynamicClient, longInterval()).WaitFor(gvr, "", ns, timeout, func(namespace *unstructured.Unstructured) error {
+		klog.V(1).Infof("Wait %s namespace deletion...", ns)
+		if namespace == nil {
+			return nil
+		}
+		err := PrintLeftOver(dynamicClient, discoveryClient, ns)
+		if err != nil {
+			klog.Error(err)
+		}
+		return failures.Classify(scope, "NamespaceNotDeleted", fmt.Sprintf("namespace %s not deleted", ns))
+	})).To(BeNil())
+}
+
 func PrintLeftOver(dynamicClient dynamic.Interface, discoveryClient *discovery.DiscoveryClient, ns string) error {
 	klog.Infof("==================== Left Over in %s ======================", ns)
 	_, err := dynamicClient.Resource(schema.GroupVersionResource{
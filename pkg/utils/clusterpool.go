@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/appliers"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
+	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+var (
+	gvrClusterPool     = schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterpools"}
+	gvrClusterClaim    = schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterclaims"}
+	gvrClusterImageSet = schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterimagesets"}
+)
+
+// ClusterPoolLifecycle creates a clusterPool on the cloud, claims a cluster from it,
+// checks the claimed cluster is imported, releases the claim and deletes the clusterPool
+// and the clusterImageSet created for it.
+func ClusterPoolLifecycle(cloud, vendor, cloudProviders string) {
+	var provider *Provider
+	var clusterNameObj *libgooptions.ClusterName
+	var poolName, claimName, clusterName, region string
+	var err error
+	var hubAppliers *appliers.HubAppliers
+	var hubClients *clients.HubClients
+
+	BeforeEach(func() {
+		if cloudProviders != "" && !isRequestedCloudProvider(cloud, cloudProviders) {
+			Skip(fmt.Sprintf("Cloud provider %s skipped", cloud))
+		}
+		hubClients = clients.GetHubClients()
+		hubAppliers = appliers.GetHubAppliers(hubClients)
+		provider, err = GetProvider(cloud)
+		Expect(err).To(BeNil())
+		clusterNameObj, err = newClusterName(cloud)
+		Expect(err).To(BeNil())
+		poolName = clusterNameObj.String()
+		claimName = poolName + "-claim"
+		region, err = provider.region()
+		Expect(err).To(BeNil())
+		klog.V(1).Infof(`========================= Start Test clusterPool %s ===============================`, poolName)
+	})
+
+	It(fmt.Sprintf("[P1][Sev1][cluster-lifecycle] Claim a cluster from a clusterPool on %s with vendor %s (cluster/g1/clusterpool)", cloud, vendor), func() {
+		By("Checking the minimal requirements", func() {
+			Eventually(func() bool {
+				klog.V(2).Infof("ClusterPool %s: Check CRDs", poolName)
+				has, missing, _ := libgocrdv1.HasCRDs(hubClients.APIExtensionClient,
+					[]string{
+						"managedclusters.cluster.open-cluster-management.io",
+						"clusterpools.hive.openshift.io",
+						"clusterclaims.hive.openshift.io",
+					})
+				if !has {
+					klog.Errorf("ClusterPool %s: Missing CRDs\n%#v", poolName, missing)
+				}
+				return has
+			}).Should(BeTrue())
+		})
+
+		By(fmt.Sprintf("Creating the namespace %s of the clusterPool", poolName), func() {
+			klog.V(1).Infof("ClusterPool %s: Creating the namespace", poolName)
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: poolName,
+				},
+			}
+			ownership.Own(namespace)
+			_, err := hubClients.KubeClient.CoreV1().Namespaces().Create(context.TODO(), namespace, metav1.CreateOptions{})
+			switch {
+			case err == nil:
+				ownership.Record(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, "Namespace", "", poolName)
+			case !errors.IsAlreadyExists(err):
+				Fail(err.Error())
+			}
+		})
+
+		var imageRefName string
+		By("Creating the needed resources", func() {
+			klog.V(1).Infof("ClusterPool %s: Creating the needed resources", poolName)
+			pullSecret := &corev1.Secret{}
+			Expect(hubClients.ClientClient.Get(context.TODO(),
+				types.NamespacedName{
+					Name:      "pull-secret",
+					Namespace: "openshift-config",
+				},
+				pullSecret)).To(BeNil())
+			values := struct {
+				ManagedClusterName       string
+				ManagedClusterPullSecret string
+			}{
+				ManagedClusterName:       poolName,
+				ManagedClusterPullSecret: string(pullSecret.Data[".dockerconfigjson"]),
+			}
+			Expect(hubAppliers.CreateApplier.CreateOrUpdateResource("pull_secret_cr.yaml", values)).To(BeNil())
+			klog.V(1).Infof("ClusterPool %s: Creating the %s cred secret", poolName, cloud)
+			Expect(createCredentialsSecret(hubAppliers.CreateApplier, poolName, cloud)).To(BeNil())
+			klog.V(1).Infof("ClusterPool %s: Creating install config secret", poolName)
+			Expect(createInstallConfig(hubAppliers.CreateApplier, hubAppliers.CreateTemplateProcessor, poolName, cloud)).To(BeNil())
+			imageRefName, err = getClusterImageSetName(hubClients.DynamicClient, hubAppliers.CreateApplier, clusterNameObj, cloud)
+			Expect(err).To(BeNil())
+			Expect(imageRefName).NotTo(Equal(""))
+			reports.SetImageSet(imageRefName)
+		})
+
+		By(fmt.Sprintf("Creating the clusterPool %s", poolName), func() {
+			baseDomain, err := provider.BaseDomain()
+			Expect(err).To(BeNil())
+			platform, err := renderPlatform(hubAppliers.CreateTemplateProcessor, poolName, cloud, region)
+			Expect(err).To(BeNil())
+			values := struct {
+				ClusterPoolName            string
+				ManagedClusterCloud        string
+				ManagedClusterRegion       string
+				ManagedClusterVendor       string
+				ManagedClusterBaseDomain   string
+				ManagedClusterImageRefName string
+				ManagedClusterPlatform     string
+			}{
+				ClusterPoolName:            poolName,
+				ManagedClusterCloud:        cloud,
+				ManagedClusterRegion:       region,
+				ManagedClusterVendor:       vendor,
+				ManagedClusterBaseDomain:   baseDomain,
+				ManagedClusterImageRefName: imageRefName,
+				ManagedClusterPlatform:     platform,
+			}
+			klog.V(1).Infof("ClusterPool %s: Creating the clusterPool", poolName)
+			Expect(hubAppliers.ClusterPoolApplier.CreateOrUpdateResource("cluster_pool_cr.yaml", values)).To(BeNil())
+		})
+
+		When(fmt.Sprintf("ClusterPool %s created, wait for a cluster to be ready in the pool", poolName), func() {
+			reports.TimeStep(reports.StepClusterPoolReady, func() {
+				waitClusterPoolReady(hubClients.DynamicClient, poolName)
+			})
+		})
+
+		By(fmt.Sprintf("Claiming a cluster from the clusterPool %s", poolName), func() {
+			values := struct {
+				ClusterPoolName  string
+				ClusterClaimName string
+			}{
+				ClusterPoolName:  poolName,
+				ClusterClaimName: claimName,
+			}
+			klog.V(1).Infof("ClusterPool %s: Creating the clusterClaim %s", poolName, claimName)
+			Expect(hubAppliers.ClusterPoolApplier.CreateOrUpdateResource("cluster_claim_cr.yaml", values)).To(BeNil())
+		})
+
+		When(fmt.Sprintf("ClusterClaim %s created, wait for a cluster to be assigned", claimName), func() {
+			reports.TimeStep(reports.StepClusterClaimAssigned, func() {
+				clusterName = waitClusterClaimAssigned(hubClients.DynamicClient, poolName, claimName)
+			})
+			reports.SetCluster(clusterName, cloud, region)
+		})
+
+		When(fmt.Sprintf("Cluster %s claimed, wait for the cluster to be auto-imported", clusterName), func() {
+			WaitClusterImported(hubClients.DynamicClient, clusterName)
+			WaitClusterAdddonsAvailable(hubClients.DynamicClient, clusterName)
+		})
+
+		When(fmt.Sprintf("Releasing the clusterClaim %s, wait for the claimed cluster to be cleaned", claimName), func() {
+			reports.TimeStep(reports.StepClusterClaimRelease, func() {
+				klog.V(1).Infof("ClusterPool %s: Deleting the clusterClaim %s", poolName, claimName)
+				Expect(hubClients.DynamicClient.Resource(gvrClusterClaim).Namespace(poolName).Delete(context.TODO(), claimName, metav1.DeleteOptions{})).To(BeNil())
+				waitDetroyed(hubClients.DynamicClient, clusterName)
+				detachReleasedCluster(hubClients.DynamicClient, clusterName)
+				waitNamespaceDeleted(hubClients.DynamicClient, hubClients.DiscoveryClient, clusterName)
+			})
+		})
+
+		When(fmt.Sprintf("Deleting the clusterPool %s, wait for the pool to be cleaned", poolName), func() {
+			klog.V(1).Infof("ClusterPool %s: Deleting the clusterPool", poolName)
+			Expect(hubClients.DynamicClient.Resource(gvrClusterPool).Namespace(poolName).Delete(context.TODO(), poolName, metav1.DeleteOptions{})).To(BeNil())
+			Expect(newWaiter(hubClients.DynamicClient, longInterval()).WaitFor(gvrClusterPool, poolName, poolName, options.Timeout(options.TimeoutClusterPoolDeletion),
+				func(clusterPool *unstructured.Unstructured) error {
+					if clusterPool == nil {
+						return nil
+					}
+					return failures.Classify(failures.ScopeClusterPool, "ClusterPoolNotDeleted",
+						fmt.Sprintf("clusterPool %s can not be deleted", poolName))
+				})).To(BeNil())
+			Expect(hubClients.KubeClient.CoreV1().Namespaces().Delete(context.TODO(), poolName, metav1.DeleteOptions{})).To(BeNil())
+			waitNamespaceDeleted(hubClients.DynamicClient, hubClients.DiscoveryClient, poolName)
+		})
+
+		When(fmt.Sprintf("ClusterPool %s deleted, delete the clusterImageSet %s created for the pool", poolName, imageRefName), func() {
+			Expect(deleteOwnedClusterImageSet(hubClients.DynamicClient, poolName, imageRefName)).To(BeNil())
+			// a clusterImageSet which was not created by the run is left as is
+			Expect(newWaiter(hubClients.DynamicClient, eventuallyInterval()).WaitFor(gvrClusterImageSet, "", imageRefName, eventuallyTimeout(),
+				func(imageSet *unstructured.Unstructured) error {
+					if imageSet == nil {
+						return nil
+					}
+					if _, ok := imageSet.GetLabels()[ownership.RunIDLabel]; !ok {
+						return nil
+					}
+					return failures.Classify(failures.ScopeClusterPool, "ClusterImageSetNotDeleted",
+						fmt.Sprintf("clusterImageSet %s of the clusterPool %s can not be deleted", imageRefName, poolName))
+				})).To(BeNil())
+		})
+
+		klog.V(1).Infof("========================= End Test clusterPool %s ===============================", poolName)
+	})
+}
+
+// waitClusterPoolReady waits for at least one cluster of the pool to be ready to be claimed.
+func waitClusterPoolReady(hubClientDynamic dynamic.Interface, poolName string) {
+	klog.V(1).Infof("ClusterPool %s: Wait a cluster to be ready...", poolName)
+	Expect(newWaiter(hubClientDynamic, longInterval()).WaitFor(gvrClusterPool, poolName, poolName, options.Timeout(options.TimeoutClusterPoolReady),
+		func(clusterPool *unstructured.Unstructured) error {
+			if clusterPool == nil {
+				return fmt.Errorf("ClusterPool %s: not found", poolName)
+			}
+			ready, _, _ := unstructured.NestedInt64(clusterPool.Object, "status", "ready")
+			if ready < 1 {
+				return failures.Classify(failures.ScopeClusterPool, "ClusterPoolNotReady",
+					fmt.Sprintf("clusterPool %s has no ready cluster", poolName))
+			}
+			return nil
+		})).To(BeNil())
+	klog.V(1).Infof("ClusterPool %s: a cluster is ready", poolName)
+}
+
+// waitClusterClaimAssigned waits for a cluster to be assigned to the claim and returns its name.
+func waitClusterClaimAssigned(hubClientDynamic dynamic.Interface, poolName, claimName string) string {
+	klog.V(1).Infof("ClusterPool %s: Wait the clusterClaim %s to be assigned...", poolName, claimName)
+	var clusterName string
+	Expect(newWaiter(hubClientDynamic, eventuallyInterval()).WaitFor(gvrClusterClaim, poolName, claimName, eventuallyTimeout(),
+		func(clusterClaim *unstructured.Unstructured) error {
+			if clusterClaim == nil {
+				return fmt.Errorf("ClusterClaim %s: not found", claimName)
+			}
+			clusterName, _, _ = unstructured.NestedString(clusterClaim.Object, "spec", "namespace")
+			if clusterName == "" {
+				return failures.Classify(failures.ScopeClusterPool, "ClusterClaimPending",
+					fmt.Sprintf("no cluster assigned to the clusterClaim %s", claimName))
+			}
+			return nil
+		})).To(BeNil())
+	klog.V(1).Infof("ClusterPool %s: cluster %s assigned to the clusterClaim %s", poolName, clusterName, claimName)
+	return clusterName
+}
+
+// detachReleasedCluster deletes the managedCluster of a released cluster if it is not already deleted
+// and waits for its deletion.
+func detachReleasedCluster(hubClientDynamic dynamic.Interface, clusterName string) {
+	klog.V(1).Infof("Cluster %s: Detaching the released cluster", clusterName)
+	err := hubClientDynamic.Resource(gvrManagedCluster).Delete(context.TODO(), clusterName, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		Fail(err.Error())
+	}
+	Expect(newWaiter(hubClientDynamic, eventuallyInterval()).WaitFor(gvrManagedCluster, "", clusterName, eventuallyTimeout(),
+		func(managedCluster *unstructured.Unstructured) error {
+			if managedCluster == nil {
+				return nil
+			}
+			return failures.Classify(failures.ScopeClusterPool, "ManagedClusterNotDeleted",
+				fmt.Sprintf("managedCluster %s of the released cluster can not be deleted", clusterName))
+		})).To(BeNil())
+}
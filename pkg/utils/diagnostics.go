@@ -0,0 +1,76 @@
+package utils
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/diagnostics"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+)
+
+// CollectDiagnosticsOnFailure collects in dir/<cluster> the diagnostics of the clusters
+// tested by the current spec if the spec failed.
+// It must be called in an AfterEach.
+func CollectDiagnosticsOnFailure(dir string) {
+	if !CurrentGinkgoTestDescription().Failed {
+		return
+	}
+	// the spec may have tested several clusters before failing, the diagnostics of each one are collected
+	clusterNames := reports.CurrentClusters()
+	if len(clusterNames) == 0 {
+		return
+	}
+	hubClients := clients.GetHubClients()
+	collector := diagnostics.NewCollector(diagnostics.Clients{
+		KubeClient:    hubClients.KubeClient,
+		DynamicClient: hubClients.DynamicClient,
+	}, dir)
+	for _, clusterName := range clusterNames {
+		managed, err := managedClusterClients(hubClients, clusterName)
+		if err != nil {
+			klog.Errorf("Cluster %s: the managed cluster diagnostics can not be collected: %s", clusterName, err)
+		}
+		if err := collector.Collect(clusterName, managed); err != nil {
+			klog.Errorf("Cluster %s: the diagnostics are incomplete: %s", clusterName, err)
+		}
+	}
+}
+
+// managedClusterClients returns the clients to the managed cluster using the kubeconfig
+// of the options for the imported clusters or the admin kubeconfig of the clusterDeployment.
+// It returns an error if no kubeconfig is available.
+func managedClusterClients(hubClients *clients.HubClients, clusterName string) (*diagnostics.Clients, error) {
+	var config *rest.Config
+	var err error
+	for _, managedCluster := range libgooptions.TestOptions.Options.ManagedClusters {
+		if managedCluster.Name == clusterName && managedCluster.KubeConfig != "" {
+			config, err = clientcmd.BuildConfigFromFlags("", managedCluster.KubeConfig)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if config == nil {
+		config, err = getAdminKubeConfig(hubClients.DynamicClient, hubClients.KubeClient, clusterName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &diagnostics.Clients{
+		KubeClient:    kubeClient,
+		DynamicClient: dynamicClient,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/manifest"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+const (
+	PowerStateHibernating = "Hibernating"
+	PowerStateRunning     = "Running"
+)
+
+// HibernateResumeCluster hibernates and resumes a cluster created by CreateCluster on the cloud
+// and checks the managedCluster goes unknown without being detached and then comes back available.
+func HibernateResumeCluster(cloud, vendor, cloudProviders string) {
+	var clusterName string
+	var hubClients *clients.HubClients
+
+	BeforeEach(func() {
+		if cloudProviders != "" && !isRequestedCloudProvider(cloud, cloudProviders) {
+			Skip(fmt.Sprintf("Cloud provider %s skipped", cloud))
+		}
+		hubClients = clients.GetHubClients()
+		// the cluster is the one created by this run, another run of the same owner may share the hub
+		entry, err := manifest.Lookup(hubClients.KubeClient, ownership.RunID(), cloud)
+		Expect(err).To(BeNil())
+		if entry == nil {
+			Fail(fmt.Sprintf("No cluster for Cloud provider %s to hibernate recorded in the run manifest %s/%s of the run %s, "+
+				"set -run-id to the run which created it",
+				cloud, manifest.Namespace, manifest.Name(ownership.RunID()), ownership.RunID()))
+		}
+		clusterName = entry.Cluster
+		reports.SetCluster(clusterName, cloud, "")
+		klog.V(1).Infof(`========================= Start Test hibernate cluster %s ===============================`, clusterName)
+	})
+
+	It(fmt.Sprintf("[P1][Sev1][cluster-lifecycle] Hibernate and resume cluster on %s with vendor %s (cluster/g1/hibernate-cluster)", cloud, vendor), func() {
+		By(fmt.Sprintf("Checking the cluster %s is available before the hibernation", clusterName), func() {
+			WaitClusterImported(hubClients.DynamicClient, clusterName)
+		})
+
+		When(fmt.Sprintf("Hibernating the cluster %s, wait for the clusterDeployment to be hibernating", clusterName), func() {
+			reports.TimeStep(reports.StepHibernate, func() {
+				Expect(setPowerState(hubClients.DynamicClient, clusterName, PowerStateHibernating)).To(BeNil())
+				waitPowerState(hubClients.DynamicClient, clusterName, PowerStateHibernating)
+			})
+		})
+
+		When(fmt.Sprintf("Cluster %s hibernating, wait for the managedCluster to be unknown", clusterName), func() {
+			reports.TimeStep(reports.StepClusterUnknown, func() {
+				waitClusterUnknown(hubClients.DynamicClient, clusterName)
+			})
+		})
+
+		When(fmt.Sprintf("Resuming the cluster %s, wait for the clusterDeployment to be running", clusterName), func() {
+			reports.TimeStep(reports.StepResume, func() {
+				Expect(setPowerState(hubClients.DynamicClient, clusterName, PowerStateRunning)).To(BeNil())
+				waitPowerState(hubClients.DynamicClient, clusterName, PowerStateRunning)
+			})
+		})
+
+		When(fmt.Sprintf("Cluster %s resumed, wait for the cluster to be available", clusterName), func() {
+			WaitClusterImported(hubClients.DynamicClient, clusterName)
+		})
+
+		if cloud != "baremetal" {
+			When(fmt.Sprintf("Cluster %s resumed, wait for Add-Ons to be available", clusterName), func() {
+				WaitClusterAdddonsAvailable(hubClients.DynamicClient, clusterName)
+			})
+		}
+
+		klog.V(1).Infof("========================= End Test hibernate cluster %s ===============================", clusterName)
+	})
+}
+
+func setPowerState(hubClientDynamic dynamic.Interface, clusterName, powerState string) error {
+	klog.V(1).Infof("Cluster %s: Set the powerState to %s", clusterName, powerState)
+	gvr := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
+	patch := fmt.Sprintf(`{"spec":{"powerState":%q}}`, powerState)
+	_, err := hubClientDynamic.Resource(gvr).Namespace(clusterName).Patch(context.TODO(), clusterName, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+func waitPowerState(hubClientDynamic dynamic.Interface, clusterName, powerState string) {
+	klog.V(1).Infof("Cluster %s: Wait the clusterDeployment to be %s...", clusterName, powerState)
+	gvr := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterdeployments"}
+	Expect(newWaiter(hubClientDynamic, longInterval()).WaitFor(gvr, clusterName, clusterName, options.Timeout(options.TimeoutPowerState),
+		func(clusterDeployment *unstructured.Unstructured) error {
+			if clusterDeployment == nil {
+				return fmt.Errorf("Cluster %s: clusterDeployment not found", clusterName)
+			}
+			return checkPowerState(clusterDeployment, clusterName, powerState)
+		})).To(BeNil())
+	klog.V(1).Infof("Cluster %s: clusterDeployment %s", clusterName, powerState)
+}
+
+// checkPowerState checks the powerState reported in the clusterDeployment status,
+// or the Hibernating condition for the hive versions which don't report it.
+func checkPowerState(clusterDeployment *unstructured.Unstructured, clusterName, powerState string) error {
+	current, found, _ := unstructured.NestedString(clusterDeployment.Object, "status", "powerState")
+	if !found {
+		// the condition is not set until the first hibernation
+		status, _ := waiters.ConditionStatus(clusterDeployment, "Hibernating")
+		current = PowerStateRunning
+		if status == string(metav1.ConditionTrue) {
+			current = PowerStateHibernating
+		}
+	}
+	klog.V(4).Infof("Cluster %s: powerState %s", clusterName, current)
+	if current != powerState {
+		return failures.Classify(failures.ScopeHibernation, "PowerStateNotReached",
+			fmt.Sprintf("clusterDeployment %s is %s instead of %s", clusterName, current, powerState))
+	}
+	return nil
+}
+
+// waitClusterUnknown waits for the managedCluster availability to be unknown,
+// the managedCluster must not be deleted while the cluster is hibernating.
+func waitClusterUnknown(hubClientDynamic dynamic.Interface, clusterName string) {
+	klog.V(1).Infof("Cluster %s: Wait the managedCluster to be unknown...", clusterName)
+	Expect(newWaiter(hubClientDynamic, eventuallyInterval()).WaitFor(gvrManagedCluster, "", clusterName, options.Timeout(options.TimeoutClusterUnknown),
+		func(managedCluster *unstructured.Unstructured) error {
+			if managedCluster == nil {
+				// the managedCluster won't come back, no need to wait for the timeout
+				return waiters.Stop(failures.Classify(failures.ScopeHibernation, "ManagedClusterDetached",
+					fmt.Sprintf("managedCluster %s deleted while hibernating", clusterName)))
+			}
+			status, err := waiters.ConditionStatus(managedCluster, "ManagedClusterConditionAvailable")
+			if err != nil {
+				return err
+			}
+			if status != string(metav1.ConditionUnknown) {
+				return failures.Classify(failures.ScopeHibernation, "ManagedClusterNotUnknown",
+					fmt.Sprintf("managedCluster %s availability is %q", clusterName, status))
+			}
+			return nil
+		})).To(BeNil())
+	klog.V(1).Infof("Cluster %s: managedCluster unknown", clusterName)
+}
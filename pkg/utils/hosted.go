@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	libgodeploymentv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/deployment"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+var gvrKlusterlet = schema.GroupVersionResource{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "klusterlets"}
+
+const (
+	// KlusterletDeployModeAnnotation is set to Hosted on a managedCluster to run its klusterlet on a hosting cluster
+	KlusterletDeployModeAnnotation = "import.open-cluster-management.io/klusterlet-deploy-mode"
+	// HostingClusterNameAnnotation is the name of the managedCluster hosting the klusterlet of a managedCluster in hosted mode
+	HostingClusterNameAnnotation = "import.open-cluster-management.io/hosting-cluster-name"
+)
+
+// HostedKlusterletName returns the name of the klusterlet and of its agent namespace
+// deployed on the hosting cluster for a cluster imported in hosted mode.
+func HostedKlusterletName(clusterName string) string {
+	return "klusterlet-" + clusterName
+}
+
+// ValidateHostedKlusterlet checks the managedCluster is imported in hosted mode: the klusterlet and its agents
+// run on the hosting cluster in the klusterlet-<cluster> namespace. The failures are classified in the scope.
+func ValidateHostedKlusterlet(
+	hubClientDynamic dynamic.Interface,
+	hostingClientDynamic dynamic.Interface,
+	hostingClient kubernetes.Interface,
+	hostingCluster, clusterName, scope string) {
+	By(fmt.Sprintf("Checking the managedCluster %s is imported in hosted mode by %s", clusterName, hostingCluster), func() {
+		managedCluster, err := hubClientDynamic.Resource(gvrManagedCluster).Get(context.TODO(), clusterName, metav1.GetOptions{})
+		Expect(err).To(BeNil())
+		annotations := managedCluster.GetAnnotations()
+		Expect(annotations[KlusterletDeployModeAnnotation]).To(Equal("Hosted"))
+		Expect(annotations[HostingClusterNameAnnotation]).To(Equal(hostingCluster))
+	})
+	klusterletName := HostedKlusterletName(clusterName)
+	By(fmt.Sprintf("Checking the klusterlet %s is in hosted mode", klusterletName), func() {
+		klog.V(1).Infof("Cluster %s: Checking the klusterlet %s on %s", clusterName, klusterletName, hostingCluster)
+		Expect(newWaiter(hostingClientDynamic, eventuallyInterval()).WaitFor(gvrKlusterlet, "", klusterletName, eventuallyTimeout(),
+			func(klusterlet *unstructured.Unstructured) error {
+				if klusterlet == nil {
+					return fmt.Errorf("Cluster %s: klusterlet %s not found", clusterName, klusterletName)
+				}
+				mode, _, _ := unstructured.NestedString(klusterlet.Object, "spec", "deployOption", "mode")
+				if mode != "Hosted" {
+					return failures.Classify(scope, "KlusterletNotHosted",
+						fmt.Sprintf("klusterlet %s is deployed in mode %q", klusterletName, mode))
+				}
+				return nil
+			})).To(BeNil())
+	})
+	By(fmt.Sprintf("Checking the agents are running in the %s namespace", klusterletName), func() {
+		Eventually(func() error {
+			has, missing, err := libgodeploymentv1.HasDeploymentsInNamespace(hostingClient,
+				klusterletName,
+				[]string{
+					klusterletName + "-registration-agent",
+					klusterletName + "-work-agent",
+				})
+			if err != nil {
+				return err
+			}
+			if !has {
+				return fmt.Errorf("Cluster %s: missing agents %#v", clusterName, missing)
+			}
+			return nil
+		}, eventuallyTimeout(), eventuallyInterval()).Should(BeNil())
+		klog.V(1).Infof("Cluster %s: hosted klusterlet running on %s", clusterName, hostingCluster)
+	})
+}
+
+// WaitHostedKlusterletDeleted waits for the klusterlet of a detached cluster in hosted mode
+// and its agent namespace to be deleted from the hosting cluster.
+func WaitHostedKlusterletDeleted(
+	hostingClientDynamic dynamic.Interface,
+	hostingClientDiscovery *discovery.DiscoveryClient,
+	clusterName, scope string) {
+	klusterletName := HostedKlusterletName(clusterName)
+	By(fmt.Sprintf("Checking the klusterlet %s is deleted on the hosting cluster", klusterletName), func() {
+		klog.V(1).Infof("Cluster %s: Checking the klusterlet %s is deleted", clusterName, klusterletName)
+		Expect(newWaiter(hostingClientDynamic, eventuallyInterval()).WaitFor(gvrKlusterlet, "", klusterletName, eventuallyTimeout(),
+			func(klusterlet *unstructured.Unstructured) error {
+				if klusterlet == nil {
+					return nil
+				}
+				return failures.Classify(scope, "klusterlet cleanup failed",
+					fmt.Sprintf("klusterlet %s can not be deleted", klusterletName))
+			})).To(BeNil())
+	})
+	By(fmt.Sprintf("Checking the %s namespace is deleted on the hosting cluster", klusterletName), func() {
+		klog.V(1).Infof("Cluster %s: Checking the %s namespace is deleted", clusterName, klusterletName)
+		WaitNamespaceDeleted(hostingClientDynamic, hostingClientDiscovery, klusterletName, scope, eventuallyTimeout())
+	})
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/appliers"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
+	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
+	libgounstructuredv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/unstructured"
+	libgoclient "github.com/stolostron/library-go/pkg/client"
+	libgoconfig "github.com/stolostron/library-go/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+var (
+	gvrHostedCluster = schema.GroupVersionResource{Group: "hypershift.openshift.io", Version: "v1beta1", Resource: "hostedclusters"}
+	gvrNodePool      = schema.GroupVersionResource{Group: "hypershift.openshift.io", Version: "v1beta1", Resource: "nodepools"}
+)
+
+// the addon running the hypershift operator on the hosting cluster
+const hypershiftAddOnName = "hypershift-addon"
+
+// HostedClusterLifecycle creates a hostedCluster and its nodePool on the hub with the hypershift addon,
+// checks the managedCluster created by the addon is available with a klusterlet in hosted mode on the
+// hosting cluster, accessed with the hostingKubeconfig, then detaches and destroys the hosted cluster
+// and checks nothing is left on the hub and on the hosting cluster.
+func HostedClusterLifecycle(vendor string) {
+	var clusterName, namespace string
+	var hostedCluster options.HostedCluster
+	var hubClients *clients.HubClients
+	var hubAppliers *appliers.HubAppliers
+	var hostingClientDynamic dynamic.Interface
+	var hostingClientDiscovery *discovery.DiscoveryClient
+	var hostingClient kubernetes.Interface
+
+	BeforeEach(func() {
+		hostedCluster = options.TestOptions.Options.HostedCluster
+		if hostedCluster.ReleaseImage == "" {
+			Skip("No hostedCluster.releaseImage in the options")
+		}
+		hubClients = clients.GetHubClients()
+		hubAppliers = appliers.GetHubAppliers(hubClients)
+		// the klusterlet and the control plane run on the hosting cluster
+		var err error
+		hostingClientDynamic, err = libgoclient.NewDefaultKubeClientDynamic(hostedCluster.HostingKubeConfig)
+		Expect(err).To(BeNil())
+		hostingClient, err = libgoclient.NewDefaultKubeClient(hostedCluster.HostingKubeConfig)
+		Expect(err).To(BeNil())
+		hostingRestConfig, err := libgoconfig.LoadConfig("", hostedCluster.HostingKubeConfig, "")
+		Expect(err).To(BeNil())
+		hostingClientDiscovery, err = discovery.NewDiscoveryClientForConfig(hostingRestConfig)
+		Expect(err).To(BeNil())
+		clusterNameObj, err := newPrefixedName("hcp")
+		Expect(err).To(BeNil())
+		clusterName = clusterNameObj.String()
+		namespace = hostedCluster.Namespace
+		reports.SetCluster(clusterName, "kubevirt", "")
+		klog.V(1).Infof(`========================= Start Test hosted cluster %s ===============================`, clusterName)
+	})
+
+	It(fmt.Sprintf("[P1][Sev1][cluster-lifecycle] Create and destroy a hosted cluster on KubeVirt with vendor %s (cluster/g1/hypershift)", vendor), func() {
+		By("Checking the minimal requirements", func() {
+			Eventually(func() bool {
+				klog.V(2).Infof("Cluster %s: Check CRDs", clusterName)
+				has, missing, _ := libgocrdv1.HasCRDs(hubClients.APIExtensionClient,
+					[]string{
+						"managedclusters.cluster.open-cluster-management.io",
+						"hostedclusters.hypershift.openshift.io",
+						"nodepools.hypershift.openshift.io",
+					})
+				if !has {
+					klog.Errorf("Cluster %s: Missing CRDs\n%#v", clusterName, missing)
+				}
+				return has
+			}).Should(BeTrue())
+			klog.V(1).Infof("Cluster %s: Checking the %s is available on %s", clusterName, hypershiftAddOnName, hostedCluster.HostingCluster)
+			gvr := schema.GroupVersionResource{Group: "addon.open-cluster-management.io", Version: "v1alpha1", Resource: "managedclusteraddons"}
+			Expect(newWaiter(hubClients.DynamicClient, eventuallyInterval()).WaitFor(gvr, hostedCluster.HostingCluster, hypershiftAddOnName, eventuallyTimeout(),
+				func(managedClusterAddon *unstructured.Unstructured) error {
+					return validateClusterAddOnAvailable(managedClusterAddon, hostedCluster.HostingCluster, hypershiftAddOnName)
+				})).To(BeNil())
+		})
+
+		By(fmt.Sprintf("Creating the namespace %s of the hostedClusters", namespace), func() {
+			klog.V(1).Infof("Cluster %s: Creating the namespace %s", clusterName, namespace)
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: namespace,
+				},
+			}
+			ownership.Own(ns)
+			_, err := hubClients.KubeClient.CoreV1().Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{})
+			switch {
+			case err == nil:
+				ownership.Record(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, "Namespace", "", namespace)
+			case !errors.IsAlreadyExists(err):
+				Fail(err.Error())
+			}
+		})
+
+		By(fmt.Sprintf("Creating the hostedCluster %s and its nodePool", clusterName), func() {
+			pullSecret := &corev1.Secret{}
+			Expect(hubClients.ClientClient.Get(context.TODO(),
+				types.NamespacedName{
+					Name:      "pull-secret",
+					Namespace: "openshift-config",
+				},
+				pullSecret)).To(BeNil())
+			values := struct {
+				HostedClusterName string
+				Namespace         string
+				ReleaseImage      string
+				BaseDomain        string
+				PullSecret        string
+				SSHPublicKey      string
+				NodePoolReplicas  int
+				Cores             int
+				Memory            string
+			}{
+				HostedClusterName: clusterName,
+				Namespace:         namespace,
+				ReleaseImage:      hostedCluster.ReleaseImage,
+				BaseDomain:        hostedCluster.BaseDomain,
+				PullSecret:        string(pullSecret.Data[".dockerconfigjson"]),
+				SSHPublicKey:      libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
+				NodePoolReplicas:  hostedCluster.NodePoolReplicas,
+				Cores:             hostedCluster.Cores,
+				Memory:            hostedCluster.Memory,
+			}
+			klog.V(1).Infof("Cluster %s: Creating the secrets", clusterName)
+			Expect(hubAppliers.HyperShiftApplier.CreateOrUpdateResource("pull_secret_cr.yaml", values)).To(BeNil())
+			if values.SSHPublicKey != "" {
+				Expect(hubAppliers.HyperShiftApplier.CreateOrUpdateResource("ssh_key_secret_cr.yaml", values)).To(BeNil())
+			}
+			klog.V(1).Infof("Cluster %s: Creating the hostedCluster and the nodePool", clusterName)
+			Expect(hubAppliers.HyperShiftApplier.CreateOrUpdateResources(
+				[]string{
+					"hosted_cluster_cr.yaml",
+					"node_pool_cr.yaml",
+				}, values)).To(BeNil())
+		})
+
+		When(fmt.Sprintf("HostedCluster %s created, wait for the control plane to be available", clusterName), func() {
+			reports.TimeStep(reports.StepHostedCluster, func() {
+				waitHostedClusterAvailable(hubClients.DynamicClient, namespace, clusterName)
+			})
+		})
+
+		When(fmt.Sprintf("NodePool %s created, wait for the nodes to be ready", clusterName), func() {
+			reports.TimeStep(reports.StepNodePool, func() {
+				waitNodePoolReady(hubClients.DynamicClient, namespace, clusterName, hostedCluster.NodePoolReplicas)
+			})
+		})
+
+		When(fmt.Sprintf("HostedCluster %s available, wait for the managedCluster created by the %s to be available", clusterName, hypershiftAddOnName), func() {
+			// the managedCluster is created by the addon, it is recorded to be detached on failure
+			ownership.Record(gvrManagedCluster, "ManagedCluster", "", clusterName)
+			WaitClusterImported(hubClients.DynamicClient, clusterName)
+		})
+
+		When(fmt.Sprintf("Cluster %s imported, check the klusterlet runs in hosted mode on %s", clusterName, hostedCluster.HostingCluster), func() {
+			ValidateHostedKlusterlet(hubClients.DynamicClient, hostingClientDynamic, hostingClient,
+				hostedCluster.HostingCluster, clusterName, failures.ScopeHyperShift)
+		})
+
+		By(fmt.Sprintf("Detaching the %s managedCluster on the hub", clusterName), func() {
+			klog.V(1).Infof("Cluster %s: Detaching the managedCluster", clusterName)
+			Expect(hubClients.DynamicClient.Resource(gvrManagedCluster).Delete(context.TODO(), clusterName, metav1.DeleteOptions{})).To(BeNil())
+			reports.TimeStep(reports.StepDetach, func() {
+				Expect(newWaiter(hubClients.DynamicClient, eventuallyInterval()).WaitFor(gvrManagedCluster, "", clusterName, eventuallyTimeout(),
+					func(managedCluster *unstructured.Unstructured) error {
+						if managedCluster == nil {
+							return nil
+						}
+						return failures.Classify(failures.ScopeHyperShift, "ManagedClusterNotDeleted",
+							fmt.Sprintf("managedCluster %s can not be deleted", clusterName))
+					})).To(BeNil())
+			})
+		})
+
+		When(fmt.Sprintf("Cluster %s detached, check the hosted klusterlet is cleaned on %s", clusterName, hostedCluster.HostingCluster), func() {
+			WaitHostedKlusterletDeleted(hostingClientDynamic, hostingClientDiscovery, clusterName, failures.ScopeHyperShift)
+		})
+
+		By(fmt.Sprintf("Deleting the hostedCluster %s and its nodePool", clusterName), func() {
+			klog.V(1).Infof("Cluster %s: Deleting the nodePool and the hostedCluster", clusterName)
+			for _, gvr := range []schema.GroupVersionResource{gvrNodePool, gvrHostedCluster} {
+				err := hubClients.DynamicClient.Resource(gvr).Namespace(namespace).Delete(context.TODO(), clusterName, metav1.DeleteOptions{})
+				if err != nil && !errors.IsNotFound(err) {
+					Fail(err.Error())
+				}
+			}
+			reports.TimeStep(reports.StepHostedClusterDelete, func() {
+				Expect(newWaiter(hubClients.DynamicClient, longInterval()).WaitFor(gvrHostedCluster, namespace, clusterName, options.Timeout(options.TimeoutHostedClusterDeletion),
+					func(hc *unstructured.Unstructured) error {
+						if hc == nil {
+							return nil
+						}
+						return failures.Classify(failures.ScopeHyperShift, "HostedClusterNotDeleted",
+							fmt.Sprintf("hostedCluster %s/%s can not be deleted", namespace, clusterName))
+					})).To(BeNil())
+			})
+		})
+
+		When(fmt.Sprintf("HostedCluster %s deleted, wait for the namespaces deletion", clusterName), func() {
+			controlPlaneNamespace := fmt.Sprintf("%s-%s", namespace, clusterName)
+			By(fmt.Sprintf("Checking the deletion of the %s control plane namespace on %s", controlPlaneNamespace, hostedCluster.HostingCluster), func() {
+				klog.V(1).Infof("Cluster %s: Checking the deletion of the %s namespace on %s", clusterName, controlPlaneNamespace, hostedCluster.HostingCluster)
+				WaitNamespaceDeleted(hostingClientDynamic, hostingClientDiscovery, controlPlaneNamespace, failures.ScopeHyperShift, options.Timeout(options.TimeoutHostedClusterDeletion))
+			})
+			By(fmt.Sprintf("Checking the deletion of the %s namespace on the hub", clusterName), func() {
+				klog.V(1).Infof("Cluster %s: Checking the deletion of the %s namespace on the hub", clusterName, clusterName)
+				reports.TimeStep(reports.StepNamespaceDeletion, func() {
+					WaitNamespaceDeleted(hubClients.DynamicClient, hubClients.DiscoveryClient, clusterName, failures.ScopeHyperShift, eventuallyTimeout())
+				})
+			})
+		})
+
+		By(fmt.Sprintf("Deleting the secrets of the hostedCluster %s", clusterName), func() {
+			for _, name := range []string{clusterName + "-pull-secret", clusterName + "-ssh-key"} {
+				err := hubClients.KubeClient.CoreV1().Secrets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+				if err != nil && !errors.IsNotFound(err) {
+					Fail(err.Error())
+				}
+			}
+		})
+
+		klog.V(1).Infof("========================= End Test hosted cluster %s ===============================", clusterName)
+	})
+}
+
+// waitHostedClusterAvailable waits for the Available condition of the hostedCluster to be true.
+func waitHostedClusterAvailable(hubClientDynamic dynamic.Interface, namespace, clusterName string) {
+	klog.V(1).Infof("Cluster %s: Wait the hostedCluster to be available...", clusterName)
+	Expect(newWaiter(hubClientDynamic, longInterval()).WaitFor(gvrHostedCluster, namespace, clusterName, options.Timeout(options.TimeoutHostedCluster),
+		func(hc *unstructured.Unstructured) error {
+			if hc == nil {
+				return fmt.Errorf("Cluster %s: hostedCluster not found", clusterName)
+			}
+			condition, err := libgounstructuredv1.GetConditionByType(hc, "Available")
+			if err != nil {
+				return err
+			}
+			if v, ok := condition["status"]; ok && v == string(metav1.ConditionTrue) {
+				return nil
+			}
+			return failures.Classify(failures.ScopeHyperShift, "HostedClusterNotAvailable",
+				fmt.Sprintf("hostedCluster %s not available: %v: %v", clusterName, condition["reason"], condition["message"]))
+		})).To(BeNil())
+	klog.V(1).Infof("Cluster %s: hostedCluster available", clusterName)
+}
+
+// waitNodePoolReady waits for the nodePool to report the expected number of replicas.
+func waitNodePoolReady(hubClientDynamic dynamic.Interface, namespace, clusterName string, replicas int) {
+	klog.V(1).Infof("Cluster %s: Wait the %d nodes of the nodePool to be ready...", clusterName, replicas)
+	Expect(newWaiter(hubClientDynamic, longInterval()).WaitFor(gvrNodePool, namespace, clusterName, options.Timeout(options.TimeoutNodePool),
+		func(nodePool *unstructured.Unstructured) error {
+			if nodePool == nil {
+				return fmt.Errorf("Cluster %s: nodePool not found", clusterName)
+			}
+			ready, _, _ := unstructured.NestedInt64(nodePool.Object, "status", "replicas")
+			if ready < int64(replicas) {
+				return failures.Classify(failures.ScopeHyperShift, "NodePoolNotReady",
+					fmt.Sprintf("nodePool %s has %d/%d ready nodes", clusterName, ready, replicas))
+			}
+			return nil
+		})).To(BeNil())
+	klog.V(1).Infof("Cluster %s: nodePool ready", clusterName)
+}
@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/appliers"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/diagnostics"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
+	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+)
+
+var (
+	gvrManifestWork        = schema.GroupVersionResource{Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks"}
+	gvrManagedClusterClaim = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1alpha1", Resource: "clusterclaims"}
+)
+
+// manifestWorkValues are the values of the manifestWork template,
+// the generation is set in the configMap and the clusterClaim to check the updates are propagated.
+type manifestWorkValues struct {
+	ManifestWorkName string
+	ClusterName      string
+	Namespace        string
+	Generation       int
+	Replicas         int
+}
+
+// clusterClaimName returns the name of the clusterClaim deployed by the manifestWork
+func (v manifestWorkValues) clusterClaimName() string {
+	return v.ManifestWorkName + ".cluster-lifecycle-e2e.open-cluster-management.io"
+}
+
+// ManifestWorkDelivery creates on the hub a manifestWork deploying a namespace, a configMap, a deployment and
+// a clusterClaim on the managed cluster, checks it is applied and available, the status feedback of the deployment,
+// the propagation of an update and of its deletion which orphans the namespace and the configMap.
+// Each step is checked on the managed cluster through its kubeconfig.
+func ManifestWorkDelivery(clusterName string) {
+	var hubClients *clients.HubClients
+	var hubAppliers *appliers.HubAppliers
+	var name string
+
+	BeforeEach(func() {
+		hubClients = clients.GetHubClients()
+		hubAppliers = appliers.GetHubAppliers(hubClients)
+		reports.SetCluster(clusterName, "", "")
+		clusterNameObj, err := newPrefixedName("work")
+		Expect(err).To(BeNil())
+		name = clusterNameObj.String()
+		klog.V(1).Infof(`========================= Start Test manifestWork %s on cluster %s ===============================`, name, clusterName)
+	})
+
+	It(fmt.Sprintf("[P1][Sev1][cluster-lifecycle] Deliver a manifestWork to the cluster %s (cluster/g1/manifestwork)", clusterName), func() {
+		By("Checking the minimal requirements", func() {
+			Eventually(func() bool {
+				klog.V(2).Infof("Cluster %s: Check CRDs", clusterName)
+				has, missing, _ := libgocrdv1.HasCRDs(hubClients.APIExtensionClient,
+					[]string{
+						"managedclusters.cluster.open-cluster-management.io",
+						"manifestworks.work.open-cluster-management.io",
+					})
+				if !has {
+					klog.Errorf("Cluster %s: Missing CRDs\n%#v", clusterName, missing)
+				}
+				return has
+			}).Should(BeTrue())
+		})
+
+		WaitClusterImported(hubClients.DynamicClient, clusterName)
+
+		managed, err := managedClusterClients(hubClients, clusterName)
+		if err != nil {
+			Fail(failures.Classify(failures.ScopeManifestWork, "ManagedClusterUnreachable",
+				fmt.Sprintf("the managed cluster %s can not be checked: %v", clusterName, err)).Error())
+		}
+
+		values := manifestWorkValues{
+			ManifestWorkName: name,
+			ClusterName:      clusterName,
+			Namespace:        name,
+			Generation:       1,
+			Replicas:         1,
+		}
+		defer func() {
+			// the namespace is orphaned by the deletion of the manifestWork
+			klog.V(1).Infof("Cluster %s: Deleting the namespace %s of the managed cluster", clusterName, values.Namespace)
+			err := managed.KubeClient.CoreV1().Namespaces().Delete(context.TODO(), values.Namespace, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				klog.Errorf("Cluster %s: the namespace %s can not be deleted: %v", clusterName, values.Namespace, err)
+			}
+		}()
+
+		By(fmt.Sprintf("Creating the manifestWork %s", name), func() {
+			klog.V(1).Infof("Cluster %s: Creating the manifestWork %s", clusterName, name)
+			Expect(hubAppliers.ManifestWorkApplier.CreateOrUpdateResource("manifest_work_cr.yaml", values)).To(BeNil())
+		})
+
+		When("the manifestWork is created, wait for it to be applied and available", func() {
+			reports.TimeStep(reports.StepManifestWorks, func() {
+				waitManifestWorkAvailable(hubClients, clusterName, name)
+				waitManifestsDelivered(managed, values)
+			})
+		})
+
+		When("the manifestWork is available, wait for the status feedback of the deployment", func() {
+			reports.TimeStep(reports.StepManifestWorkFeedback, func() {
+				waitDeploymentFeedback(hubClients, managed, values)
+			})
+		})
+
+		By(fmt.Sprintf("Updating the manifestWork %s", name), func() {
+			values.Generation = 2
+			values.Replicas = 2
+			klog.V(1).Infof("Cluster %s: Updating the manifestWork %s to the generation %d", clusterName, name, values.Generation)
+			Expect(hubAppliers.ManifestWorkApplier.CreateOrUpdateResource("manifest_work_cr.yaml", values)).To(BeNil())
+		})
+
+		When("the manifestWork is updated, wait for the update to be applied", func() {
+			reports.TimeStep(reports.StepManifestWorkUpdate, func() {
+				waitManifestsDelivered(managed, values)
+				waitDeploymentFeedback(hubClients, managed, values)
+			})
+		})
+
+		By(fmt.Sprintf("Deleting the manifestWork %s", name), func() {
+			klog.V(1).Infof("Cluster %s: Deleting the manifestWork %s", clusterName, name)
+			err := hubClients.DynamicClient.Resource(gvrManifestWork).Namespace(clusterName).Delete(context.TODO(), name, metav1.DeleteOptions{})
+			Expect(err).To(BeNil())
+		})
+
+		When("the manifestWork is deleted, wait for the manifests to be deleted or orphaned", func() {
+			reports.TimeStep(reports.StepManifestWorkDeletion, func() {
+				Expect(newWaiter(hubClients.DynamicClient, eventuallyInterval()).WaitFor(gvrManifestWork, clusterName, name,
+					eventuallyTimeout(),
+					func(mw *unstructured.Unstructured) error {
+						if mw == nil {
+							return nil
+						}
+						return failures.Classify(failures.ScopeManifestWork, "ManifestWorkNotDeleted",
+							fmt.Sprintf("manifestWork %s/%s is not deleted, finalizers %v", clusterName, name, mw.GetFinalizers()))
+					})).To(BeNil())
+				waitManifestsOrphaned(managed, values)
+			})
+		})
+	})
+}
+
+// waitManifestWorkAvailable waits for the manifestWork to be applied and available on the managed cluster.
+func waitManifestWorkAvailable(hubClients *clients.HubClients, clusterName, name string) {
+	klog.V(1).Infof("Cluster %s: Wait manifestWork %s to be applied and available...", clusterName, name)
+	Expect(newWaiter(hubClients.DynamicClient, eventuallyInterval()).WaitFor(gvrManifestWork, clusterName, name,
+		eventuallyTimeout(),
+		func(mw *unstructured.Unstructured) error {
+			for _, conditionType := range []string{"Applied", "Available"} {
+				if err := waiters.ConditionTrue(conditionType)(mw); err != nil {
+					return failures.Classify(failures.ScopeManifestWork, "ManifestWorkNotApplied", err.Error())
+				}
+			}
+			return nil
+		})).To(BeNil())
+	klog.V(1).Infof("Cluster %s: manifestWork %s applied and available", clusterName, name)
+}
+
+// waitManifestsDelivered waits for the manifests of the generation of the manifestWork to be deployed on the managed cluster.
+func waitManifestsDelivered(managed *diagnostics.Clients, values manifestWorkValues) {
+	clusterName := values.ClusterName
+	generation := strconv.Itoa(values.Generation)
+	klog.V(1).Infof("Cluster %s: Wait the manifests of the generation %s to be deployed...", clusterName, generation)
+	Eventually(func() error {
+		cm, err := managed.KubeClient.CoreV1().ConfigMaps(values.Namespace).Get(context.TODO(), values.ManifestWorkName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if cm.Data["generation"] != generation {
+			return failures.Classify(failures.ScopeManifestWork, "ManifestNotUpdated",
+				fmt.Sprintf("configMap %s/%s has the generation %q instead of %q", values.Namespace, cm.Name, cm.Data["generation"], generation))
+		}
+		deployment, err := managed.KubeClient.AppsV1().Deployments(values.Namespace).Get(context.TODO(), values.ManifestWorkName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if deployment.Spec.Replicas == nil || int(*deployment.Spec.Replicas) != values.Replicas {
+			return failures.Classify(failures.ScopeManifestWork, "ManifestNotUpdated",
+				fmt.Sprintf("deployment %s/%s doesn't have %d replicas", values.Namespace, deployment.Name, values.Replicas))
+		}
+		claim, err := managed.DynamicClient.Resource(gvrManagedClusterClaim).Get(context.TODO(), values.clusterClaimName(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if value, _, _ := unstructured.NestedString(claim.Object, "spec", "value"); value != generation {
+			return failures.Classify(failures.ScopeManifestWork, "ManifestNotUpdated",
+				fmt.Sprintf("clusterClaim %s has the value %q instead of %q", claim.GetName(), value, generation))
+		}
+		return nil
+	}, eventuallyTimeout(), eventuallyInterval()).Should(BeNil())
+	klog.V(1).Infof("Cluster %s: manifests of the generation %s deployed", clusterName, generation)
+}
+
+// waitDeploymentFeedback waits for the status feedback of the deployment in the manifestWork to report
+// the ready replicas of the deployment on the managed cluster.
+func waitDeploymentFeedback(hubClients *clients.HubClients, managed *diagnostics.Clients, values manifestWorkValues) {
+	clusterName := values.ClusterName
+	klog.V(1).Infof("Cluster %s: Wait the status feedback of %d ready replicas...", clusterName, values.Replicas)
+	Expect(newWaiter(hubClients.DynamicClient, eventuallyInterval()).WaitFor(gvrManifestWork, clusterName, values.ManifestWorkName,
+		eventuallyTimeout(),
+		func(mw *unstructured.Unstructured) error {
+			if mw == nil {
+				return fmt.Errorf("Cluster %s: manifestWork %s not found", clusterName, values.ManifestWorkName)
+			}
+			feedback := statusFeedback(mw, "apps", "Deployment", values.Namespace, values.ManifestWorkName)
+			deployment, err := managed.KubeClient.AppsV1().Deployments(values.Namespace).Get(context.TODO(), values.ManifestWorkName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			ready, ok := feedback["ReadyReplicas"]
+			if !ok || ready != int64(values.Replicas) || ready != int64(deployment.Status.ReadyReplicas) {
+				return failures.Classify(failures.ScopeManifestWork, "StatusFeedbackNotSynced",
+					fmt.Sprintf("deployment %s/%s: the status feedback %v doesn't report the %d ready replicas expected, %d ready on the managed cluster",
+						values.Namespace, values.ManifestWorkName, feedback, values.Replicas, deployment.Status.ReadyReplicas))
+			}
+			if feedback["observedGeneration"] != deployment.Status.ObservedGeneration {
+				return failures.Classify(failures.ScopeManifestWork, "StatusFeedbackNotSynced",
+					fmt.Sprintf("deployment %s/%s: the status feedback %v doesn't report the observed generation %d of the managed cluster",
+						values.Namespace, values.ManifestWorkName, feedback, deployment.Status.ObservedGeneration))
+			}
+			return nil
+		})).To(BeNil())
+	klog.V(1).Infof("Cluster %s: status feedback of %d ready replicas received", clusterName, values.Replicas)
+}
+
+// statusFeedback returns the integer values of the status feedback of a manifest of the manifestWork.
+func statusFeedback(mw *unstructured.Unstructured, group, kind, namespace, name string) map[string]int64 {
+	feedback := map[string]int64{}
+	manifests, _, _ := unstructured.NestedSlice(mw.Object, "status", "resourceStatus", "manifests")
+	for _, m := range manifests {
+		manifest, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		meta, _, _ := unstructured.NestedStringMap(manifest, "resourceMeta")
+		if meta["group"] != group || meta["kind"] != kind || meta["namespace"] != namespace || meta["name"] != name {
+			continue
+		}
+		feedbackValues, _, _ := unstructured.NestedSlice(manifest, "statusFeedback", "values")
+		for _, v := range feedbackValues {
+			value, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			valueName, _, _ := unstructured.NestedString(value, "name")
+			if i, found, _ := unstructured.NestedInt64(value, "fieldValue", "integer"); found {
+				feedback[valueName] = i
+			}
+		}
+	}
+	return feedback
+}
+
+// waitManifestsOrphaned waits for the deployment and the clusterClaim to be deleted from the managed cluster
+// and checks the namespace and the configMap orphaned by the deleteOption are kept without owner.
+func waitManifestsOrphaned(managed *diagnostics.Clients, values manifestWorkValues) {
+	clusterName := values.ClusterName
+	klog.V(1).Infof("Cluster %s: Wait the manifests of the manifestWork %s to be deleted or orphaned...", clusterName, values.ManifestWorkName)
+	Eventually(func() error {
+		_, err := managed.KubeClient.AppsV1().Deployments(values.Namespace).Get(context.TODO(), values.ManifestWorkName, metav1.GetOptions{})
+		if !errors.IsNotFound(err) {
+			return failures.Classify(failures.ScopeManifestWork, "ManifestNotDeleted",
+				fmt.Sprintf("deployment %s/%s is not deleted: %v", values.Namespace, values.ManifestWorkName, err))
+		}
+		_, err = managed.DynamicClient.Resource(gvrManagedClusterClaim).Get(context.TODO(), values.clusterClaimName(), metav1.GetOptions{})
+		if !errors.IsNotFound(err) {
+			return failures.Classify(failures.ScopeManifestWork, "ManifestNotDeleted",
+				fmt.Sprintf("clusterClaim %s is not deleted: %v", values.clusterClaimName(), err))
+		}
+		ns, err := managed.KubeClient.CoreV1().Namespaces().Get(context.TODO(), values.Namespace, metav1.GetOptions{})
+		if err != nil {
+			return failures.Classify(failures.ScopeManifestWork, "ManifestNotOrphaned",
+				fmt.Sprintf("namespace %s is not orphaned: %v", values.Namespace, err))
+		}
+		cm, err := managed.KubeClient.CoreV1().ConfigMaps(values.Namespace).Get(context.TODO(), values.ManifestWorkName, metav1.GetOptions{})
+		if err != nil {
+			return failures.Classify(failures.ScopeManifestWork, "ManifestNotOrphaned",
+				fmt.Sprintf("configMap %s/%s is not orphaned: %v", values.Namespace, values.ManifestWorkName, err))
+		}
+		// the work agent removes the owner reference to the appliedManifestWork of the orphaned manifests
+		for _, obj := range []metav1.Object{ns, cm} {
+			for _, owner := range obj.GetOwnerReferences() {
+				if owner.Kind == "AppliedManifestWork" {
+					return failures.Classify(failures.ScopeManifestWork, "ManifestNotOrphaned",
+						fmt.Sprintf("%s is still owned by the appliedManifestWork %s", obj.GetName(), owner.Name))
+				}
+			}
+		}
+		return nil
+	}, eventuallyTimeout(), eventuallyInterval()).Should(BeNil())
+	klog.V(1).Infof("Cluster %s: manifests of the manifestWork %s deleted or orphaned", clusterName, values.ManifestWorkName)
+}
@@ -0,0 +1,354 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/appliers"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/scale"
+	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
+	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+var gvrPlacementDecision = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "placementdecisions"}
+
+const (
+	// the label adding a managedCluster to a managedClusterSet
+	clusterSetLabel = "cluster.open-cluster-management.io/clusterset"
+	// the label set by the placement controller on its placementDecisions
+	placementLabel = "cluster.open-cluster-management.io/placement"
+	// the label and the cluster claim of the simulated clusters selected by the placements
+	placementGroupLabel = "cluster-lifecycle-e2e.open-cluster-management.io/placement-group"
+	zoneClaim           = "zone.cluster-lifecycle-e2e.open-cluster-management.io"
+	// the lease duration of the simulated clusters
+	placementLeaseDurationSeconds = 60
+)
+
+// placementCluster is a simulated cluster of the placement tests
+type placementCluster struct {
+	group  string
+	zone   string
+	memory string
+}
+
+// the simulated clusters registered in the managedClusterSet at the start, the last one joins the set later
+var placementClusters = []placementCluster{
+	{group: "a", zone: "east", memory: "16Gi"},
+	{group: "a", zone: "west", memory: "64Gi"},
+	{group: "b", zone: "east", memory: "32Gi"},
+	{group: "a", zone: "east", memory: "8Gi"},
+}
+
+// placementSpec is a placement created by the tests
+type placementSpec struct {
+	PlacementName    string
+	Namespace        string
+	ClusterSetName   string
+	NumberOfClusters int
+	LabelSelector    map[string]string
+	ClaimSelector    map[string]string
+	Prioritizer      string
+}
+
+// PlacementScheduling adds the available managedClusters created or imported by the tests and simulated clusters
+// to a new managedClusterSet bound to a namespace, creates placements with label and claim predicates and a prioritizer,
+// and checks their placementDecisions, then when a cluster joins the set, leaves it or becomes unavailable.
+// The clusters created or imported by the tests are put back in their managedClusterSet at the end.
+func PlacementScheduling() {
+	var hubClients *clients.HubClients
+	var hubAppliers *appliers.HubAppliers
+	var prefix string
+
+	BeforeEach(func() {
+		hubClients = clients.GetHubClients()
+		hubAppliers = appliers.GetHubAppliers(hubClients)
+		clusterNameObj, err := newPrefixedName("placement")
+		Expect(err).To(BeNil())
+		prefix = clusterNameObj.String()
+		klog.V(1).Infof(`========================= Start Test placement %s ===============================`, prefix)
+	})
+
+	It("[P2][Sev2][cluster-lifecycle] Schedule placements on a managedClusterSet (cluster/g1/placement)", func() {
+		By("Checking the minimal requirements", func() {
+			Eventually(func() bool {
+				klog.V(2).Infof("Placement %s: Check CRDs", prefix)
+				has, missing, _ := libgocrdv1.HasCRDs(hubClients.APIExtensionClient,
+					[]string{
+						"managedclusters.cluster.open-cluster-management.io",
+						"managedclustersets.cluster.open-cluster-management.io",
+						"managedclustersetbindings.cluster.open-cluster-management.io",
+						"placements.cluster.open-cluster-management.io",
+						"placementdecisions.cluster.open-cluster-management.io",
+					})
+				if !has {
+					klog.Errorf("Placement %s: Missing CRDs\n%#v", prefix, missing)
+				}
+				return has
+			}).Should(BeTrue())
+		})
+
+		clusterSetName := prefix
+		namespace := prefix
+		ctx, cancel := context.WithCancel(context.TODO())
+		// the clusters of the tests are labeled back to their managedClusterSet
+		previousClusterSets := map[string]string{}
+		defer func() {
+			klog.V(1).Infof("Placement %s: Removing the clusters from the managedClusterSet %s", prefix, clusterSetName)
+			for clusterName, previous := range previousClusterSets {
+				if err := setClusterSet(hubClients.DynamicClient, clusterName, previous); err != nil {
+					klog.Errorf("Cluster %s: %v", clusterName, err)
+				}
+			}
+			// the agents run until the simulated clusters are deleted
+			reports.TimeStep(reports.StepTeardown, Teardown)
+			cancel()
+		}()
+
+		By(fmt.Sprintf("Creating the managedClusterSet %s bound to the namespace %s", clusterSetName, namespace), func() {
+			klog.V(1).Infof("Placement %s: Creating the managedClusterSet %s bound to the namespace %s", prefix, clusterSetName, namespace)
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: namespace,
+				},
+			}
+			ownership.Own(ns)
+			_, err := hubClients.KubeClient.CoreV1().Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{})
+			Expect(err).To(BeNil())
+			ownership.Record(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, "Namespace", "", namespace)
+			values := struct {
+				ClusterSetName string
+				Namespace      string
+			}{
+				ClusterSetName: clusterSetName,
+				Namespace:      namespace,
+			}
+			Expect(hubAppliers.PlacementApplier.CreateOrUpdateResources([]string{
+				"managed_cluster_set_cr.yaml",
+				"managed_cluster_set_binding_cr.yaml",
+			}, values)).To(BeNil())
+		})
+
+		var testClusters []string
+		By("Adding the available clusters created or imported by the tests to the managedClusterSet", func() {
+			var err error
+			testClusters, err = availableTestClusters(hubClients.DynamicClient)
+			Expect(err).To(BeNil())
+			for _, clusterName := range testClusters {
+				mc, err := hubClients.DynamicClient.Resource(gvrManagedCluster).Get(context.TODO(), clusterName, metav1.GetOptions{})
+				Expect(err).To(BeNil())
+				previousClusterSets[clusterName] = mc.GetLabels()[clusterSetLabel]
+				klog.V(1).Infof("Cluster %s: Adding to the managedClusterSet %s", clusterName, clusterSetName)
+				Expect(setClusterSet(hubClients.DynamicClient, clusterName, clusterSetName)).To(BeNil())
+			}
+		})
+
+		go scale.ApproveCSRs(ctx, hubClients.KubeClient)
+		clusterTracker := scale.NewTracker(hubClients.DynamicClient, gvrManagedCluster, "ManagedClusterConditionAvailable")
+		go clusterTracker.Run(ctx)
+		simulated := make([]string, len(placementClusters))
+		agents := make([]*scale.Agent, len(placementClusters))
+		for i, c := range placementClusters {
+			simulated[i] = fmt.Sprintf("%s-%d", prefix, i)
+			agents[i] = scale.NewAgent(hubClients.KubeClient, hubClients.DynamicClient, simulated[i],
+				placementLeaseDurationSeconds*time.Second, eventuallyTimeout())
+			agents[i].ClusterClaims = map[string]string{zoneClaim: c.zone}
+			agents[i].Resources = map[string]string{"cpu": "16", "memory": c.memory}
+		}
+		register := func(i int) {
+			klog.V(1).Infof("Cluster %s: Registering the simulated cluster in the managedClusterSet %s", simulated[i], clusterSetName)
+			Expect(createSimulatedCluster(hubClients.KubeClient, hubClients.DynamicClient, clusterTracker, simulated[i], placementLeaseDurationSeconds,
+				map[string]string{
+					clusterSetLabel:     clusterSetName,
+					placementGroupLabel: placementClusters[i].group,
+				})).To(BeNil())
+			go func() {
+				if err := agents[i].Run(ctx); err != nil && ctx.Err() == nil {
+					klog.Errorf("Placement %s: %v", prefix, err)
+				}
+			}()
+		}
+
+		By("Registering the simulated clusters in the managedClusterSet", func() {
+			for i := 0; i < len(placementClusters)-1; i++ {
+				register(i)
+			}
+			Expect(clusterTracker.Wait(eventuallyTimeout())).To(BeNil())
+		})
+
+		placements := []placementSpec{
+			{PlacementName: prefix + "-all"},
+			{PlacementName: prefix + "-label", LabelSelector: map[string]string{placementGroupLabel: "a"}},
+			{PlacementName: prefix + "-claim", ClaimSelector: map[string]string{zoneClaim: "east"}},
+			{PlacementName: prefix + "-prioritizer", LabelSelector: map[string]string{simulatedClusterLabel: "true"},
+				NumberOfClusters: 1, Prioritizer: "ResourceAllocatableMemory"},
+		}
+		check := func(expected ...[]string) {
+			for i, p := range placements {
+				waitPlacementDecisions(hubClients.DynamicClient, namespace, p.PlacementName, expected[i])
+			}
+		}
+
+		By("Creating the placements", func() {
+			for i := range placements {
+				placements[i].Namespace = namespace
+				placements[i].ClusterSetName = clusterSetName
+				klog.V(1).Infof("Placement %s: Creating the placement %s/%s", prefix, namespace, placements[i].PlacementName)
+				Expect(hubAppliers.PlacementApplier.CreateOrUpdateResource("placement_cr.yaml", placements[i])).To(BeNil())
+			}
+		})
+
+		When("the placements are created, check their decisions", func() {
+			check(
+				append([]string{simulated[0], simulated[1], simulated[2]}, testClusters...),
+				[]string{simulated[0], simulated[1]},
+				[]string{simulated[0], simulated[2]},
+				[]string{simulated[1]},
+			)
+		})
+
+		When(fmt.Sprintf("the cluster %s joins the managedClusterSet, check the decisions are updated", simulated[3]), func() {
+			register(3)
+			Expect(clusterTracker.Wait(eventuallyTimeout())).To(BeNil())
+			check(
+				append([]string{simulated[0], simulated[1], simulated[2], simulated[3]}, testClusters...),
+				[]string{simulated[0], simulated[1], simulated[3]},
+				[]string{simulated[0], simulated[2], simulated[3]},
+				[]string{simulated[1]},
+			)
+		})
+
+		When(fmt.Sprintf("the cluster %s leaves the managedClusterSet, check the decisions are updated", simulated[2]), func() {
+			klog.V(1).Infof("Cluster %s: Removing from the managedClusterSet %s", simulated[2], clusterSetName)
+			Expect(setClusterSet(hubClients.DynamicClient, simulated[2], "")).To(BeNil())
+			check(
+				append([]string{simulated[0], simulated[1], simulated[3]}, testClusters...),
+				[]string{simulated[0], simulated[1], simulated[3]},
+				[]string{simulated[0], simulated[3]},
+				[]string{simulated[1]},
+			)
+		})
+
+		When(fmt.Sprintf("the cluster %s becomes unavailable, check the decisions are updated", simulated[1]), func() {
+			Expect(agents[1].SetAvailable(ctx, false)).To(BeNil())
+			check(
+				append([]string{simulated[0], simulated[3]}, testClusters...),
+				[]string{simulated[0], simulated[3]},
+				[]string{simulated[0], simulated[3]},
+				[]string{simulated[0]},
+			)
+		})
+	})
+}
+
+// availableTestClusters returns the available managedClusters imported from the clusters of the options
+// or labeled with a run ID, the simulated clusters excepted.
+func availableTestClusters(hubClientDynamic dynamic.Interface) ([]string, error) {
+	imported := map[string]bool{}
+	for _, mc := range libgooptions.TestOptions.Options.ManagedClusters {
+		imported[mc.Name] = true
+	}
+	mcs, err := hubClientDynamic.Resource(gvrManagedCluster).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var l []string
+	for i := range mcs.Items {
+		mc := &mcs.Items[i]
+		labels := mc.GetLabels()
+		_, owned := labels[ownership.RunIDLabel]
+		if _, simulated := labels[simulatedClusterLabel]; simulated || (!owned && !imported[mc.GetName()]) {
+			continue
+		}
+		if checkClusterImported(mc, mc.GetName()) != nil {
+			klog.V(1).Infof("Cluster %s: not available, not added to the managedClusterSet", mc.GetName())
+			continue
+		}
+		l = append(l, mc.GetName())
+	}
+	return l, nil
+}
+
+// setClusterSet moves the managedCluster to the managedClusterSet, out of any managedClusterSet if empty.
+func setClusterSet(hubClientDynamic dynamic.Interface, clusterName, clusterSetName string) error {
+	var value interface{}
+	if clusterSetName != "" {
+		value = clusterSetName
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				clusterSetLabel: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = hubClientDynamic.Resource(gvrManagedCluster).Patch(context.TODO(), clusterName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("the managedClusterSet can not be set to %q: %v", clusterSetName, err)
+	}
+	return nil
+}
+
+// waitPlacementDecisions waits for the placementDecisions of the placement to select the expected clusters.
+func waitPlacementDecisions(hubClientDynamic dynamic.Interface, namespace, placementName string, expected []string) {
+	expected = append([]string{}, expected...)
+	sort.Strings(expected)
+	klog.V(1).Infof("Placement %s/%s: Wait the decisions %v...", namespace, placementName, expected)
+	reports.TimeStep(reports.StepPlacementDecisions, func() {
+		Eventually(func() error {
+			decided, err := placementDecisions(hubClientDynamic, namespace, placementName)
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(decided, expected) {
+				return failures.Classify(failures.ScopePlacement, "PlacementDecisionsNotUpdated",
+					fmt.Sprintf("placement %s/%s: the clusters %v are decided instead of %v", namespace, placementName, decided, expected))
+			}
+			return nil
+		}, eventuallyTimeout(), eventuallyInterval()).Should(BeNil())
+	})
+	klog.V(1).Infof("Placement %s/%s: decisions %v", namespace, placementName, expected)
+}
+
+// placementDecisions returns the sorted names of the clusters decided by the placement.
+func placementDecisions(hubClientDynamic dynamic.Interface, namespace, placementName string) ([]string, error) {
+	decisions, err := hubClientDynamic.Resource(gvrPlacementDecision).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: placementLabel + "=" + placementName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	l := []string{}
+	for _, decision := range decisions.Items {
+		items, _, _ := unstructured.NestedSlice(decision.Object, "status", "decisions")
+		for _, item := range items {
+			if d, ok := item.(map[string]interface{}); ok {
+				if clusterName, ok := d["clusterName"].(string); ok {
+					l = append(l, clusterName)
+				}
+			}
+		}
+	}
+	sort.Strings(l)
+	return l, nil
+}
@@ -0,0 +1,482 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	libgooptions "github.com/stolostron/library-e2e-go/pkg/options"
+)
+
+// Provider is a cloud provider on which hive deploys the clusters.
+// The templates are assets of the create scenario.
+type Provider struct {
+	// Name is the cloud provider in -cloud-providers (ie: aws)
+	Name string
+	// Region returns the region of the clusters, nil if the cloud provider has no region
+	Region func() (string, error)
+	// BaseDomain returns the base domain of the clusters
+	BaseDomain func() (string, error)
+	// CredsSecretTemplates are the templates of the secrets referenced by the platform, none if not needed
+	CredsSecretTemplates []string
+	// CredsSecretValues returns the values of the secrets templates for the cluster
+	CredsSecretValues func(clusterName string) interface{}
+	// InstallConfigTemplate is the template of the install-config
+	InstallConfigTemplate string
+	// InstallConfigValues returns the values of the install-config template for the cluster
+	InstallConfigValues func(clusterName, baseDomain, region string) interface{}
+	// PlatformTemplate is the template of the platform stanza of the clusterDeployment and clusterPool
+	PlatformTemplate string
+	// PlatformValues returns the values of the platform template for the cluster
+	PlatformValues func(clusterName, region string) interface{}
+	// CredentialsManifests returns the manifests of the manual credentials mode, nil if not needed.
+	// They are provided to the installer with a configMap.
+	CredentialsManifests func() (map[string]string, error)
+	// QuotaErrors are regular expressions matching the messages of the provision failures caused by the quotas
+	QuotaErrors []string
+}
+
+// the rule of the failure rules giving its tag and link to the quota errors of the cloud providers
+const quotaLimitRule = "provision-limit-exceeded"
+
+var providers = map[string]*Provider{}
+
+// RegisterProvider adds a cloud provider and registers the failure rules of its quota errors,
+// it panics if the cloud provider is already registered.
+func RegisterProvider(p *Provider) {
+	if _, ok := providers[p.Name]; ok {
+		panic(fmt.Sprintf("cloud provider %s already registered", p.Name))
+	}
+	providers[p.Name] = p
+	for i, msg := range p.QuotaErrors {
+		failures.RegisterRules(failures.Rule{
+			Name:    fmt.Sprintf("provision-%s-quota-%d", p.Name, i+1),
+			Scope:   failures.ScopeProvision,
+			Message: msg,
+			From:    quotaLimitRule,
+		})
+	}
+}
+
+// GetProvider returns the registered cloud provider
+func GetProvider(cloud string) (*Provider, error) {
+	p, ok := providers[cloud]
+	if !ok {
+		return nil, fmt.Errorf("unsupported cloud %s, it must be one of %v", cloud, Providers())
+	}
+	return p, nil
+}
+
+// Providers returns the names of the registered cloud providers sorted
+func Providers() []string {
+	l := make([]string, 0, len(providers))
+	for name := range providers {
+		l = append(l, name)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// region returns the region of the cloud provider, "" if it has no region
+func (p *Provider) region() (string, error) {
+	if p.Region == nil {
+		return "", nil
+	}
+	return p.Region()
+}
+
+// newClusterName returns a new cluster name for the cloud provider.
+func newClusterName(cloud string) (*libgooptions.ClusterName, error) {
+	if _, err := GetProvider(cloud); err != nil {
+		return nil, err
+	}
+	return newPrefixedName(cloud)
+}
+
+// newPrefixedName returns a new name <prefix>-<owner>-<uid> for the resources created by the tests.
+// libgooptions only generates the names of its cloud providers,
+// the name of the other prefixes is generated for aws and its cloud replaced.
+func newPrefixedName(prefix string) (*libgooptions.ClusterName, error) {
+	switch prefix {
+	case "aws", "gcp", "azure", "baremetal":
+		return libgooptions.NewClusterName(prefix)
+	}
+	clusterNameObj, err := libgooptions.NewClusterName("aws")
+	if err != nil {
+		return nil, err
+	}
+	clusterNameObj.Cloud = prefix
+	return clusterNameObj, nil
+}
+
+func libgoRegion(cloud string) func() (string, error) {
+	return func() (string, error) {
+		return libgooptions.GetRegion(cloud)
+	}
+}
+
+func libgoBaseDomain(cloud string) func() (string, error) {
+	return func() (string, error) {
+		return libgooptions.GetBaseDomain(cloud)
+	}
+}
+
+// defaultPlatformValues are the values of the platforms which only reference the credentials secret
+func defaultPlatformValues(clusterName, region string) interface{} {
+	return struct {
+		ManagedClusterName   string
+		ManagedClusterRegion string
+	}{
+		ManagedClusterName:   clusterName,
+		ManagedClusterRegion: region,
+	}
+}
+
+func init() {
+	RegisterProvider(&Provider{
+		Name:                 "aws",
+		Region:               libgoRegion("aws"),
+		BaseDomain:           libgoBaseDomain("aws"),
+		CredsSecretTemplates: []string{"aws/creds_secret_cr.yaml"},
+		CredsSecretValues: func(clusterName string) interface{} {
+			return struct {
+				ManagedClusterName string
+				AWSAccessKeyID     string
+				AWSSecretAccessKey string
+			}{
+				ManagedClusterName: clusterName,
+				AWSAccessKeyID:     libgooptions.TestOptions.Options.CloudConnection.APIKeys.AWS.AWSAccessKeyID,
+				AWSSecretAccessKey: libgooptions.TestOptions.Options.CloudConnection.APIKeys.AWS.AWSAccessSecret,
+			}
+		},
+		InstallConfigTemplate: "aws/install_config.yaml",
+		InstallConfigValues: func(clusterName, baseDomain, region string) interface{} {
+			return struct {
+				ManagedClusterName         string
+				ManagedClusterBaseDomain   string
+				ManagedClusterRegion       string
+				ManagedClusterSSHPublicKey string
+			}{
+				ManagedClusterName:         clusterName,
+				ManagedClusterBaseDomain:   baseDomain,
+				ManagedClusterRegion:       region,
+				ManagedClusterSSHPublicKey: libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
+			}
+		},
+		PlatformTemplate: "aws/platform.yaml",
+		PlatformValues:   defaultPlatformValues,
+		QuotaErrors:      []string{"(Vcpu|Address|Vpc|NatGateway)LimitExceeded"},
+	})
+	RegisterProvider(&Provider{
+		Name:                 "azure",
+		Region:               libgoRegion("azure"),
+		BaseDomain:           libgoBaseDomain("azure"),
+		CredsSecretTemplates: []string{"azure/creds_secret_cr.yaml"},
+		CredsSecretValues: func(clusterName string) interface{} {
+			return struct {
+				ManagedClusterName           string
+				ManagedClusterClientId       string
+				ManagedClusterClientSecret   string
+				ManagedClusterTenantId       string
+				ManagedClusterSubscriptionId string
+			}{
+				ManagedClusterName:           clusterName,
+				ManagedClusterClientId:       libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.ClientID,
+				ManagedClusterClientSecret:   libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.ClientSecret,
+				ManagedClusterTenantId:       libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.TenantID,
+				ManagedClusterSubscriptionId: libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.SubscriptionID,
+			}
+		},
+		InstallConfigTemplate: "azure/install_config.yaml",
+		InstallConfigValues: func(clusterName, baseDomain, region string) interface{} {
+			return struct {
+				ManagedClusterName          string
+				ManagedClusterBaseDomain    string
+				ManagedClusterBaseDomainRGN string
+				ManagedClusterRegion        string
+				ManagedClusterSSHPublicKey  string
+			}{
+				ManagedClusterName:          clusterName,
+				ManagedClusterBaseDomain:    baseDomain,
+				ManagedClusterBaseDomainRGN: libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.BaseDomainRGN,
+				ManagedClusterRegion:        region,
+				ManagedClusterSSHPublicKey:  libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
+			}
+		},
+		PlatformTemplate: "azure/platform.yaml",
+		PlatformValues: func(clusterName, region string) interface{} {
+			return struct {
+				ManagedClusterName          string
+				ManagedClusterRegion        string
+				ManagedClusterBaseDomainRGN string
+			}{
+				ManagedClusterName:          clusterName,
+				ManagedClusterRegion:        region,
+				ManagedClusterBaseDomainRGN: libgooptions.TestOptions.Options.CloudConnection.APIKeys.Azure.BaseDomainRGN,
+			}
+		},
+		QuotaErrors: []string{"LimitReached"},
+	})
+	RegisterProvider(&Provider{
+		Name:                 "gcp",
+		Region:               libgoRegion("gcp"),
+		BaseDomain:           libgoBaseDomain("gcp"),
+		CredsSecretTemplates: []string{"gcp/creds_secret_cr.yaml"},
+		CredsSecretValues: func(clusterName string) interface{} {
+			return struct {
+				ManagedClusterName      string
+				GCPOSServiceAccountJson string
+			}{
+				ManagedClusterName:      clusterName,
+				GCPOSServiceAccountJson: libgooptions.TestOptions.Options.CloudConnection.APIKeys.GCP.ServiceAccountJSONKey,
+			}
+		},
+		InstallConfigTemplate: "gcp/install_config.yaml",
+		InstallConfigValues: func(clusterName, baseDomain, region string) interface{} {
+			return struct {
+				ManagedClusterName         string
+				ManagedClusterBaseDomain   string
+				ManagedClusterProjectID    string
+				ManagedClusterRegion       string
+				ManagedClusterSSHPublicKey string
+			}{
+				ManagedClusterName:         clusterName,
+				ManagedClusterBaseDomain:   baseDomain,
+				ManagedClusterProjectID:    libgooptions.TestOptions.Options.CloudConnection.APIKeys.GCP.ProjectID,
+				ManagedClusterRegion:       region,
+				ManagedClusterSSHPublicKey: libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
+			}
+		},
+		PlatformTemplate: "gcp/platform.yaml",
+		PlatformValues:   defaultPlatformValues,
+		QuotaErrors: []string{
+			"more than remaining quota",
+			"Quota '[A-Z_]+' exceeded",
+		},
+	})
+	RegisterProvider(&Provider{
+		Name:                  "baremetal",
+		BaseDomain:            libgoBaseDomain("baremetal"),
+		InstallConfigTemplate: "baremetal/install_config.yaml",
+		InstallConfigValues: func(clusterName, baseDomain, region string) interface{} {
+			bm := libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal
+			return struct {
+				ManagedClusterName             string
+				ManagedClusterBaseDomain       string
+				LibvirtURI                     string
+				ProvisioningNetworkCIDR        string
+				ProvisioningNetworkInterface   string
+				ProvisioningBridge             string
+				ExternalBridge                 string
+				APIVIP                         string
+				IngressVIP                     string
+				ManagedClusterBootstrapOSImage string
+				ManagedClusterClusterOSImage   string
+				ManagedClusterSSHPublicKey     string
+				ManagedClusterTrustBundle      string
+				ImageRegistryMirror            string
+				Hosts                          []libgooptions.Hosts
+			}{
+				ManagedClusterName:             clusterName,
+				ManagedClusterBaseDomain:       baseDomain,
+				LibvirtURI:                     bm.LibvirtURI,
+				ProvisioningNetworkCIDR:        bm.ProvisioningNetworkCIDR,
+				ProvisioningNetworkInterface:   bm.ProvisioningNetworkInterface,
+				ProvisioningBridge:             bm.ProvisioningBridge,
+				ExternalBridge:                 bm.ExternalBridge,
+				APIVIP:                         bm.APIVIP,
+				IngressVIP:                     bm.IngressVIP,
+				ManagedClusterBootstrapOSImage: bm.BootstrapOSImage,
+				ManagedClusterClusterOSImage:   bm.ClusterOSImage,
+				ManagedClusterSSHPublicKey:     libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
+				ManagedClusterTrustBundle:      bm.TrustBundle,
+				ImageRegistryMirror:            bm.ImageRegistryMirror,
+				Hosts:                          bm.Hosts,
+			}
+		},
+		PlatformTemplate: "baremetal/platform.yaml",
+		PlatformValues: func(clusterName, region string) interface{} {
+			return struct {
+				ManagedClusterName string
+				Hosts              []libgooptions.Hosts
+			}{
+				ManagedClusterName: clusterName,
+				Hosts:              libgooptions.TestOptions.Options.CloudConnection.APIKeys.BareMetal.Hosts,
+			}
+		},
+	})
+	RegisterProvider(&Provider{
+		Name: "vsphere",
+		BaseDomain: func() (string, error) {
+			return options.TestOptions.Options.CloudConnection.APIKeys.VSphere.BaseDNSDomain, nil
+		},
+		CredsSecretTemplates: []string{"vsphere/creds_secret_cr.yaml", "vsphere/certs_secret_cr.yaml"},
+		CredsSecretValues: func(clusterName string) interface{} {
+			key := options.TestOptions.Options.CloudConnection.APIKeys.VSphere
+			return struct {
+				ManagedClusterName string
+				Username           string
+				Password           string
+				CACertificate      string
+			}{
+				ManagedClusterName: clusterName,
+				Username:           key.Username,
+				Password:           key.Password,
+				CACertificate:      key.CACertificate,
+			}
+		},
+		InstallConfigTemplate: "vsphere/install_config.yaml",
+		InstallConfigValues: func(clusterName, baseDomain, region string) interface{} {
+			return struct {
+				ManagedClusterName         string
+				ManagedClusterBaseDomain   string
+				ManagedClusterSSHPublicKey string
+				options.VSphereAPIKey
+			}{
+				ManagedClusterName:         clusterName,
+				ManagedClusterBaseDomain:   baseDomain,
+				ManagedClusterSSHPublicKey: libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
+				VSphereAPIKey:              options.TestOptions.Options.CloudConnection.APIKeys.VSphere,
+			}
+		},
+		PlatformTemplate: "vsphere/platform.yaml",
+		PlatformValues: func(clusterName, region string) interface{} {
+			return struct {
+				ManagedClusterName string
+				options.VSphereAPIKey
+			}{
+				ManagedClusterName: clusterName,
+				VSphereAPIKey:      options.TestOptions.Options.CloudConnection.APIKeys.VSphere,
+			}
+		},
+		QuotaErrors: []string{
+			"Insufficient resources to satisfy configured failover level",
+			"(insufficient|not enough) (disk )?space",
+		},
+	})
+	RegisterProvider(&Provider{
+		Name: "openstack",
+		BaseDomain: func() (string, error) {
+			return options.TestOptions.Options.CloudConnection.APIKeys.OpenStack.BaseDNSDomain, nil
+		},
+		CredsSecretTemplates: []string{"openstack/creds_secret_cr.yaml"},
+		CredsSecretValues: func(clusterName string) interface{} {
+			return struct {
+				ManagedClusterName string
+				CloudsYAML         string
+			}{
+				ManagedClusterName: clusterName,
+				CloudsYAML:         options.TestOptions.Options.CloudConnection.APIKeys.OpenStack.CloudsYAML,
+			}
+		},
+		InstallConfigTemplate: "openstack/install_config.yaml",
+		InstallConfigValues: func(clusterName, baseDomain, region string) interface{} {
+			return struct {
+				ManagedClusterName         string
+				ManagedClusterBaseDomain   string
+				ManagedClusterSSHPublicKey string
+				options.OpenStackAPIKey
+			}{
+				ManagedClusterName:         clusterName,
+				ManagedClusterBaseDomain:   baseDomain,
+				ManagedClusterSSHPublicKey: libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
+				OpenStackAPIKey:            options.TestOptions.Options.CloudConnection.APIKeys.OpenStack,
+			}
+		},
+		PlatformTemplate: "openstack/platform.yaml",
+		PlatformValues: func(clusterName, region string) interface{} {
+			return struct {
+				ManagedClusterName string
+				Cloud              string
+			}{
+				ManagedClusterName: clusterName,
+				Cloud:              options.TestOptions.Options.CloudConnection.APIKeys.OpenStack.Cloud,
+			}
+		},
+		QuotaErrors: []string{
+			"Quota exceeded for",
+			"QuotaExceeded",
+		},
+	})
+	RegisterProvider(&Provider{
+		Name: "ibmcloud",
+		Region: func() (string, error) {
+			return options.TestOptions.Options.CloudConnection.APIKeys.IBMCloud.Region, nil
+		},
+		BaseDomain: func() (string, error) {
+			return options.TestOptions.Options.CloudConnection.APIKeys.IBMCloud.BaseDNSDomain, nil
+		},
+		CredsSecretTemplates: []string{"ibmcloud/creds_secret_cr.yaml"},
+		CredsSecretValues: func(clusterName string) interface{} {
+			return struct {
+				ManagedClusterName string
+				APIKey             string
+			}{
+				ManagedClusterName: clusterName,
+				APIKey:             options.TestOptions.Options.CloudConnection.APIKeys.IBMCloud.APIKey,
+			}
+		},
+		InstallConfigTemplate: "ibmcloud/install_config.yaml",
+		InstallConfigValues: func(clusterName, baseDomain, region string) interface{} {
+			return struct {
+				ManagedClusterName         string
+				ManagedClusterBaseDomain   string
+				ManagedClusterRegion       string
+				ManagedClusterSSHPublicKey string
+			}{
+				ManagedClusterName:         clusterName,
+				ManagedClusterBaseDomain:   baseDomain,
+				ManagedClusterRegion:       region,
+				ManagedClusterSSHPublicKey: libgooptions.TestOptions.Options.CloudConnection.SSHPublicKey,
+			}
+		},
+		PlatformTemplate: "ibmcloud/platform.yaml",
+		PlatformValues: func(clusterName, region string) interface{} {
+			key := options.TestOptions.Options.CloudConnection.APIKeys.IBMCloud
+			return struct {
+				ManagedClusterName   string
+				ManagedClusterRegion string
+				AccountID            string
+				CISInstanceCRN       string
+			}{
+				ManagedClusterName:   clusterName,
+				ManagedClusterRegion: region,
+				AccountID:            key.AccountID,
+				CISInstanceCRN:       key.CISInstanceCRN,
+			}
+		},
+		CredentialsManifests: func() (map[string]string, error) {
+			return readManifests(options.TestOptions.Options.CloudConnection.APIKeys.IBMCloud.CredentialsManifestsDir)
+		},
+		QuotaErrors: []string{
+			"quota (has been )?exceeded",
+			"exceeds? the (account )?quota",
+		},
+	})
+}
+
+// readManifests reads the yaml files of a directory by file name
+func readManifests(dir string) (map[string]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	manifests := map[string]string{}
+	for _, f := range files {
+		if f.IsDir() || (filepath.Ext(f.Name()) != ".yaml" && filepath.Ext(f.Name()) != ".yml") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		manifests[f.Name()] = string(b)
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no manifest in %s", dir)
+	}
+	return manifests, nil
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/scale"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	libgocrdv1 "github.com/stolostron/library-go/pkg/apis/meta/v1/crd"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+)
+
+// the label set on the simulated managedClusters
+const simulatedClusterLabel = "cluster-lifecycle-e2e.open-cluster-management.io/simulated"
+
+// ScaleRegistration registers simulated managedClusters whose klusterlets run as goroutines of the test process,
+// creates manifestWorks in their namespaces and reports the percentiles of the latencies observed on the hub
+// for the clusters to be available and the manifestWorks to be applied. The clusters are always torn down.
+func ScaleRegistration() {
+	var hubClients *clients.HubClients
+	var sc options.Scale
+	var prefix string
+
+	BeforeEach(func() {
+		hubClients = clients.GetHubClients()
+		sc = options.TestOptions.Options.Scale
+		clusterNameObj, err := newPrefixedName("scale")
+		Expect(err).To(BeNil())
+		// the cluster of the report is not set, the diagnostics of the simulated clusters are not collected
+		prefix = clusterNameObj.String()
+		klog.V(1).Infof(`========================= Start Test scale %s with %d clusters ===============================`, prefix, sc.Clusters)
+	})
+
+	It("[P2][Sev2][cluster-lifecycle] Register simulated clusters and apply manifestWorks (cluster/g1/scale)", func() {
+		By("Checking the minimal requirements", func() {
+			Eventually(func() bool {
+				klog.V(2).Infof("Scale %s: Check CRDs", prefix)
+				has, missing, _ := libgocrdv1.HasCRDs(hubClients.APIExtensionClient,
+					[]string{
+						"managedclusters.cluster.open-cluster-management.io",
+						"manifestworks.work.open-cluster-management.io",
+					})
+				if !has {
+					klog.Errorf("Scale %s: Missing CRDs\n%#v", prefix, missing)
+				}
+				return has
+			}).Should(BeTrue())
+		})
+
+		// the clusters are created and their agents run with clients throttled at the qps of the options
+		// rather than the default qps of the clients of the tests, which would add to the latencies
+		restConfig := rest.CopyConfig(hubClients.RestConfig)
+		restConfig.QPS = float32(sc.QPS)
+		restConfig.Burst = 2 * sc.QPS
+		kubeClient, err := kubernetes.NewForConfig(restConfig)
+		Expect(err).To(BeNil())
+		dynamicClient, err := dynamic.NewForConfig(restConfig)
+		Expect(err).To(BeNil())
+
+		timeout := options.Timeout(options.TimeoutScale)
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer func() {
+			// the agents run until the clusters are deleted to release their manifestWorks
+			klog.V(1).Infof("Scale %s: Deleting the simulated clusters", prefix)
+			reports.TimeStep(reports.StepTeardown, Teardown)
+			cancel()
+		}()
+		go scale.ApproveCSRs(ctx, hubClients.KubeClient)
+		clusterTracker := scale.NewTracker(hubClients.DynamicClient, gvrManagedCluster, "ManagedClusterConditionAvailable")
+		go clusterTracker.Run(ctx)
+		workTracker := scale.NewTracker(hubClients.DynamicClient, gvrManifestWork, "Applied")
+		go workTracker.Run(ctx)
+
+		clusterNames := make([]string, sc.Clusters)
+		for i := range clusterNames {
+			clusterNames[i] = fmt.Sprintf("%s-%04d", prefix, i)
+		}
+		agents := make([]*scale.Agent, 0, sc.Clusters)
+		var agentsMutex sync.Mutex
+		By(fmt.Sprintf("Registering %d simulated clusters", sc.Clusters), func() {
+			klog.V(1).Infof("Scale %s: Registering %d simulated clusters, %d at once", prefix, sc.Clusters, sc.Concurrency)
+			Expect(forEachCluster(clusterNames, sc.Concurrency, func(clusterName string) error {
+				if err := createSimulatedCluster(kubeClient, dynamicClient, clusterTracker, clusterName, sc.LeaseDurationSeconds, nil); err != nil {
+					return err
+				}
+				agent := scale.NewAgent(kubeClient, dynamicClient, clusterName,
+					time.Duration(sc.LeaseDurationSeconds)*time.Second, timeout)
+				agentsMutex.Lock()
+				agents = append(agents, agent)
+				agentsMutex.Unlock()
+				go func() {
+					if err := agent.Run(ctx); err != nil && ctx.Err() == nil {
+						klog.Errorf("Scale %s: %v", prefix, err)
+					}
+				}()
+				return nil
+			})).To(BeNil())
+		})
+
+		When("the clusters are registered, wait for them to be available", func() {
+			err := clusterTracker.Wait(timeout)
+			var csrLatencies []time.Duration
+			for _, agent := range agents {
+				if latency := agent.CSRLatency(); latency != 0 {
+					csrLatencies = append(csrLatencies, latency)
+				}
+			}
+			recordLatency(prefix, reports.LatencyCSRSigned, reports.NewLatency(csrLatencies, len(agents)-len(csrLatencies)))
+			recordLatency(prefix, reports.LatencyClusterAvailable, clusterTracker.Latency())
+			if err != nil {
+				Fail(failures.Classify(failures.ScopeScale, "ClustersNotAvailable", err.Error()).Error())
+			}
+		})
+
+		By(fmt.Sprintf("Creating %d manifestWorks in each cluster namespace", sc.ManifestWorks), func() {
+			Expect(forEachCluster(clusterNames, sc.Concurrency, func(clusterName string) error {
+				for i := 0; i < sc.ManifestWorks; i++ {
+					if err := createManifestWork(dynamicClient, workTracker, clusterName, fmt.Sprintf("scale-work-%d", i)); err != nil {
+						return err
+					}
+				}
+				return nil
+			})).To(BeNil())
+		})
+
+		When("the manifestWorks are created, wait for them to be applied", func() {
+			err := workTracker.Wait(timeout)
+			recordLatency(prefix, reports.LatencyManifestWork, workTracker.Latency())
+			if err != nil {
+				Fail(failures.Classify(failures.ScopeScale, "ManifestWorksNotApplied", err.Error()).Error())
+			}
+		})
+	})
+}
+
+// forEachCluster calls f for each cluster with at most concurrency calls at once
+// and returns the aggregate of the errors.
+func forEachCluster(clusterNames []string, concurrency int, f func(clusterName string) error) error {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	errs := make([]error, len(clusterNames))
+	for i := range clusterNames {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = f(clusterNames[i])
+		}(i)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+// createSimulatedCluster creates the namespace and the accepted managedCluster of a simulated cluster with the labels.
+func createSimulatedCluster(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface, tracker *scale.Tracker,
+	clusterName string, leaseDurationSeconds int, labels map[string]string) error {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+	}
+	ownership.Own(ns)
+	_, err := kubeClient.CoreV1().Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("cluster %s: namespace can not be created: %v", clusterName, err)
+	}
+	if err == nil {
+		ownership.Record(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, "Namespace", "", clusterName)
+	}
+	mc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cluster.open-cluster-management.io/v1",
+		"kind":       "ManagedCluster",
+		"metadata": map[string]interface{}{
+			"name": clusterName,
+			"labels": map[string]interface{}{
+				"cloud":               "simulated",
+				"vendor":              "simulated",
+				simulatedClusterLabel: "true",
+			},
+		},
+		"spec": map[string]interface{}{
+			"hubAcceptsClient":     true,
+			"leaseDurationSeconds": int64(leaseDurationSeconds),
+		},
+	}}
+	for name, value := range labels {
+		if err := unstructured.SetNestedField(mc.Object, value, "metadata", "labels", name); err != nil {
+			return err
+		}
+	}
+	ownership.Own(mc)
+	tracker.Created("", clusterName)
+	if _, err := dynamicClient.Resource(gvrManagedCluster).Create(context.TODO(), mc, metav1.CreateOptions{}); err != nil {
+		tracker.Forget("", clusterName)
+		return fmt.Errorf("cluster %s: managedCluster can not be created: %v", clusterName, err)
+	}
+	ownership.Record(gvrManagedCluster, "ManagedCluster", "", clusterName)
+	klog.V(2).Infof("Cluster %s: simulated managedCluster created", clusterName)
+	return nil
+}
+
+// createManifestWork creates in the cluster namespace a manifestWork deploying a configMap.
+func createManifestWork(dynamicClient dynamic.Interface, tracker *scale.Tracker, clusterName, name string) error {
+	mw := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "work.open-cluster-management.io/v1",
+		"kind":       "ManifestWork",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": clusterName,
+		},
+		"spec": map[string]interface{}{
+			"workload": map[string]interface{}{
+				"manifests": []interface{}{
+					map[string]interface{}{
+						"apiVersion": "v1",
+						"kind":       "ConfigMap",
+						"metadata": map[string]interface{}{
+							"name":      name,
+							"namespace": "default",
+						},
+						"data": map[string]interface{}{
+							"cluster": clusterName,
+						},
+					},
+				},
+			},
+		},
+	}}
+	ownership.Own(mw)
+	tracker.Created(clusterName, name)
+	if _, err := dynamicClient.Resource(gvrManifestWork).Namespace(clusterName).Create(context.TODO(), mw, metav1.CreateOptions{}); err != nil {
+		tracker.Forget(clusterName, name)
+		return fmt.Errorf("cluster %s: manifestWork %s can not be created: %v", clusterName, name, err)
+	}
+	return nil
+}
+
+// recordLatency records the latency in the report and logs it.
+func recordLatency(prefix, name string, l reports.Latency) {
+	klog.V(1).Infof("Scale %s: %s latency of %d operations p50=%.2fs p90=%.2fs p99=%.2fs max=%.2fs missing=%d",
+		prefix, name, l.Count, l.P50, l.P90, l.P99, l.Max, l.Missing)
+	reports.RecordLatency(name, l)
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+// TeardownOnFailure deletes the objects created by the current spec if the spec failed,
+// they are kept otherwise for the next test groups.
+// It must be called in an AfterEach, after the diagnostics are collected.
+func TeardownOnFailure() {
+	if !CurrentGinkgoTestDescription().Failed {
+		ownership.Forget()
+		return
+	}
+	reports.TimeStep(reports.StepTeardown, Teardown)
+}
+
+// Teardown deletes the objects created by the specs which are not kept.
+// It must be called in the AfterSuite to tear down the objects of an interrupted spec.
+func Teardown() {
+	if !ownership.Pending() {
+		return
+	}
+	if err := ownership.Teardown(clients.GetHubClients().DynamicClient); err != nil {
+		klog.Errorf("Run %s: the teardown is incomplete, run 'clc-e2e cleanup -run-id=%s': %s", ownership.RunID(), ownership.RunID(), err)
+	}
+}
+
+// deleteOwnedClusterImageSet deletes the clusterImageSet if it was created by a run.
+func deleteOwnedClusterImageSet(hubClientDynamic dynamic.Interface, clusterName, imageSetName string) error {
+	gvr := schema.GroupVersionResource{Group: "hive.openshift.io", Version: "v1", Resource: "clusterimagesets"}
+	imageSet, err := hubClientDynamic.Resource(gvr).Get(context.TODO(), imageSetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if _, ok := imageSet.GetLabels()[ownership.RunIDLabel]; !ok {
+		return nil
+	}
+	klog.V(1).Infof("Cluster %s: Deleting the clusterImageSet %s", clusterName, imageSetName)
+	err = hubClientDynamic.Resource(gvr).Delete(context.TODO(), imageSetName, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
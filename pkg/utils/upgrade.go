@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/appliers"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/clients"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/failures"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/manifest"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/ownership"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/reports"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/tests/options"
+	"github.com/stolostron/cluster-lifecycle-e2e/pkg/waiters"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog"
+)
+
+var (
+	gvrClusterCurator = schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1beta1", Resource: "clustercurators"}
+	gvrClusterVersion = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+)
+
+// UpgradeCluster upgrades a cluster created by CreateCluster on the cloud with a clusterCurator
+// to the version defined in the options and checks the managedCluster and its addons recover.
+func UpgradeCluster(cloud, vendor, cloudProviders string) {
+	var clusterName string
+	var hubClients *clients.HubClients
+	var hubAppliers *appliers.HubAppliers
+	var upgrade options.Upgrade
+
+	BeforeEach(func() {
+		if cloudProviders != "" && !isRequestedCloudProvider(cloud, cloudProviders) {
+			Skip(fmt.Sprintf("Cloud provider %s skipped", cloud))
+		}
+		hubClients = clients.GetHubClients()
+		upgrade = options.TestOptions.Options.Upgrade
+		if upgrade.DesiredVersion == "" {
+			Skip("No upgrade.desiredVersion in the options")
+		}
+		hubAppliers = appliers.GetHubAppliers(hubClients)
+		// the cluster is the one created by this run, another run of the same owner may share the hub
+		entry, err := manifest.Lookup(hubClients.KubeClient, ownership.RunID(), cloud)
+		Expect(err).To(BeNil())
+		if entry == nil {
+			Fail(fmt.Sprintf("No cluster for Cloud provider %s to upgrade recorded in the run manifest %s/%s of the run %s, "+
+				"set -run-id to the run which created it",
+				cloud, manifest.Namespace, manifest.Name(ownership.RunID()), ownership.RunID()))
+		}
+		clusterName = entry.Cluster
+		reports.SetCluster(clusterName, cloud, "")
+		klog.V(1).Infof(`========================= Start Test upgrade cluster %s ===============================`, clusterName)
+	})
+
+	It(fmt.Sprintf("[P1][Sev1][cluster-lifecycle] Upgrade cluster on %s with vendor %s (cluster/g1/upgrade-cluster)", cloud, vendor), func() {
+		By(fmt.Sprintf("Checking the cluster %s is available before the upgrade", clusterName), func() {
+			WaitClusterImported(hubClients.DynamicClient, clusterName)
+		})
+
+		var managedClientDynamic dynamic.Interface
+		By(fmt.Sprintf("Loading the admin kubeconfig of the cluster %s", clusterName), func() {
+			rconfig, err := getAdminKubeConfig(hubClients.DynamicClient, hubClients.KubeClient, clusterName)
+			Expect(err).To(BeNil())
+			managedClientDynamic, err = dynamic.NewForConfig(rconfig)
+			Expect(err).To(BeNil())
+		})
+
+		By(fmt.Sprintf("Creating the clusterCurator to upgrade the cluster %s to %s", clusterName, upgrade.DesiredVersion), func() {
+			values := struct {
+				ManagedClusterName string
+				DesiredVersion     string
+				Channel            string
+			}{
+				ManagedClusterName: clusterName,
+				DesiredVersion:     upgrade.DesiredVersion,
+				Channel:            upgrade.Channel,
+			}
+			klog.V(1).Infof("Cluster %s: Creating the clusterCurator", clusterName)
+			Expect(hubAppliers.UpgradeApplier.CreateOrUpdateResource("cluster_curator_cr.yaml", values)).To(BeNil())
+		})
+
+		When(fmt.Sprintf("ClusterCurator created, wait for the curator job of the cluster %s to complete", clusterName), func() {
+			reports.TimeStep(reports.StepCuratorUpgrade, func() {
+				waitCuratorJob(hubClients.DynamicClient, clusterName)
+			})
+		})
+
+		When(fmt.Sprintf("Cluster %s upgrading, wait for the clusterVersion to be %s", clusterName, upgrade.DesiredVersion), func() {
+			reports.TimeStep(reports.StepClusterVersion, func() {
+				waitClusterVersion(managedClientDynamic, clusterName, upgrade.DesiredVersion)
+			})
+		})
+
+		When(fmt.Sprintf("Cluster %s upgraded, wait for the cluster to be available", clusterName), func() {
+			WaitClusterImported(hubClients.DynamicClient, clusterName)
+		})
+
+		When(fmt.Sprintf("Cluster %s upgraded, wait for Add-Ons to be available", clusterName), func() {
+			WaitClusterAdddonsAvailable(hubClients.DynamicClient, clusterName)
+		})
+
+		klog.V(1).Infof("========================= End Test upgrade cluster %s ===============================", clusterName)
+	})
+}
+
+// waitCuratorJob waits for the clustercurator-job condition of the clusterCurator to be true,
+// the transitions of the conditions are logged and a failed job stops the wait.
+func waitCuratorJob(hubClientDynamic dynamic.Interface, clusterName string) {
+	klog.V(1).Infof("Cluster %s: Wait the curator job to complete...", clusterName)
+	transitions := map[string]string{}
+	var jobErr error
+	err := newWaiter(hubClientDynamic, longInterval()).WaitFor(gvrClusterCurator, clusterName, clusterName, options.Timeout(options.TimeoutCuratorJob),
+		func(clusterCurator *unstructured.Unstructured) error {
+			if clusterCurator == nil {
+				return fmt.Errorf("Cluster %s: clusterCurator not found", clusterName)
+			}
+			conditions, _, _ := unstructured.NestedSlice(clusterCurator.Object, "status", "conditions")
+			for _, c := range conditions {
+				condition, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				conditionType, _, _ := unstructured.NestedString(condition, "type")
+				status, _, _ := unstructured.NestedString(condition, "status")
+				reason, _, _ := unstructured.NestedString(condition, "reason")
+				message, _, _ := unstructured.NestedString(condition, "message")
+				state := fmt.Sprintf("%s/%s", status, reason)
+				if transitions[conditionType] != state {
+					klog.V(1).Infof("Cluster %s: curator condition %s is %s: %s", clusterName, conditionType, state, message)
+					transitions[conditionType] = state
+				}
+				if reason == "Job_failed" {
+					jobErr = failures.Classify(failures.ScopeUpgrade, "CuratorJobFailed",
+						fmt.Sprintf("curator job %s of the cluster %s failed: %s", conditionType, clusterName, message))
+					return nil
+				}
+			}
+			status, err := waiters.ConditionStatus(clusterCurator, "clustercurator-job")
+			if err != nil || status != string(metav1.ConditionTrue) {
+				return failures.Classify(failures.ScopeUpgrade, "CuratorJobNotCompleted",
+					fmt.Sprintf("curator job of the cluster %s is not completed", clusterName))
+			}
+			return nil
+		})
+	if jobErr != nil {
+		err = jobErr
+	}
+	Expect(err).To(BeNil())
+	klog.V(1).Infof("Cluster %s: curator job completed", clusterName)
+}
+
+// waitClusterVersion waits for the last update of the clusterVersion of the managed cluster
+// to be the completed desired version.
+func waitClusterVersion(managedClientDynamic dynamic.Interface, clusterName, desiredVersion string) {
+	klog.V(1).Infof("Cluster %s: Wait the clusterVersion to be %s...", clusterName, desiredVersion)
+	Expect(newWaiter(managedClientDynamic, longInterval()).WaitFor(gvrClusterVersion, "", "version", options.Timeout(options.TimeoutClusterVersion),
+		func(clusterVersion *unstructured.Unstructured) error {
+			if clusterVersion == nil {
+				return fmt.Errorf("Cluster %s: clusterVersion not found", clusterName)
+			}
+			history, _, _ := unstructured.NestedSlice(clusterVersion.Object, "status", "history")
+			if len(history) == 0 {
+				return failures.Classify(failures.ScopeUpgrade, "ClusterVersionNotUpdated",
+					fmt.Sprintf("clusterVersion of the cluster %s has no history", clusterName))
+			}
+			last, ok := history[0].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("Cluster %s: unexpected clusterVersion history %v", clusterName, history[0])
+			}
+			version, _, _ := unstructured.NestedString(last, "version")
+			state, _, _ := unstructured.NestedString(last, "state")
+			klog.V(4).Infof("Cluster %s: clusterVersion %s %s", clusterName, version, state)
+			if version != desiredVersion || state != "Completed" {
+				return failures.Classify(failures.ScopeUpgrade, "ClusterVersionNotUpdated",
+					fmt.Sprintf("clusterVersion of the cluster %s is %s %s instead of %s Completed", clusterName, version, state, desiredVersion))
+			}
+			return nil
+		})).To(BeNil())
+	klog.V(1).Infof("Cluster %s: clusterVersion %s", clusterName, desiredVersion)
+}
@@ -0,0 +1,225 @@
+package waiters
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// Condition checks if the object reached the expected state.
+// obj is nil when the object doesn't exist.
+// It returns nil when the state is reached, otherwise an error describing why not,
+// wrapped by Stop if the state can not be reached anymore.
+type Condition func(obj *unstructured.Unstructured) error
+
+// stopError is returned by a condition to stop the wait
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string {
+	return e.err.Error()
+}
+
+// Stop wraps the error returned by a condition when the state can not be reached anymore
+// (ie: the object was deleted), WaitFor then returns err at once instead of waiting for the timeout.
+func Stop(err error) error {
+	return &stopError{err: err}
+}
+
+// Waiter waits for objects to reach a state by watching them.
+type Waiter struct {
+	client dynamic.Interface
+	//The period at which the condition is re-evaluated even if no event is received
+	resync time.Duration
+}
+
+// NewWaiter creates a Waiter
+// client: The dynamic client used to watch the objects
+// resync: The period at which the condition is re-evaluated if no event is received
+func NewWaiter(client dynamic.Interface, resync time.Duration) *Waiter {
+	return &Waiter{
+		client: client,
+		resync: resync,
+	}
+}
+
+// WaitFor watches the object identified by gvr, namespace and name and returns
+// as soon as the condition returns nil, or the error wrapped if the condition returns a Stop error.
+// On timeout, it returns the last error returned by the condition.
+// The condition is also re-evaluated every resync period, the object is then read
+// from the server if the watch is not synced yet.
+func (w *Waiter) WaitFor(
+	gvr schema.GroupVersionResource,
+	namespace, name string,
+	timeout time.Duration,
+	condition Condition,
+) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	informer := dynamicinformer.NewFilteredDynamicInformer(w.client, gvr, namespace, w.resync, cache.Indexers{},
+		func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}).Informer()
+
+	events := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notify() },
+		UpdateFunc: func(interface{}, interface{}) { notify() },
+		DeleteFunc: func(interface{}) { notify() },
+	})
+	go informer.Run(ctx.Done())
+
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+
+	ticker := time.NewTicker(w.resync)
+	defer ticker.Stop()
+
+	lastErr := fmt.Errorf("%s %s: not observed within %s", gvr.Resource, key, timeout)
+	evaluate := func(fallback bool) bool {
+		obj, err := w.current(ctx, informer, gvr, namespace, name, key, fallback)
+		if err != nil {
+			klog.V(4).Infof("%s %s: %s", gvr.Resource, key, err)
+			return false
+		}
+		lastErr = condition(obj)
+		if stop, ok := lastErr.(*stopError); ok {
+			klog.V(1).Infof("%s %s: stopped: %s", gvr.Resource, key, stop.err)
+			lastErr = stop.err
+			return true
+		}
+		return lastErr == nil
+	}
+
+	// evaluate returns true when the wait is over, lastErr is then nil if the condition is satisfied
+	if evaluate(true) {
+		return lastErr
+	}
+	for {
+		select {
+		case <-events:
+			if evaluate(false) {
+				return lastErr
+			}
+		case <-ticker.C:
+			if evaluate(true) {
+				return lastErr
+			}
+		case <-ctx.Done():
+			klog.V(1).Infof("%s %s: timeout after %s", gvr.Resource, key, timeout)
+			return lastErr
+		}
+	}
+}
+
+// WaitForDeletion waits for the object to be deleted.
+func (w *Waiter) WaitForDeletion(
+	gvr schema.GroupVersionResource,
+	namespace, name string,
+	timeout time.Duration,
+) error {
+	return w.WaitFor(gvr, namespace, name, timeout, Deleted())
+}
+
+// current returns the object from the informer cache or from the server
+// if the cache is not synced and fallback is requested.
+func (w *Waiter) current(
+	ctx context.Context,
+	informer cache.SharedIndexInformer,
+	gvr schema.GroupVersionResource,
+	namespace, name, key string,
+	fallback bool,
+) (*unstructured.Unstructured, error) {
+	if informer.HasSynced() {
+		item, exists, err := informer.GetStore().GetByKey(key)
+		if err != nil || !exists {
+			return nil, err
+		}
+		return item.(*unstructured.Unstructured), nil
+	}
+	if !fallback {
+		return nil, fmt.Errorf("watch not synced yet")
+	}
+	obj, err := w.client.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return obj, err
+}
+
+// Deleted returns a condition satisfied when the object doesn't exist.
+func Deleted() Condition {
+	return func(obj *unstructured.Unstructured) error {
+		if obj == nil {
+			return nil
+		}
+		return fmt.Errorf("%s %s still exists", obj.GetKind(), obj.GetName())
+	}
+}
+
+// Exists returns a condition satisfied when the object exists.
+func Exists() Condition {
+	return func(obj *unstructured.Unstructured) error {
+		if obj == nil {
+			return fmt.Errorf("not found")
+		}
+		return nil
+	}
+}
+
+// ConditionTrue returns a condition satisfied when the status condition
+// conditionType of the object is True.
+func ConditionTrue(conditionType string) Condition {
+	return func(obj *unstructured.Unstructured) error {
+		if obj == nil {
+			return fmt.Errorf("not found")
+		}
+		status, err := ConditionStatus(obj, conditionType)
+		if err != nil {
+			return err
+		}
+		if status != string(metav1.ConditionTrue) {
+			return fmt.Errorf("%s %s: condition %s is %q", obj.GetKind(), obj.GetName(), conditionType, status)
+		}
+		return nil
+	}
+}
+
+// ConditionStatus returns the status of the status condition conditionType of the object.
+func ConditionStatus(obj *unstructured.Unstructured, conditionType string) (string, error) {
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return "", err
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == conditionType {
+			status, _ := condition["status"].(string)
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("%s %s: condition %s not found", obj.GetKind(), obj.GetName(), conditionType)
+}